@@ -2,26 +2,59 @@ package lambdaapi
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
-	"regexp"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/validation"
 )
 
 // LambdaCrdAPI Lambda implementation of CrdAPI
 type LambdaCrdAPI struct {
 	repo             repository.HashKeyValueRepo
 	toStructFunction func(jsonString string) (interface{}, error)
+	validator        validation.Validator
+	keyPolicy        repository.KeyPolicy
+}
+
+// Option configures a LambdaCrdAPI
+type Option func(*LambdaCrdAPI)
+
+// WithValidator validates all values before they are stored. Invalid values are
+// rejected with 422 - Unprocessable Entity and the field errors as body.
+func WithValidator(validator validation.Validator) Option {
+	return func(lambdaCrdAPI *LambdaCrdAPI) {
+		lambdaCrdAPI.validator = validator
+	}
+}
+
+// WithKeyPolicy validates and escapes all keys with the policy of the backend.
+// Requests with invalid ids are rejected with 400 - Bad Request and the reason as body.
+func WithKeyPolicy(keyPolicy repository.KeyPolicy) Option {
+	return func(lambdaCrdAPI *LambdaCrdAPI) {
+		lambdaCrdAPI.keyPolicy = keyPolicy
+	}
 }
 
 // NewLambdaCrdAPI generating a struct to allow CRD actions
-func NewLambdaCrdAPI(repo repository.KeyValueRepo, toStructFunction func(jsonString string) (interface{}, error)) *LambdaCrdAPI {
-	hashKeyValueRepo := *repository.NewHashKeyValueRepo(repo)
-	return &LambdaCrdAPI{
-		repo:             hashKeyValueRepo,
+func NewLambdaCrdAPI(repo repository.KeyValueRepo, toStructFunction func(jsonString string) (interface{}, error), options ...Option) *LambdaCrdAPI {
+	lambdaCrdAPI := &LambdaCrdAPI{
 		toStructFunction: toStructFunction,
 	}
+	for _, option := range options {
+		option(lambdaCrdAPI)
+	}
+
+	if lambdaCrdAPI.keyPolicy != nil {
+		repo = repository.NewKeyPolicyRepo(repo, lambdaCrdAPI.keyPolicy)
+	}
+	if lambdaCrdAPI.validator != nil {
+		repo = validation.NewValidatingRepo(repo, lambdaCrdAPI.validator)
+	}
+	lambdaCrdAPI.repo = *repository.NewHashKeyValueRepo(repo)
+	return lambdaCrdAPI
 }
 
 // HTTPMethodProxy proxies requests to CRD method based on HTTP method
@@ -31,6 +64,8 @@ func (lambdaCrdAPI *LambdaCrdAPI) HTTPMethodProxy(request events.APIGatewayProxy
 		return lambdaCrdAPI.Get(request)
 	case "POST":
 		return lambdaCrdAPI.Post(request)
+	case "PATCH":
+		return lambdaCrdAPI.Patch(request)
 	case "DELETE":
 		return lambdaCrdAPI.Delete(request)
 	// return 405 - Method Not Allowed
@@ -54,8 +89,24 @@ func (lambdaCrdAPI *LambdaCrdAPI) Get(request events.APIGatewayProxyRequest) (*e
 
 // Post method create a new entity
 func (lambdaCrdAPI *LambdaCrdAPI) Post(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	requestBodyItem, _ := lambdaCrdAPI.toStructFunction(request.Body)
+	requestBodyItem, err := lambdaCrdAPI.toStructFunction(request.Body)
+	if err != nil {
+		log.Printf("Could not parse post request body %+v", err)
+		return &events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       err.Error(),
+		}, nil
+	}
 	item, err := lambdaCrdAPI.repo.Save(requestBodyItem)
+	if response, ok := toValidationResponse(err); ok {
+		return response, nil
+	}
+	if errors.Is(err, repository.ErrInvalidKey) {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       err.Error(),
+		}, nil
+	}
 
 	statusCode := 400
 	jsonString := ""
@@ -76,23 +127,91 @@ func (lambdaCrdAPI *LambdaCrdAPI) Post(request events.APIGatewayProxyRequest) (*
 	}, err
 }
 
+// Patch applies the JSON merge patch (RFC 7396) of the request body to an entity
+func (lambdaCrdAPI *LambdaCrdAPI) Patch(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	id, ok := getIDFromPath(request.Path)
+	if !ok {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, nil
+	}
+
+	item, err := lambdaCrdAPI.repo.Patch(id, []byte(request.Body))
+	if response, ok := toValidationResponse(err); ok {
+		return response, nil
+	}
+	if errors.Is(err, repository.ErrInvalidKey) {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	statusCode := 400
+	jsonString := ""
+
+	if err == nil {
+		jsonString, err = toJSON(item)
+	}
+
+	if err == nil {
+		statusCode = 200
+	} else {
+		log.Printf("Error during patch request processing %+v", err)
+	}
+
+	return &events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Body:       jsonString,
+	}, err
+}
+
 // Delete removes an entity
 func (lambdaCrdAPI *LambdaCrdAPI) Delete(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	regex := regexp.MustCompile(`(?:\/)?(?:.*\/)(?P<id>.+)`)
-	match := regex.FindStringSubmatch(request.Path)
+	id, ok := getIDFromPath(request.Path)
 
 	statusCode := 400
 	var err error
-	if len(match) == 2 {
-		err = lambdaCrdAPI.repo.Delete(match[1])
+	if ok {
+		err = lambdaCrdAPI.repo.Delete(id)
 		statusCode = 204
 	}
+	if errors.Is(err, repository.ErrInvalidKey) {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       err.Error(),
+		}, nil
+	}
 
 	return &events.APIGatewayProxyResponse{
 		StatusCode: statusCode,
 	}, err
 }
 
+// getIDFromPath extracts the last segment of a path. Paths without
+// a slash or with an empty last segment are invalid.
+func getIDFromPath(path string) (string, bool) {
+	index := strings.LastIndex(path, "/")
+	if index < 0 || index == len(path)-1 {
+		return "", false
+	}
+	return path[index+1:], true
+}
+
+// toValidationResponse creates a 422 - Unprocessable Entity response with the field errors
+// if the error is a validation error
+func toValidationResponse(err error) (*events.APIGatewayProxyResponse, bool) {
+	var validationError *validation.ValidationError
+	if !errors.As(err, &validationError) {
+		return nil, false
+	}
+	jsonString, _ := toJSON(validationError)
+	return &events.APIGatewayProxyResponse{
+		StatusCode: 422,
+		Body:       jsonString,
+	}, true
+}
+
 func toJSON(item interface{}) (string, error) {
 	byteArray, err := json.MarshalIndent(item, "", "    ")
 	jsonString := string(byteArray)
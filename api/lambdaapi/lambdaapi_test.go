@@ -8,6 +8,7 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/validation"
 )
 
 var mockedItem = MockItem{
@@ -143,6 +144,91 @@ func TestLambdaCrdAPI_DeleteNonExisting(t *testing.T) {
 	}
 }
 
+func TestLambdaCrdAPI_HTTPMethodProxy_PATCH(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	_, err := repo.Save("myKey", `{"MockString":"mock"}`)
+	checkError(err, t)
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("PATCH", "/somepath/myKey", `{"MockString":"patched"}`)
+
+	response, err := service.HTTPMethodProxy(request)
+	checkError(err, t)
+
+	if response.StatusCode != 200 {
+		t.Errorf("Expected status code to be 200 but was %d", response.StatusCode)
+	}
+	if !strings.Contains(response.Body, "patched") {
+		t.Errorf("Expected body to contain patched value. Body was actually %+v", response.Body)
+	}
+}
+
+func TestLambdaCrdAPI_Patch(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	_, err := repo.Save("myKey", MockItem{MockString: "mock"})
+	checkError(err, t)
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("PATCH", "/somepath/myKey", `{"MockString":"patched"}`)
+
+	_, err = service.Patch(request)
+	checkError(err, t)
+
+	item, _ := repo.Find("myKey")
+	expected := MockItem{MockString: "patched"}
+	if !reflect.DeepEqual(item.Value, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, item.Value)
+	}
+}
+
+// patchCountingRepo counts the patches applied by the repository
+type patchCountingRepo struct {
+	*repository.InMemoryRepo
+	patches int
+}
+
+func (repo *patchCountingRepo) Patch(key string, mergePatch []byte) (repository.KeyValuePair, error) {
+	repo.patches++
+	return repo.InMemoryRepo.Patch(key, mergePatch)
+}
+
+func TestLambdaCrdAPI_PatchWithKeyPolicy(t *testing.T) {
+	repo := &patchCountingRepo{InMemoryRepo: repository.NewInMemoryRepo()}
+	_, err := repo.Save("myKey", MockItem{MockString: "mock"})
+	checkError(err, t)
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct, WithKeyPolicy(repository.LengthKeyPolicy{MaxLength: 10}))
+	request := generateMockedRequest("PATCH", "/somepath/myKey", `{"MockString":"patched"}`)
+
+	response, err := service.Patch(request)
+	checkError(err, t)
+
+	if response.StatusCode != 200 || repo.patches != 1 {
+		t.Errorf("Expected patch of the wrapped repository but found %d patches and %+v", repo.patches, response)
+	}
+}
+
+func TestLambdaCrdAPI_PatchInvalid(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("PATCH", "invalid", `{"MockString":"patched"}`)
+
+	response, _ := service.Patch(request)
+
+	if response.StatusCode != 400 {
+		t.Errorf("Expected response to deliver 400. But received %v", response.StatusCode)
+	}
+}
+
+func TestLambdaCrdAPI_PatchNonExisting(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("PATCH", "/somepath/nonexisting", `{"MockString":"patched"}`)
+
+	response, _ := service.Patch(request)
+
+	if response.StatusCode != 400 {
+		t.Errorf("Expected response to deliver 400. But received %v", response.StatusCode)
+	}
+}
+
 func generateMockedRequest(httpMethod string, path string, body string) events.APIGatewayProxyRequest {
 	return events.APIGatewayProxyRequest{
 		HTTPMethod: httpMethod,
@@ -156,3 +242,130 @@ func checkError(err error, t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestLambdaCrdAPI_GetMetadata(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	_, err := repo.Save("myKey", mockedItem)
+	checkError(err, t)
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("GET", "", "")
+
+	response, _ := service.Get(request)
+
+	items := []repository.KeyValuePair{}
+	err = json.Unmarshal([]byte(response.Body), &items)
+	checkError(err, t)
+	if len(items) != 1 || items[0].Metadata == nil || items[0].Metadata.Version != 1 {
+		t.Errorf("Expected item with metadata version 1 but found %+v", response.Body)
+	}
+}
+
+func TestLambdaCrdAPI_PostInvalidBody(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("POST", "", "{invalid")
+
+	response, err := service.Post(request)
+	checkError(err, t)
+
+	if response.StatusCode != 400 {
+		t.Errorf("Expected response to deliver 400. But received %v", response.StatusCode)
+	}
+	if response.Body == "" {
+		t.Error("Expected body to contain the parse error")
+	}
+	items, _ := repo.FindAll()
+	if len(items) != 0 {
+		t.Errorf("No items were expected to be in the repo. Instead found %v", items)
+	}
+}
+
+func TestLambdaCrdAPI_PostValidationError(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct, WithValidator(createMockValidator(t)))
+	request := generateMockedRequest("POST", "", `{"MockString":"abc"}`)
+
+	response, err := service.Post(request)
+	checkError(err, t)
+
+	if response.StatusCode != 422 {
+		t.Errorf("Expected response to deliver 422. But received %v", response.StatusCode)
+	}
+	if !strings.Contains(response.Body, "/MockString") {
+		t.Errorf("Expected body to contain field error. Body was actually %+v", response.Body)
+	}
+	items, _ := repo.FindAll()
+	if len(items) != 0 {
+		t.Errorf("No items were expected to be in the repo. Instead found %v", items)
+	}
+}
+
+func TestLambdaCrdAPI_PostValid(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct, WithValidator(createMockValidator(t)))
+	request := generateMockedRequest("POST", "", `{"MockString":"valid value"}`)
+
+	response, err := service.Post(request)
+	checkError(err, t)
+
+	if response.StatusCode != 200 {
+		t.Errorf("Expected response to deliver 200. But received %v", response.StatusCode)
+	}
+}
+
+func TestLambdaCrdAPI_PatchValidationError(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	_, err := repo.Save("myKey", MockItem{MockString: "valid value"})
+	checkError(err, t)
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct, WithValidator(createMockValidator(t)))
+	request := generateMockedRequest("PATCH", "/somepath/myKey", `{"MockString":"abc"}`)
+
+	response, err := service.Patch(request)
+	checkError(err, t)
+
+	if response.StatusCode != 422 {
+		t.Errorf("Expected response to deliver 422. But received %v", response.StatusCode)
+	}
+	item, _ := repo.Find("myKey")
+	if item.Value.(MockItem).MockString != "valid value" {
+		t.Errorf("Expected item to stay unchanged but found %+v", item.Value)
+	}
+}
+
+func createMockValidator(t *testing.T) validation.Validator {
+	validator, err := validation.NewSchemaValidator([]byte(`{
+		"type": "object",
+		"properties": {"MockString": {"type": "string", "minLength": 5}},
+		"required": ["MockString"]
+	}`))
+	checkError(err, t)
+	return validator
+}
+
+func TestLambdaCrdAPI_DeleteTrailingSlash(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct)
+	request := generateMockedRequest("DELETE", "/somepath/", "")
+
+	response, _ := service.Delete(request)
+
+	if response.StatusCode != 400 {
+		t.Errorf("Expected response to deliver 400. But received %v", response.StatusCode)
+	}
+}
+
+func TestLambdaCrdAPI_DeleteInvalidKey(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	service := NewLambdaCrdAPI(repo, mockedItem.ToStruct, WithKeyPolicy(repository.LengthKeyPolicy{MaxLength: 3}))
+	request := generateMockedRequest("DELETE", "/somepath/tooLong", "")
+
+	response, err := service.Delete(request)
+	checkError(err, t)
+
+	if response.StatusCode != 400 {
+		t.Errorf("Expected response to deliver 400. But received %v", response.StatusCode)
+	}
+	if !strings.Contains(response.Body, "tooLong") {
+		t.Errorf("Expected body to contain the reason. Body was actually %+v", response.Body)
+	}
+}
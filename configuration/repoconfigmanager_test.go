@@ -26,7 +26,7 @@ func TestRepositoryConfigProviderSaveConfig(t *testing.T) {
 		Key:   testKey,
 		Value: testValue,
 	}
-	if actual != expected {
+	if actual.Key != expected.Key || actual.Value != expected.Value {
 		t.Errorf("Expected %v but retrieved %v", expected, actual)
 	}
 }
@@ -52,7 +52,7 @@ func TestRepositoryConfigProviderSaveConfig_Twice(t *testing.T) {
 		Key:   testKey,
 		Value: testValue,
 	}
-	if actual != expected {
+	if actual.Key != expected.Key || actual.Value != expected.Value {
 		t.Errorf("Expected %v but retrieved %v", expected, actual)
 	}
 }
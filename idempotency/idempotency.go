@@ -0,0 +1,179 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// ErrInProgress is returned if a request with the same idempotency key is currently processed
+var ErrInProgress = errors.New("request with the same idempotency key is in progress")
+
+// Status of an idempotency record
+type Status string
+
+const (
+	// StatusInProgress marks a request which is currently processed
+	StatusInProgress Status = "IN_PROGRESS"
+	// StatusCompleted marks a request whose result is stored
+	StatusCompleted Status = "COMPLETED"
+)
+
+// maxAttempts limits the retries if records are modified concurrently
+const maxAttempts = 3
+
+// TTLAttribute is the attribute of a record which holds its expiry in epoch seconds. Use it as
+// TableSpec.TTLAttribute of a DynamoDB table with native attributes to remove expired records.
+const TTLAttribute = "expiresAt"
+
+// Record is the persisted state of an idempotency key
+type Record struct {
+	Status Status `json:"status"`
+	// serialized result of a completed request
+	Result string `json:"result"`
+	// expiry as unix time in nanoseconds
+	Expires int64 `json:"expires"`
+	// expiry as unix time in seconds, which is the format of DynamoDB time to live
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// ToStruct converts json string to struct
+func (record Record) ToStruct(jsonString string) (interface{}, error) {
+	err := json.Unmarshal([]byte(jsonString), &record)
+	return record, err
+}
+
+// Store records the state of requests in a repository to process each idempotency key
+// only once. Repositories which are decoding values need to use Record as item template.
+//
+// Expired records are not removed by the Store, they are only replaced if the same key is
+// used again. Enable time to live on TTLAttribute if the repository supports it, otherwise
+// expired records have to be cleaned up periodically to limit the size of the repository.
+type Store struct {
+	repo              repository.KeyValueRepo
+	ttl               time.Duration
+	inProgressTimeout time.Duration
+	now               func() time.Time
+}
+
+// NewStore creates a Store. Results are replayed for the duration of the ttl. The
+// inProgressTimeout defines after which time a request, which did not complete (e.g. because
+// the Lambda timed out), can be processed again. It should match the Lambda timeout.
+func NewStore(repo repository.KeyValueRepo, ttl time.Duration, inProgressTimeout time.Duration) *Store {
+	return &Store{
+		repo:              repo,
+		ttl:               ttl,
+		inProgressTimeout: inProgressTimeout,
+		now:               time.Now,
+	}
+}
+
+// Do runs fn once per key and stores its result. On replay the stored result is returned
+// without running fn. ErrInProgress is returned if the key is currently processed.
+// If fn fails, nothing is stored and the next call with the same key runs fn again.
+//
+// If the repository supports conditional writes, the result is only stored if the claim of
+// the request was not taken over in the meantime, e.g. because fn exceeded the
+// inProgressTimeout. Otherwise the result is returned with repository.ErrConditionFailed.
+func (store *Store) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	inProgress := newRecord(StatusInProgress, "", store.now().Add(store.inProgressTimeout))
+
+	claimed := false
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		_, err := store.repo.Save(key, inProgress)
+		if err == nil {
+			claimed = true
+			break
+		}
+		if !errors.Is(err, repository.ErrAlreadyExists) {
+			return nil, err
+		}
+
+		item, err := store.repo.Find(key)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue // record was removed in the meantime
+		}
+		if err != nil {
+			return nil, err
+		}
+		record, err := toRecord(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		if record.Expires > store.now().UnixNano() {
+			if record.Status == StatusCompleted {
+				return []byte(record.Result), nil
+			}
+			return nil, ErrInProgress
+		}
+
+		// take over expired records
+		err = store.replace(key, item.Value, inProgress)
+		if err == repository.ErrConditionFailed {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		claimed = true
+		break
+	}
+	if !claimed {
+		return nil, fmt.Errorf("could not claim idempotency key %s after %d attempts", key, maxAttempts)
+	}
+
+	result, err := fn()
+	if err != nil {
+		if deleteErr := store.remove(key, inProgress); deleteErr != nil {
+			return nil, fmt.Errorf("%v; could not reset idempotency key %s: %v", err, key, deleteErr)
+		}
+		return nil, err
+	}
+
+	err = store.replace(key, inProgress, newRecord(StatusCompleted, string(result), store.now().Add(store.ttl)))
+	return result, err
+}
+
+func newRecord(status Status, result string, expires time.Time) Record {
+	return Record{
+		Status:    status,
+		Result:    result,
+		Expires:   expires.UnixNano(),
+		ExpiresAt: expires.Unix(),
+	}
+}
+
+// replace overwrites a record. If the repository supports conditional writes, the record is
+// only overwritten if it was not changed by a concurrent request.
+func (store *Store) replace(key string, expected interface{}, record Record) error {
+	if conditional, ok := store.repo.(repository.ConditionalRepo); ok {
+		_, err := conditional.CompareAndSwap(key, expected, record)
+		if !errors.Is(err, repository.ErrNotSupported) {
+			return err
+		}
+	}
+	_, err := store.repo.Overwrite(key, record)
+	return err
+}
+
+// remove deletes a record. If the repository supports conditional writes, the record is
+// only deleted if it was not changed by a concurrent request.
+func (store *Store) remove(key string, expected interface{}) error {
+	if conditional, ok := store.repo.(repository.ConditionalRepo); ok {
+		err := conditional.CompareAndDelete(key, expected)
+		if !errors.Is(err, repository.ErrNotSupported) {
+			return err
+		}
+	}
+	return store.repo.Delete(key)
+}
+
+// toRecord converts stored values into a record independent of the decoding of the repository
+func toRecord(value interface{}) (Record, error) {
+	result := Record{}
+	err := repository.DecodeValue(value, &result)
+	return result, err
+}
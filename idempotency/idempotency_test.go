@@ -0,0 +1,200 @@
+package idempotency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+func TestDo(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+
+	result, err := store.Do("key", func() ([]byte, error) {
+		return []byte("result"), nil
+	})
+	checkError(err, t)
+
+	if string(result) != "result" {
+		t.Errorf("Expected result but found %s", result)
+	}
+}
+
+func TestDoExpiresAt(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	store := NewStore(repo, time.Hour, time.Minute)
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+
+	_, err := store.Do("key", func() ([]byte, error) {
+		return []byte("result"), nil
+	})
+	checkError(err, t)
+	item, err := repo.Find("key")
+	checkError(err, t)
+	record, err := toRecord(item.Value)
+	checkError(err, t)
+
+	if record.ExpiresAt != now.Add(time.Hour).Unix() {
+		t.Errorf("Expected expiry in epoch seconds but found %+v", record)
+	}
+}
+
+func TestDoReplay(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+	calls := 0
+	fn := func() ([]byte, error) {
+		calls++
+		return []byte("result"), nil
+	}
+
+	_, err := store.Do("key", fn)
+	checkError(err, t)
+	result, err := store.Do("key", fn)
+	checkError(err, t)
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call but found %d", calls)
+	}
+	if string(result) != "result" {
+		t.Errorf("Expected replayed result but found %s", result)
+	}
+}
+
+func TestDoInProgress(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+
+	_, err := store.Do("key", func() ([]byte, error) {
+		_, err := store.Do("key", func() ([]byte, error) {
+			t.Error("Duplicate must not be executed")
+			return nil, nil
+		})
+		if err != ErrInProgress {
+			t.Errorf("Expected %v but found %v", ErrInProgress, err)
+		}
+		return []byte("result"), nil
+	})
+	checkError(err, t)
+}
+
+func TestDoFailure(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+	expected := errors.New("test error")
+
+	_, err := store.Do("key", func() ([]byte, error) {
+		return nil, expected
+	})
+	if err != expected {
+		t.Errorf("Expected %v but found %v", expected, err)
+	}
+	result, err := store.Do("key", func() ([]byte, error) {
+		return []byte("retry"), nil
+	})
+	checkError(err, t)
+
+	if string(result) != "retry" {
+		t.Errorf("Expected failed request to be executed again but found %s", result)
+	}
+}
+
+func TestDoExpired(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	store := NewStore(repo, time.Hour, time.Minute)
+	_, err := store.Do("key", func() ([]byte, error) {
+		return []byte("first"), nil
+	})
+	checkError(err, t)
+
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	result, err := store.Do("key", func() ([]byte, error) {
+		return []byte("second"), nil
+	})
+	checkError(err, t)
+
+	if string(result) != "second" {
+		t.Errorf("Expected expired record to be replaced but found %s", result)
+	}
+}
+
+func TestDoExpiredInProgress(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	store := NewStore(repo, time.Hour, time.Minute)
+	_, err := repo.Save("key", Record{
+		Status:  StatusInProgress,
+		Expires: time.Now().Add(-time.Second).UnixNano(),
+	})
+	checkError(err, t)
+
+	result, err := store.Do("key", func() ([]byte, error) {
+		return []byte("result"), nil
+	})
+	checkError(err, t)
+
+	if string(result) != "result" {
+		t.Errorf("Expected timed out request to be executed again but found %s", result)
+	}
+}
+
+func TestDoTakenOver(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	store := NewStore(repo, time.Hour, time.Minute)
+	other := NewStore(repo, time.Hour, time.Minute)
+	other.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+	result, err := store.Do("key", func() ([]byte, error) {
+		// the claim expires and is taken over by another request
+		_, err := other.Do("key", func() ([]byte, error) {
+			return []byte("other"), nil
+		})
+		checkError(err, t)
+		return []byte("result"), nil
+	})
+
+	if err != repository.ErrConditionFailed || string(result) != "result" {
+		t.Errorf("Expected result and %v but found %s and %v", repository.ErrConditionFailed, result, err)
+	}
+	item, err := repo.Find("key")
+	checkError(err, t)
+	if record, _ := toRecord(item.Value); record.Result != "other" {
+		t.Errorf("Expected result of the other request to be kept but found %+v", record)
+	}
+}
+
+// unavailableRepo fails all saves
+type unavailableRepo struct {
+	*repository.InMemoryRepo
+}
+
+func (repo *unavailableRepo) Save(key string, in interface{}) (repository.KeyValuePair, error) {
+	return repository.KeyValuePair{}, errors.New("unavailable")
+}
+
+func TestDoSaveError(t *testing.T) {
+	store := NewStore(&unavailableRepo{repository.NewInMemoryRepo()}, time.Hour, time.Minute)
+
+	_, err := store.Do("key", func() ([]byte, error) {
+		t.Error("Request must not be executed without claim")
+		return nil, nil
+	})
+
+	if err == nil || errors.Is(err, ErrInProgress) {
+		t.Errorf("Expected the save error but found %v", err)
+	}
+}
+
+func TestToRecordString(t *testing.T) {
+	record, err := toRecord(`{"status":"COMPLETED","result":"a","expires":5}`)
+	checkError(err, t)
+
+	expected := Record{Status: StatusCompleted, Result: "a", Expires: 5}
+	if record != expected {
+		t.Errorf("Expected %+v but found %+v", expected, record)
+	}
+}
+
+func checkError(err error, t *testing.T) {
+	if err != nil {
+		t.Error(err)
+	}
+}
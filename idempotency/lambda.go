@@ -0,0 +1,95 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HeaderName is the request header carrying the idempotency key
+const HeaderName = "Idempotency-Key"
+
+// APIGatewayHandler handles an API Gateway request
+type APIGatewayHandler func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+
+// SQSMessageHandler handles a single SQS message
+type SQSMessageHandler func(message events.SQSMessage) error
+
+// APIGatewayKey returns the value of the Idempotency-Key header. The lookup is case insensitive.
+func APIGatewayKey(request events.APIGatewayProxyRequest) (string, bool) {
+	for name, value := range request.Headers {
+		if strings.EqualFold(name, HeaderName) && value != "" {
+			return value, true
+		}
+	}
+	for name, values := range request.MultiValueHeaders {
+		if strings.EqualFold(name, HeaderName) && len(values) > 0 && values[0] != "" {
+			return values[0], true
+		}
+	}
+	return "", false
+}
+
+// SQSKey returns the message id, which stays the same if a message is delivered again
+func SQSKey(message events.SQSMessage) string {
+	return message.MessageId
+}
+
+// WrapAPIGatewayHandler replays stored responses for requests with an Idempotency-Key header.
+// Requests without the header are passed to the handler as is. Concurrent requests with
+// the same key are answered with 409 - Conflict.
+// Responses are only stored if the handler did not return an error.
+func (store *Store) WrapAPIGatewayHandler(handler APIGatewayHandler) APIGatewayHandler {
+	return func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		key, ok := APIGatewayKey(request)
+		if !ok {
+			return handler(request)
+		}
+
+		result, err := store.Do(key, func() ([]byte, error) {
+			response, err := handler(request)
+			if err != nil {
+				return nil, err
+			}
+			return json.Marshal(response)
+		})
+		if err == ErrInProgress {
+			return &events.APIGatewayProxyResponse{
+				StatusCode: 409,
+			}, nil
+		}
+		if err != nil {
+			return &events.APIGatewayProxyResponse{
+				StatusCode: 500,
+			}, err
+		}
+
+		response := &events.APIGatewayProxyResponse{}
+		err = json.Unmarshal(result, response)
+		return response, err
+	}
+}
+
+// WrapSQSHandler processes each message of an SQS event only once. Messages which failed or
+// are processed concurrently are reported as batch item failures to be delivered again.
+func (store *Store) WrapSQSHandler(handler SQSMessageHandler) func(event events.SQSEvent) (events.SQSEventResponse, error) {
+	return func(event events.SQSEvent) (events.SQSEventResponse, error) {
+		response := events.SQSEventResponse{
+			BatchItemFailures: []events.SQSBatchItemFailure{},
+		}
+		for _, message := range event.Records {
+			_, err := store.Do(SQSKey(message), func() ([]byte, error) {
+				return nil, handler(message)
+			})
+			if err != nil {
+				log.Printf("Could not process message %s: %v", message.MessageId, err)
+				response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+					ItemIdentifier: message.MessageId,
+				})
+			}
+		}
+		return response, nil
+	}
+}
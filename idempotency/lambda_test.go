@@ -0,0 +1,131 @@
+package idempotency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+func TestAPIGatewayKey(t *testing.T) {
+	key, ok := APIGatewayKey(events.APIGatewayProxyRequest{
+		Headers: map[string]string{"idempotency-key": "abc"},
+	})
+
+	if !ok || key != "abc" {
+		t.Errorf("Expected key abc but found '%s'", key)
+	}
+}
+
+func TestAPIGatewayKeyMultiValue(t *testing.T) {
+	key, ok := APIGatewayKey(events.APIGatewayProxyRequest{
+		MultiValueHeaders: map[string][]string{HeaderName: {"abc"}},
+	})
+
+	if !ok || key != "abc" {
+		t.Errorf("Expected key abc but found '%s'", key)
+	}
+}
+
+func TestAPIGatewayKeyMissing(t *testing.T) {
+	_, ok := APIGatewayKey(events.APIGatewayProxyRequest{})
+
+	if ok {
+		t.Error("Expected no key")
+	}
+}
+
+func TestWrapAPIGatewayHandlerReplay(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+	calls := 0
+	handler := store.WrapAPIGatewayHandler(func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		calls++
+		return &events.APIGatewayProxyResponse{
+			StatusCode: 200,
+			Body:       "sent",
+		}, nil
+	})
+	request := events.APIGatewayProxyRequest{
+		Headers: map[string]string{HeaderName: "abc"},
+	}
+
+	_, err := handler(request)
+	checkError(err, t)
+	response, err := handler(request)
+	checkError(err, t)
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call but found %d", calls)
+	}
+	if response.StatusCode != 200 || response.Body != "sent" {
+		t.Errorf("Expected replayed response but found %+v", response)
+	}
+}
+
+func TestWrapAPIGatewayHandlerWithoutKey(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+	calls := 0
+	handler := store.WrapAPIGatewayHandler(func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		calls++
+		return &events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	})
+
+	_, err := handler(events.APIGatewayProxyRequest{})
+	checkError(err, t)
+	_, err = handler(events.APIGatewayProxyRequest{})
+	checkError(err, t)
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls but found %d", calls)
+	}
+}
+
+func TestWrapAPIGatewayHandlerInProgress(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+	request := events.APIGatewayProxyRequest{
+		Headers: map[string]string{HeaderName: "abc"},
+	}
+	var handler APIGatewayHandler
+	handler = store.WrapAPIGatewayHandler(func(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		response, err := handler(request)
+		checkError(err, t)
+		if response.StatusCode != 409 {
+			t.Errorf("Expected 409 for concurrent request but found %d", response.StatusCode)
+		}
+		return &events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	})
+
+	_, err := handler(request)
+	checkError(err, t)
+}
+
+func TestWrapSQSHandler(t *testing.T) {
+	store := NewStore(repository.NewInMemoryRepo(), time.Hour, time.Minute)
+	processed := make([]string, 0)
+	handler := store.WrapSQSHandler(func(message events.SQSMessage) error {
+		if message.Body == "fail" {
+			return errors.New("test error")
+		}
+		processed = append(processed, message.MessageId)
+		return nil
+	})
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "1", Body: "ok"},
+			{MessageId: "2", Body: "fail"},
+			{MessageId: "1", Body: "ok"},
+		},
+	}
+
+	response, err := handler(event)
+	checkError(err, t)
+
+	if len(processed) != 1 {
+		t.Errorf("Expected duplicate message to be processed once but found %v", processed)
+	}
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "2" {
+		t.Errorf("Expected failure for message 2 but found %+v", response.BatchItemFailures)
+	}
+}
@@ -0,0 +1,190 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// ErrLocked is returned if a lock is held by another owner and its lease did not expire
+var ErrLocked = errors.New("lock is held by another owner")
+
+// ErrLockLost is returned if a lease is renewed or released after the lock was
+// taken over by another owner
+var ErrLockLost = errors.New("lock is no longer held by the owner")
+
+// Lease is a granted lock. The token increases with each acquisition of the lock and can
+// be passed to downstream systems to reject writes of stale lock owners (fencing).
+type Lease struct {
+	Name    string
+	Owner   string
+	Token   int64
+	Expires time.Time
+}
+
+// Record is the persisted state of a lock
+type Record struct {
+	Owner string `json:"owner"`
+	Token int64  `json:"token"`
+	// expiry as unix time in nanoseconds, zero if the lock was released
+	Expires int64 `json:"expires"`
+}
+
+// ToStruct converts json string to struct
+func (record Record) ToStruct(jsonString string) (interface{}, error) {
+	err := json.Unmarshal([]byte(jsonString), &record)
+	return record, err
+}
+
+// Locker grants leases on named locks. The locks are stored in a repository supporting
+// conditional writes, e.g. InMemoryRepo or DynamoDBRepo. Repositories which are decoding
+// values need to use Record as item template.
+type Locker struct {
+	repo        repository.KeyValueRepo
+	conditional repository.ConditionalRepo
+	owner       string
+	now         func() time.Time
+}
+
+// NewLocker creates a Locker for an owner. If the owner is empty a random id is used.
+// An error is returned if the repository does not support conditional writes.
+func NewLocker(repo repository.KeyValueRepo, owner string) (*Locker, error) {
+	conditional, ok := repo.(repository.ConditionalRepo)
+	if !ok {
+		return nil, fmt.Errorf("repository %T does not support conditional writes", repo)
+	}
+	if owner == "" {
+		owner = uuid.New().String()
+	}
+	return &Locker{
+		repo:        repo,
+		conditional: conditional,
+		owner:       owner,
+		now:         time.Now,
+	}, nil
+}
+
+// Owner returns the id used to acquire locks
+func (locker *Locker) Owner() string {
+	return locker.owner
+}
+
+// Acquire takes a lock for the lease duration. Locks which were released or whose lease
+// expired are taken over. ErrLocked is returned if the lock is held by another owner.
+func (locker *Locker) Acquire(name string, leaseDuration time.Duration) (Lease, error) {
+	expires := locker.now().Add(leaseDuration)
+	record := Record{
+		Owner:   locker.owner,
+		Token:   1,
+		Expires: expires.UnixNano(),
+	}
+	_, err := locker.repo.Save(name, record)
+	if err == nil {
+		return locker.toLease(name, record), nil
+	}
+	if !errors.Is(err, repository.ErrAlreadyExists) {
+		return Lease{}, err
+	}
+
+	item, current, err := locker.find(name)
+	if err != nil {
+		return Lease{}, err
+	}
+	if current.Expires > locker.now().UnixNano() {
+		return Lease{}, ErrLocked
+	}
+
+	record.Token = current.Token + 1
+	_, err = locker.conditional.CompareAndSwap(name, item.Value, record)
+	if err == repository.ErrConditionFailed {
+		return Lease{}, ErrLocked
+	}
+	if err != nil {
+		return Lease{}, err
+	}
+	return locker.toLease(name, record), nil
+}
+
+// Renew extends a lease by the lease duration starting from now. The token stays the same.
+// ErrLockLost is returned if the lock was taken over by another owner.
+func (locker *Locker) Renew(lease Lease, leaseDuration time.Duration) (Lease, error) {
+	record := Record{
+		Owner:   lease.Owner,
+		Token:   lease.Token,
+		Expires: locker.now().Add(leaseDuration).UnixNano(),
+	}
+	if err := locker.replaceOwned(lease, record); err != nil {
+		return Lease{}, err
+	}
+	return locker.toLease(lease.Name, record), nil
+}
+
+// Release frees a lock. The record of the lock is kept to ensure fencing tokens of
+// later acquisitions keep increasing.
+func (locker *Locker) Release(lease Lease) error {
+	return locker.replaceOwned(lease, Record{
+		Token: lease.Token,
+	})
+}
+
+// WithLock runs fn while holding the lock and releases it afterwards.
+// fn has to finish within the lease duration or renew the lease itself.
+func (locker *Locker) WithLock(name string, leaseDuration time.Duration, fn func(lease Lease) error) error {
+	lease, err := locker.Acquire(name, leaseDuration)
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn(lease)
+	releaseErr := locker.Release(lease)
+	if fnErr != nil {
+		return fnErr
+	}
+	return releaseErr
+}
+
+// replaceOwned overwrites the record of a lock if it is still held by the lease
+func (locker *Locker) replaceOwned(lease Lease, record Record) error {
+	item, current, err := locker.find(lease.Name)
+	if err != nil {
+		return err
+	}
+	if current.Owner != lease.Owner || current.Token != lease.Token {
+		return ErrLockLost
+	}
+
+	_, err = locker.conditional.CompareAndSwap(lease.Name, item.Value, record)
+	if err == repository.ErrConditionFailed {
+		return ErrLockLost
+	}
+	return err
+}
+
+func (locker *Locker) find(name string) (repository.KeyValuePair, Record, error) {
+	item, err := locker.repo.Find(name)
+	if err != nil {
+		return item, Record{}, err
+	}
+	record, err := toRecord(item.Value)
+	return item, record, err
+}
+
+func (locker *Locker) toLease(name string, record Record) Lease {
+	return Lease{
+		Name:    name,
+		Owner:   record.Owner,
+		Token:   record.Token,
+		Expires: time.Unix(0, record.Expires),
+	}
+}
+
+// toRecord converts stored values into a record independent of the decoding of the repository
+func toRecord(value interface{}) (Record, error) {
+	result := Record{}
+	err := repository.DecodeValue(value, &result)
+	return result, err
+}
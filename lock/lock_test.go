@@ -0,0 +1,199 @@
+package lock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+const testLock = "testLock"
+
+func TestNewLocker(t *testing.T) {
+	locker, err := NewLocker(repository.NewInMemoryRepo(), "")
+	checkError(err, t)
+
+	if locker.Owner() == "" {
+		t.Error("Expected a generated owner id")
+	}
+}
+
+func TestNewLockerUnsupportedRepo(t *testing.T) {
+	repo := struct{ repository.KeyValueRepo }{repository.NewInMemoryRepo()}
+
+	_, err := NewLocker(repo, "")
+
+	if err == nil {
+		t.Error("Expected error for repository without conditional writes")
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	locker := createLocker("a", repository.NewInMemoryRepo(), t)
+
+	lease, err := locker.Acquire(testLock, time.Minute)
+	checkError(err, t)
+
+	if lease.Owner != "a" || lease.Token != 1 || lease.Name != testLock {
+		t.Errorf("Unexpected lease %+v", lease)
+	}
+}
+
+func TestAcquireLocked(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	first := createLocker("a", repo, t)
+	second := createLocker("b", repo, t)
+
+	_, err := first.Acquire(testLock, time.Minute)
+	checkError(err, t)
+	_, err = second.Acquire(testLock, time.Minute)
+
+	if err != ErrLocked {
+		t.Errorf("Expected %v but found %v", ErrLocked, err)
+	}
+}
+
+// unavailableRepo fails all saves
+type unavailableRepo struct {
+	*repository.InMemoryRepo
+}
+
+func (repo *unavailableRepo) Save(key string, in interface{}) (repository.KeyValuePair, error) {
+	return repository.KeyValuePair{}, errors.New("unavailable")
+}
+
+func TestAcquireSaveError(t *testing.T) {
+	locker := createLocker("a", &unavailableRepo{repository.NewInMemoryRepo()}, t)
+
+	_, err := locker.Acquire(testLock, time.Minute)
+
+	if err == nil || err == ErrLocked {
+		t.Errorf("Expected the save error but found %v", err)
+	}
+}
+
+func TestAcquireExpired(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	first := createLocker("a", repo, t)
+	second := createLocker("b", repo, t)
+
+	_, err := first.Acquire(testLock, time.Minute)
+	checkError(err, t)
+	second.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	lease, err := second.Acquire(testLock, time.Minute)
+	checkError(err, t)
+
+	if lease.Owner != "b" || lease.Token != 2 {
+		t.Errorf("Expected lock to be stolen with token 2 but found %+v", lease)
+	}
+}
+
+func TestAcquireAfterRelease(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	first := createLocker("a", repo, t)
+	second := createLocker("b", repo, t)
+
+	lease, err := first.Acquire(testLock, time.Minute)
+	checkError(err, t)
+	err = first.Release(lease)
+	checkError(err, t)
+	lease, err = second.Acquire(testLock, time.Minute)
+	checkError(err, t)
+
+	if lease.Token != 2 {
+		t.Errorf("Expected token to increase after release but found %d", lease.Token)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	locker := createLocker("a", repository.NewInMemoryRepo(), t)
+
+	lease, err := locker.Acquire(testLock, time.Minute)
+	checkError(err, t)
+	renewed, err := locker.Renew(lease, time.Hour)
+	checkError(err, t)
+
+	if !renewed.Expires.After(lease.Expires) {
+		t.Errorf("Expected %v to be after %v", renewed.Expires, lease.Expires)
+	}
+	if renewed.Token != lease.Token {
+		t.Errorf("Expected token %d but found %d", lease.Token, renewed.Token)
+	}
+}
+
+func TestRenewLost(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	first := createLocker("a", repo, t)
+	second := createLocker("b", repo, t)
+
+	lease, err := first.Acquire(testLock, time.Minute)
+	checkError(err, t)
+	second.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err = second.Acquire(testLock, time.Minute)
+	checkError(err, t)
+
+	_, err = first.Renew(lease, time.Minute)
+	if err != ErrLockLost {
+		t.Errorf("Expected %v but found %v", ErrLockLost, err)
+	}
+	err = first.Release(lease)
+	if err != ErrLockLost {
+		t.Errorf("Expected %v but found %v", ErrLockLost, err)
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	repo := repository.NewInMemoryRepo()
+	first := createLocker("a", repo, t)
+	second := createLocker("b", repo, t)
+
+	err := first.WithLock(testLock, time.Minute, func(lease Lease) error {
+		_, err := second.Acquire(testLock, time.Minute)
+		if err != ErrLocked {
+			t.Errorf("Expected %v while lock is held but found %v", ErrLocked, err)
+		}
+		return nil
+	})
+	checkError(err, t)
+
+	_, err = second.Acquire(testLock, time.Minute)
+	checkError(err, t)
+}
+
+func TestWithLockError(t *testing.T) {
+	locker := createLocker("a", repository.NewInMemoryRepo(), t)
+	expected := errors.New("test error")
+
+	err := locker.WithLock(testLock, time.Minute, func(lease Lease) error {
+		return expected
+	})
+
+	if err != expected {
+		t.Errorf("Expected %v but found %v", expected, err)
+	}
+	_, err = locker.Acquire(testLock, time.Minute)
+	checkError(err, t)
+}
+
+func TestToRecordString(t *testing.T) {
+	record, err := toRecord(`{"owner":"a","token":3,"expires":5}`)
+	checkError(err, t)
+
+	expected := Record{Owner: "a", Token: 3, Expires: 5}
+	if record != expected {
+		t.Errorf("Expected %+v but found %+v", expected, record)
+	}
+}
+
+func createLocker(owner string, repo repository.KeyValueRepo, t *testing.T) *Locker {
+	locker, err := NewLocker(repo, owner)
+	checkError(err, t)
+	return locker
+}
+
+func checkError(err error, t *testing.T) {
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// isAWSErrorCode checks if an error returned by the sdk has a specific error code
+func isAWSErrorCode(err error, code string) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == code
+	}
+	return false
+}
+
+// markedError marks an sdk error as a repository error, e.g. repository.ErrNotFound,
+// while keeping its error code
+type markedError struct {
+	awsErr awserr.Error
+	target error
+}
+
+func (err markedError) Error() string {
+	return err.awsErr.Error()
+}
+
+func (err markedError) Code() string {
+	return err.awsErr.Code()
+}
+
+func (err markedError) Message() string {
+	return err.awsErr.Message()
+}
+
+func (err markedError) OrigErr() error {
+	return err.awsErr.OrigErr()
+}
+
+func (err markedError) Is(target error) bool {
+	return target == err.target
+}
+
+// markError marks errors with one of the given codes as the target repository error
+func markError(err error, target error, codes ...string) error {
+	if awsErr, ok := err.(awserr.Error); ok {
+		for _, code := range codes {
+			if awsErr.Code() == code {
+				return markedError{awsErr: awsErr, target: target}
+			}
+		}
+	}
+	return err
+}
+
+// toNotFoundError marks errors with one of the given codes as repository.ErrNotFound
+func toNotFoundError(err error, codes ...string) error {
+	return markError(err, repository.ErrNotFound, codes...)
+}
+
+// toAlreadyExistsError marks errors with one of the given codes as repository.ErrAlreadyExists
+func toAlreadyExistsError(err error, codes ...string) error {
+	return markError(err, repository.ErrAlreadyExists, codes...)
+}
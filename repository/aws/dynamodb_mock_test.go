@@ -0,0 +1,234 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDynamoDB simulates the table management and scans of DynamoDB. Tables become active
+// after pendingDescribes calls of DescribeTable. Scans return the scanItems of a segment
+// in pages of scanPageSize items, each item consumes one capacity unit.
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	tables           map[string]*dynamodb.TableDescription
+	ttl              map[string]*dynamodb.TimeToLiveDescription
+	tags             map[string]map[string]string
+	pendingDescribes int
+	creates          []*dynamodb.CreateTableInput
+	updates          []*dynamodb.UpdateTableInput
+
+	scanMutex      sync.Mutex
+	scanItems      []map[string]*dynamodb.AttributeValue
+	scanPageSize   int
+	scanInputs     []*dynamodb.ScanInput
+	activeScans    int
+	maxActiveScans int
+
+	// items by partition key, returned by GetItem, UpdateItem and DeleteItem
+	items       map[string]map[string]*dynamodb.AttributeValue
+	updateItems []*dynamodb.UpdateItemInput
+}
+
+func newMockDynamoDB() *mockDynamoDB {
+	return &mockDynamoDB{
+		tables: map[string]*dynamodb.TableDescription{},
+		ttl:    map[string]*dynamodb.TimeToLiveDescription{},
+		tags:   map[string]map[string]string{},
+		items:  map[string]map[string]*dynamodb.AttributeValue{},
+	}
+}
+
+// expressionPlaceholder matches attribute name and value placeholders of expressions
+var expressionPlaceholder = regexp.MustCompile(`[#:][A-Za-z0-9_]+`)
+
+// validatePlaceholders rejects expressions like DynamoDB if they use undefined placeholders
+// or if placeholders are defined but not used
+func validatePlaceholders(names map[string]*string, values map[string]*dynamodb.AttributeValue, expressions ...*string) error {
+	used := map[string]bool{}
+	for _, expression := range expressions {
+		for _, placeholder := range expressionPlaceholder.FindAllString(aws.StringValue(expression), -1) {
+			used[placeholder] = true
+			_, isName := names[placeholder]
+			_, isValue := values[placeholder]
+			if !isName && !isValue {
+				return awserr.New("ValidationException", fmt.Sprintf("undefined placeholder %s in %q", placeholder, aws.StringValue(expression)), nil)
+			}
+		}
+	}
+	for name := range names {
+		if !used[name] {
+			return awserr.New("ValidationException", fmt.Sprintf("unused attribute name %s", name), nil)
+		}
+	}
+	for value := range values {
+		if !used[value] {
+			return awserr.New("ValidationException", fmt.Sprintf("unused attribute value %s", value), nil)
+		}
+	}
+	return nil
+}
+
+func (mock *mockDynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: mock.items[aws.StringValue(input.Key[keyName].S)]}, nil
+}
+
+func (mock *mockDynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	key := aws.StringValue(input.Key[keyName].S)
+	item := mock.items[key]
+	delete(mock.items, key)
+	return &dynamodb.DeleteItemOutput{Attributes: item}, nil
+}
+
+// UpdateItem validates the expressions and returns the stored item without applying them
+func (mock *mockDynamoDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	mock.updateItems = append(mock.updateItems, input)
+	err := validatePlaceholders(input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.UpdateExpression, input.ConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.UpdateItemOutput{Attributes: mock.items[aws.StringValue(input.Key[keyName].S)]}, nil
+}
+
+func (mock *mockDynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	table, ok := mock.tables[*input.TableName]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "table not found", nil)
+	}
+	if mock.pendingDescribes > 0 {
+		mock.pendingDescribes--
+	} else {
+		table.TableStatus = aws.String(dynamodb.TableStatusActive)
+		for _, index := range table.GlobalSecondaryIndexes {
+			index.IndexStatus = aws.String(dynamodb.IndexStatusActive)
+		}
+	}
+	return &dynamodb.DescribeTableOutput{Table: table}, nil
+}
+
+func (mock *mockDynamoDB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	mock.creates = append(mock.creates, input)
+	table := &dynamodb.TableDescription{
+		TableName:           input.TableName,
+		TableArn:            aws.String("arn:aws:dynamodb:table/" + *input.TableName),
+		TableStatus:         aws.String(dynamodb.TableStatusCreating),
+		KeySchema:           input.KeySchema,
+		BillingModeSummary:  &dynamodb.BillingModeSummary{BillingMode: input.BillingMode},
+		StreamSpecification: input.StreamSpecification,
+	}
+	if input.ProvisionedThroughput != nil {
+		table.ProvisionedThroughput = &dynamodb.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  input.ProvisionedThroughput.ReadCapacityUnits,
+			WriteCapacityUnits: input.ProvisionedThroughput.WriteCapacityUnits,
+		}
+	}
+	for _, index := range input.GlobalSecondaryIndexes {
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   index.IndexName,
+			KeySchema:   index.KeySchema,
+			IndexStatus: aws.String(dynamodb.IndexStatusCreating),
+		})
+	}
+	mock.tables[*input.TableName] = table
+	return &dynamodb.CreateTableOutput{TableDescription: table}, nil
+}
+
+func (mock *mockDynamoDB) UpdateTable(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	mock.updates = append(mock.updates, input)
+	table := mock.tables[*input.TableName]
+	table.TableStatus = aws.String(dynamodb.TableStatusUpdating)
+	if input.BillingMode != nil {
+		table.BillingModeSummary = &dynamodb.BillingModeSummary{BillingMode: input.BillingMode}
+	}
+	if input.StreamSpecification != nil {
+		table.StreamSpecification = input.StreamSpecification
+	}
+	for _, update := range input.GlobalSecondaryIndexUpdates {
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   update.Create.IndexName,
+			KeySchema:   update.Create.KeySchema,
+			IndexStatus: aws.String(dynamodb.IndexStatusCreating),
+		})
+	}
+	return &dynamodb.UpdateTableOutput{TableDescription: table}, nil
+}
+
+func (mock *mockDynamoDB) DescribeTimeToLive(input *dynamodb.DescribeTimeToLiveInput) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	description, ok := mock.ttl[*input.TableName]
+	if !ok {
+		description = &dynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusDisabled)}
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: description}, nil
+}
+
+func (mock *mockDynamoDB) UpdateTimeToLive(input *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	mock.ttl[*input.TableName] = &dynamodb.TimeToLiveDescription{
+		AttributeName:    input.TimeToLiveSpecification.AttributeName,
+		TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusEnabled),
+	}
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: input.TimeToLiveSpecification}, nil
+}
+
+func (mock *mockDynamoDB) ListTagsOfResource(input *dynamodb.ListTagsOfResourceInput) (*dynamodb.ListTagsOfResourceOutput, error) {
+	return &dynamodb.ListTagsOfResourceOutput{Tags: sortedTags(mock.tags[*input.ResourceArn])}, nil
+}
+
+func (mock *mockDynamoDB) TagResource(input *dynamodb.TagResourceInput) (*dynamodb.TagResourceOutput, error) {
+	tags, ok := mock.tags[*input.ResourceArn]
+	if !ok {
+		tags = map[string]string{}
+		mock.tags[*input.ResourceArn] = tags
+	}
+	for _, tag := range input.Tags {
+		tags[*tag.Key] = *tag.Value
+	}
+	return &dynamodb.TagResourceOutput{}, nil
+}
+
+func (mock *mockDynamoDB) ScanPagesWithContext(ctx aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, options ...request.Option) error {
+	mock.scanMutex.Lock()
+	mock.scanInputs = append(mock.scanInputs, input)
+	mock.activeScans++
+	if mock.activeScans > mock.maxActiveScans {
+		mock.maxActiveScans = mock.activeScans
+	}
+	mock.scanMutex.Unlock()
+	defer func() {
+		mock.scanMutex.Lock()
+		mock.activeScans--
+		mock.scanMutex.Unlock()
+	}()
+
+	segment, total := aws.Int64Value(input.Segment), aws.Int64Value(input.TotalSegments)
+	items := []map[string]*dynamodb.AttributeValue{}
+	for i, item := range mock.scanItems {
+		if total == 0 || int64(i)%total == segment {
+			items = append(items, item)
+		}
+	}
+	for start := 0; start < len(items) || start == 0; start += mock.scanPageSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond) // allows other segments to run concurrently
+		end := start + mock.scanPageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		page := &dynamodb.ScanOutput{Items: items[start:end]}
+		if input.ReturnConsumedCapacity != nil {
+			page.ConsumedCapacity = &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(float64(end - start))}
+		}
+		if !fn(page, end == len(items)) || end == len(items) {
+			return nil
+		}
+	}
+	return nil
+}
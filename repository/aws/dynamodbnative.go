@@ -0,0 +1,522 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+// WithNativeAttributes stores the fields of a value as top-level attributes of the item
+// instead of a json string in the value attribute. Values are marshalled with
+// dynamodbattribute.MarshalMap, so the dynamodbav and json tags of structs apply.
+// This allows projections, filters and secondary indexes on the fields of the value.
+//
+// Items written as json strings are still read, MigrateToNativeAttributes converts them.
+// Fields must not use the names of the key attributes or of the attributes createdAt,
+// updatedAt, version, tags and value.
+func WithNativeAttributes() DynamoDBOption {
+	return func(repo *DynamoDBRepo) {
+		repo.nativeAttributes = true
+	}
+}
+
+// MigrateToNativeAttributes converts all items stored as json string into native attributes
+// and returns the number of converted items. An item is only converted if it was not changed
+// concurrently, so the migration can run while the table is in use and can be repeated.
+func (repo *DynamoDBRepo) MigrateToNativeAttributes() (int, error) {
+	if !repo.nativeAttributes {
+		return 0, fmt.Errorf("repository for table %s does not use native attributes", repo.tableName)
+	}
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	migrated := 0
+	var migrationErr error
+	err := repo.connection.ScanPages(&dynamodb.ScanInput{
+		TableName:      aws.String(repo.tableName),
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			converted, err := repo.migrateItem(item)
+			if err != nil {
+				migrationErr = err
+				return false
+			}
+			if converted {
+				migrated++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return migrated, err
+	}
+	return migrated, migrationErr
+}
+
+// migrateItem rewrites a single item if its value attribute is a json string
+func (repo *DynamoDBRepo) migrateItem(item map[string]*dynamodb.AttributeValue) (bool, error) {
+	stored, ok := item[valueName]
+	if !ok || stored.S == nil {
+		return false, nil
+	}
+	key, err := repo.keySchema.toKey(item)
+	if err != nil {
+		return false, err
+	}
+	value, err := repo.decodeValue(stored)
+	if err != nil {
+		return false, fmt.Errorf("could not decode item with key %s: %w", key, err)
+	}
+	set, remove, err := repo.encodeNative(value)
+	if err != nil {
+		return false, fmt.Errorf("could not encode item with key %s: %w", key, err)
+	}
+
+	_, err = repo.updateAttributes(key, set, remove, "#"+valueName+" = :expected",
+		map[string]*string{"#" + valueName: aws.String(valueName)},
+		map[string]*dynamodb.AttributeValue{":expected": stored})
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return false, nil // item was changed or deleted concurrently
+	}
+	return err == nil, err
+}
+
+// encodeNative marshals a value into the attributes to set. Attributes of fields which
+// are not part of the marshalled value, e.g. omitted empty fields, are returned to be removed.
+func (repo *DynamoDBRepo) encodeNative(in interface{}) (map[string]*dynamodb.AttributeValue, []string, error) {
+	set, err := dynamodbattribute.MarshalMap(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	reserved := repo.reservedAttributes()
+	for name := range set {
+		if reserved[name] {
+			return nil, nil, fmt.Errorf("field %s uses the name of a reserved attribute", name)
+		}
+	}
+
+	remove := []string{valueName}
+	for _, name := range fieldAttributeNames(reflect.TypeOf(in)) {
+		if _, ok := set[name]; !ok && !reserved[name] {
+			remove = append(remove, name)
+		}
+	}
+	return set, remove, nil
+}
+
+// decodeItem converts an item into a struct. Items which have a value attribute are
+// stored as json string, all others as native attributes.
+func (repo *DynamoDBRepo) decodeItem(item map[string]*dynamodb.AttributeValue) (interface{}, error) {
+	if value, ok := item[valueName]; ok || !repo.nativeAttributes {
+		return repo.decodeValue(value)
+	}
+	serialized, err := repo.nativeJSON(item)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toStructFunction(serialized)
+}
+
+// nativeJSON converts the native attributes of an item into a json string. Key and metadata
+// attributes are not part of the result.
+func (repo *DynamoDBRepo) nativeJSON(item map[string]*dynamodb.AttributeValue) (string, error) {
+	reserved := repo.reservedAttributes()
+	attributes := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, attribute := range item {
+		if !reserved[name] {
+			attributes[name] = attribute
+		}
+	}
+
+	var decoded interface{}
+	decoder := dynamodbattribute.NewDecoder(func(decoder *dynamodbattribute.Decoder) {
+		decoder.UseNumber = true
+	})
+	err := decoder.Decode(&dynamodb.AttributeValue{M: attributes}, &decoded)
+	if err != nil {
+		return "", err
+	}
+	serialized, err := json.Marshal(toJSONCompatible(decoded))
+	return string(serialized), err
+}
+
+// toJSONCompatible replaces the numbers of decoded attributes by json numbers, which
+// are marshalled without quotes
+func toJSONCompatible(decoded interface{}) interface{} {
+	switch value := decoded.(type) {
+	case map[string]interface{}:
+		for name, nested := range value {
+			value[name] = toJSONCompatible(nested)
+		}
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = toJSONCompatible(nested)
+		}
+	case dynamodbattribute.Number:
+		return json.Number(value)
+	case []dynamodbattribute.Number:
+		numbers := make([]json.Number, len(value))
+		for i, number := range value {
+			numbers[i] = json.Number(number)
+		}
+		return numbers
+	}
+	return decoded
+}
+
+// reservedAttributes are the attributes which are not part of a native value
+func (repo *DynamoDBRepo) reservedAttributes() map[string]bool {
+	reserved := map[string]bool{
+		valueName:     true,
+		createdAtName: true,
+		updatedAtName: true,
+		versionName:   true,
+		tagsName:      true,
+	}
+	reserved[repo.keySchema.PartitionKey] = true
+	if repo.keySchema.SortKey != "" {
+		reserved[repo.keySchema.SortKey] = true
+	}
+	return reserved
+}
+
+// fieldAttributeNames lists the attribute names of all exported fields of a struct type the
+// way dynamodbattribute names them. Fields of embedded structs without name are flattened.
+func fieldAttributeNames(structType reflect.Type) []string {
+	for structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return nil
+	}
+
+	names := []string{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, tagged := fieldTagName(field)
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && !tagged {
+			names = append(names, fieldAttributeNames(field.Type)...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// fieldTagName returns the name of a field set by its dynamodbav or json tag
+func fieldTagName(field reflect.StructField) (string, bool) {
+	for _, tagName := range []string{"dynamodbav", "json"} {
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+		return name, name != ""
+	}
+	return "", false
+}
+
+// sortedAttributeNames returns the names of attributes in a stable order
+func sortedAttributeNames(attributes map[string]*dynamodb.AttributeValue) []string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errRewriteRequired is returned by patchNative if a patch can not be applied on the server
+var errRewriteRequired = errors.New("patch requires to rewrite the item")
+
+// patchNative applies a JSON merge patch to an item stored as native attributes with a single
+// update on the server. Members of the patch address attributes by their names, null removes
+// an attribute and nested objects are merged into map attributes. errRewriteRequired is
+// returned if the item is missing or still stored as json string, if the patch is not an
+// object or if a nested object of the patch does not target a map attribute.
+func (repo *DynamoDBRepo) patchNative(key string, mergePatch []byte) (repository.KeyValuePair, error) {
+	decoder := json.NewDecoder(bytes.NewReader(mergePatch))
+	decoder.UseNumber()
+	var patch interface{}
+	if err := decoder.Decode(&patch); err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return getEmptyKeyValuePair(), errRewriteRequired
+	}
+	reserved := repo.reservedAttributes()
+	for name := range members {
+		if reserved[name] {
+			return getEmptyKeyValuePair(), fmt.Errorf("field %s uses the name of a reserved attribute", name)
+		}
+	}
+
+	update := &patchUpdate{}
+	if err := update.add(nil, members); err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	names := map[string]*string{
+		"#" + keyName:   aws.String(repo.keySchema.PartitionKey),
+		"#" + valueName: aws.String(valueName),
+	}
+	var values map[string]*dynamodb.AttributeValue
+	conditions := []string{"attribute_exists(#" + keyName + ")", "attribute_not_exists(#" + valueName + ")"}
+	for i, path := range update.maps {
+		conditions = append(conditions, "attribute_type("+path.expression("#m"+strconv.Itoa(i), names)+", :map)")
+	}
+	if len(update.maps) > 0 {
+		values = map[string]*dynamodb.AttributeValue{":map": {S: aws.String("M")}}
+	}
+
+	attributes, err := repo.updatePaths(key, update.set, update.remove, strings.Join(conditions, " AND "), names, values)
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return getEmptyKeyValuePair(), errRewriteRequired
+	}
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	value, err := repo.decodeItem(attributes)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	return repository.KeyValuePair{
+		Key:      key,
+		Value:    value,
+		Metadata: toItemMetadata(attributes),
+	}, nil
+}
+
+// patchUpdate collects the attribute paths a merge patch sets and removes. Paths of
+// nested objects are collected in maps as they have to be map attributes.
+type patchUpdate struct {
+	set    []pathValue
+	remove []attributePath
+	maps   []attributePath
+}
+
+// add collects the paths of the members of a patch object below the given path
+func (update *patchUpdate) add(path attributePath, members map[string]interface{}) error {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		memberPath := append(append(attributePath{}, path...), name)
+		switch member := members[name].(type) {
+		case nil:
+			update.remove = append(update.remove, memberPath)
+		case map[string]interface{}:
+			update.maps = append(update.maps, memberPath)
+			if err := update.add(memberPath, member); err != nil {
+				return err
+			}
+		default:
+			attribute, err := dynamodbattribute.Marshal(toAttributeCompatible(member))
+			if err != nil {
+				return err
+			}
+			update.set = append(update.set, pathValue{path: memberPath, value: attribute})
+		}
+	}
+	return nil
+}
+
+// toAttributeCompatible replaces the json numbers of a decoded patch by attribute numbers,
+// which keep their precision when marshalled
+func toAttributeCompatible(decoded interface{}) interface{} {
+	switch value := decoded.(type) {
+	case map[string]interface{}:
+		for name, nested := range value {
+			value[name] = toAttributeCompatible(nested)
+		}
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = toAttributeCompatible(nested)
+		}
+	case json.Number:
+		return dynamodbattribute.Number(value)
+	}
+	return decoded
+}
+
+// rewriteNative applies a JSON merge patch to a read item and stores the patched value as
+// native attributes. The patched value is only written if the version of the item was not
+// changed in the meantime.
+func (repo *DynamoDBRepo) rewriteNative(key string, item map[string]*dynamodb.AttributeValue, mergePatch []byte) (repository.KeyValuePair, error) {
+	current, err := repo.itemJSON(item)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	patched, err := serialization.MergePatch([]byte(current), mergePatch)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	value, err := repo.toStructFunction(string(patched))
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+
+	attributes, err := repo.putIfVersion(key, item, value)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	return repository.KeyValuePair{
+		Key:      key,
+		Value:    value,
+		Metadata: toItemMetadata(attributes),
+	}, nil
+}
+
+// itemJSON returns the value of an item as json string regardless of its storage mode
+func (repo *DynamoDBRepo) itemJSON(item map[string]*dynamodb.AttributeValue) (string, error) {
+	if value, ok := item[valueName]; ok {
+		current := ""
+		err := dynamodbattribute.Unmarshal(value, &current)
+		return current, err
+	}
+	return repo.nativeJSON(item)
+}
+
+// sameNativeValue checks if the value of an item equals the expected value once stored.
+// Items which are still stored as json string are compared with the serialized value.
+func (repo *DynamoDBRepo) sameNativeValue(item map[string]*dynamodb.AttributeValue, expected interface{}) (bool, error) {
+	if value, ok := item[valueName]; ok {
+		current := ""
+		if err := dynamodbattribute.Unmarshal(value, &current); err != nil {
+			return false, err
+		}
+		serializedExpected, err := serialization.ToJSON(expected)
+		if err != nil {
+			return false, err
+		}
+		return normalizeJSON(current) == normalizeJSON(serializedExpected), nil
+	}
+
+	current, err := repo.nativeJSON(item)
+	if err != nil {
+		return false, err
+	}
+	encoded, err := dynamodbattribute.MarshalMap(expected)
+	if err != nil {
+		return false, err
+	}
+	serializedExpected, err := repo.nativeJSON(encoded)
+	if err != nil {
+		return false, err
+	}
+	return normalizeJSON(current) == normalizeJSON(serializedExpected), nil
+}
+
+// normalizeJSON orders the keys of json objects, invalid json is returned as is
+func normalizeJSON(serialized string) string {
+	decoder := json.NewDecoder(strings.NewReader(serialized))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return serialized
+	}
+	normalized, err := json.Marshal(decoded)
+	if err != nil {
+		return serialized
+	}
+	return string(normalized)
+}
+
+// putIfVersion stores a value as native attributes if the item was not changed since it was read
+func (repo *DynamoDBRepo) putIfVersion(key string, item map[string]*dynamodb.AttributeValue, in interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	set, remove, err := repo.encodeNative(in)
+	if err != nil {
+		return nil, err
+	}
+	condition, names, values := versionCondition(repo.keySchema, item)
+	attributes, err := repo.updateAttributes(key, set, remove, condition, names, values)
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil, repository.ErrConditionFailed
+	}
+	return attributes, err
+}
+
+// versionCondition creates a condition expression which only matches the item if its
+// version equals the version of the read item
+func versionCondition(schema KeySchema, item map[string]*dynamodb.AttributeValue) (string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	names := map[string]*string{
+		"#" + keyName:     aws.String(schema.PartitionKey),
+		"#" + versionName: aws.String(versionName),
+	}
+	version, ok := item[versionName]
+	if !ok {
+		return "attribute_exists(#" + keyName + ") AND attribute_not_exists(#" + versionName + ")", names, nil
+	}
+	return "attribute_exists(#" + keyName + ") AND #" + versionName + " = :expectedVersion", names,
+		map[string]*dynamodb.AttributeValue{":expectedVersion": version}
+}
+
+// compareAndWriteNative reads an item and overwrites or deletes it if its value equals the
+// expected value and it was not changed in the meantime. A nil value deletes the item.
+func (repo *DynamoDBRepo) compareAndWriteNative(key string, expected interface{}, in interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	keyAttributes, err := repo.keySchema.toAttributes(key)
+	if err != nil {
+		return nil, err
+	}
+	result, err := repo.connection.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(repo.tableName),
+		Key:            keyAttributes,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, repository.ErrConditionFailed
+	}
+	same, err := repo.sameNativeValue(result.Item, expected)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, repository.ErrConditionFailed
+	}
+
+	if in != nil {
+		return repo.putIfVersion(key, result.Item, in)
+	}
+	condition, names, values := versionCondition(repo.keySchema, result.Item)
+	input := &dynamodb.DeleteItemInput{
+		Key:                      keyAttributes,
+		TableName:                aws.String(repo.tableName),
+		ConditionExpression:      aws.String(condition),
+		ExpressionAttributeNames: names,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+	_, err = repo.connection.DeleteItem(input)
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil, repository.ErrConditionFailed
+	}
+	return nil, err
+}
@@ -0,0 +1,145 @@
+package aws
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+type nativeItem struct {
+	Name     string            `json:"name"`
+	Count    int64             `json:"count"`
+	Ratio    float64           `json:"ratio"`
+	Labels   []string          `json:"labels,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+	Ignored  string            `json:"-"`
+	nativeEmbedded
+}
+
+type nativeEmbedded struct {
+	Owner string `dynamodbav:"owner" json:"ownerName"`
+}
+
+func (item nativeItem) ToStruct(jsonString string) (interface{}, error) {
+	err := json.Unmarshal([]byte(jsonString), &item)
+	return item, err
+}
+
+func createNativeRepo() *DynamoDBRepo {
+	return &DynamoDBRepo{
+		toStructFunction: nativeItem{}.ToStruct,
+		keySchema:        defaultKeySchema(),
+		nativeAttributes: true,
+	}
+}
+
+func Test_Native_Round_Trip(t *testing.T) {
+	repo := createNativeRepo()
+	expected := nativeItem{
+		Name:     "name",
+		Count:    9007199254740993,
+		Ratio:    0.5,
+		Labels:   []string{"a", "b"},
+		Settings: map[string]string{"mode": "fast"},
+	}
+
+	set, _, err := repo.encodeNative(expected)
+	checkError(err, t)
+	set[keyName] = &dynamodb.AttributeValue{S: aws.String("key")}
+	set[versionName] = &dynamodb.AttributeValue{N: aws.String("1")}
+	actual, err := repo.decodeItem(set)
+	checkError(err, t)
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, actual)
+	}
+}
+
+func Test_Native_Removes_Omitted_Fields(t *testing.T) {
+	repo := createNativeRepo()
+
+	set, remove, err := repo.encodeNative(nativeItem{Name: "name"})
+	checkError(err, t)
+
+	expected := []string{valueName, "labels", "settings"}
+	if !reflect.DeepEqual(remove, expected) {
+		t.Errorf("Expected %v to be removed but found %v", expected, remove)
+	}
+	if _, ok := set["owner"]; !ok {
+		t.Errorf("Expected field of embedded struct in %+v", set)
+	}
+}
+
+func Test_Native_Reserved_Attribute(t *testing.T) {
+	repo := createNativeRepo()
+
+	_, _, err := repo.encodeNative(map[string]string{versionName: "1"})
+
+	checkFailure(err, t)
+}
+
+func Test_Native_Reads_String_Items(t *testing.T) {
+	repo := createNativeRepo()
+	item := map[string]*dynamodb.AttributeValue{
+		keyName:   {S: aws.String("key")},
+		valueName: {S: aws.String(`{"name":"stored"}`)},
+	}
+
+	actual, err := repo.decodeItem(item)
+	checkError(err, t)
+
+	if actual.(nativeItem).Name != "stored" {
+		t.Errorf("Expected value of json string but found %+v", actual)
+	}
+}
+
+func Test_Native_Same_Value(t *testing.T) {
+	repo := createNativeRepo()
+	native, _, err := repo.encodeNative(nativeItem{Name: "a", Count: 1})
+	checkError(err, t)
+	items := []map[string]*dynamodb.AttributeValue{
+		{valueName: {S: aws.String(`{"ratio":0,"count":1,"name":"a","ownerName":""}`)}},
+		native,
+	}
+
+	for _, stored := range items {
+		same, err := repo.sameNativeValue(stored, nativeItem{Name: "a", Count: 1})
+		checkError(err, t)
+		different, err := repo.sameNativeValue(stored, nativeItem{Name: "b", Count: 1})
+		checkError(err, t)
+
+		if !same || different {
+			t.Errorf("Expected only equal values of %+v to match but found %v and %v", stored, same, different)
+		}
+	}
+}
+
+func Test_Field_Attribute_Names(t *testing.T) {
+	actual := fieldAttributeNames(reflect.TypeOf(&serialization.NestedMockItem{}))
+
+	if !reflect.DeepEqual(actual, []string{"NestedItem"}) {
+		t.Errorf("Expected field name but found %v", actual)
+	}
+}
+
+func Test_Native_Patch_Paths(t *testing.T) {
+	update := &patchUpdate{}
+
+	err := update.add(nil, map[string]interface{}{
+		"count":    json.Number("9007199254740993"),
+		"settings": map[string]interface{}{"mode": nil},
+	})
+
+	checkError(err, t)
+	if len(update.set) != 1 || aws.StringValue(update.set[0].value.N) != "9007199254740993" {
+		t.Errorf("Expected count to be set as exact number but found %+v", update.set)
+	}
+	if !reflect.DeepEqual(update.remove, []attributePath{{"settings", "mode"}}) ||
+		!reflect.DeepEqual(update.maps, []attributePath{{"settings"}}) {
+		t.Errorf("Expected nested removal in map settings but found %+v and %+v", update.remove, update.maps)
+	}
+}
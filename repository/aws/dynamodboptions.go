@@ -0,0 +1,206 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// defaultKeySeparator separates the partition and sort key in keys of tables with a sort key
+const defaultKeySeparator = "|"
+
+// KeySchema describes the primary key of a table. If a sort key is set, keys of the repository
+// consist of the partition key and the sort key joined by the separator, e.g. "user1|item1".
+// The partition key must not contain the separator.
+type KeySchema struct {
+	PartitionKey string
+	// optional name of the sort key attribute
+	SortKey string
+	// defaults to "|"
+	Separator string
+}
+
+// DynamoDBOption configures a DynamoDBRepo
+type DynamoDBOption func(*DynamoDBRepo)
+
+// WithKeySchema sets the primary key of the table. By default the table has the
+// partition key "key" and no sort key.
+func WithKeySchema(schema KeySchema) DynamoDBOption {
+	return func(repo *DynamoDBRepo) {
+		if schema.Separator == "" {
+			schema.Separator = defaultKeySeparator
+		}
+		repo.keySchema = schema
+	}
+}
+
+// WithConsistentRead uses strongly consistent reads for Find, FindAll and Query
+func WithConsistentRead() DynamoDBOption {
+	return func(repo *DynamoDBRepo) {
+		repo.consistentRead = true
+	}
+}
+
+func defaultKeySchema() KeySchema {
+	return KeySchema{
+		PartitionKey: keyName,
+		Separator:    defaultKeySeparator,
+	}
+}
+
+// toAttributes splits a key of the repository into the key attributes of the table
+func (schema KeySchema) toAttributes(key string) (map[string]*dynamodb.AttributeValue, error) {
+	if schema.SortKey == "" {
+		return map[string]*dynamodb.AttributeValue{
+			schema.PartitionKey: {S: aws.String(key)},
+		}, nil
+	}
+
+	index := strings.Index(key, schema.Separator)
+	if index <= 0 || index+len(schema.Separator) == len(key) {
+		return nil, repository.InvalidKeyError(key, fmt.Sprintf("key has to consist of partition and sort key separated by %s", schema.Separator))
+	}
+	return map[string]*dynamodb.AttributeValue{
+		schema.PartitionKey: {S: aws.String(key[:index])},
+		schema.SortKey:      {S: aws.String(key[index+len(schema.Separator):])},
+	}, nil
+}
+
+// toKey joins the key attributes of an item into a key of the repository
+func (schema KeySchema) toKey(item map[string]*dynamodb.AttributeValue) (string, error) {
+	partition, ok := item[schema.PartitionKey]
+	if !ok || partition.S == nil {
+		return "", fmt.Errorf("item has no partition key %s", schema.PartitionKey)
+	}
+	if schema.SortKey == "" {
+		return *partition.S, nil
+	}
+	sort, ok := item[schema.SortKey]
+	if !ok || sort.S == nil {
+		return "", fmt.Errorf("item has no sort key %s", schema.SortKey)
+	}
+	return *partition.S + schema.Separator + *sort.S, nil
+}
+
+// attributeDefinitions and keySchemaElements describe the schema when creating a table
+func (schema KeySchema) attributeDefinitions() []*dynamodb.AttributeDefinition {
+	definitions := []*dynamodb.AttributeDefinition{
+		{
+			AttributeName: aws.String(schema.PartitionKey),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+		},
+	}
+	if schema.SortKey != "" {
+		definitions = append(definitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(schema.SortKey),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+		})
+	}
+	return definitions
+}
+
+func (schema KeySchema) keySchemaElements() []*dynamodb.KeySchemaElement {
+	elements := []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String(schema.PartitionKey),
+			KeyType:       aws.String(dynamodb.KeyTypeHash),
+		},
+	}
+	if schema.SortKey != "" {
+		elements = append(elements, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(schema.SortKey),
+			KeyType:       aws.String(dynamodb.KeyTypeRange),
+		})
+	}
+	return elements
+}
+
+// SortKeyCondition restricts the items returned by Query by their sort key
+type SortKeyCondition struct {
+	// uses #sortKey as name and :sortKey0 and :sortKey1 as values
+	expression string
+	values     []string
+}
+
+// SortKeyEquals matches the sort key equal to the value
+func SortKeyEquals(value string) SortKeyCondition {
+	return sortKeyComparison("=", value)
+}
+
+// SortKeyLessThan matches sort keys less than the value
+func SortKeyLessThan(value string) SortKeyCondition {
+	return sortKeyComparison("<", value)
+}
+
+// SortKeyLessOrEqual matches sort keys less than or equal to the value
+func SortKeyLessOrEqual(value string) SortKeyCondition {
+	return sortKeyComparison("<=", value)
+}
+
+// SortKeyGreaterThan matches sort keys greater than the value
+func SortKeyGreaterThan(value string) SortKeyCondition {
+	return sortKeyComparison(">", value)
+}
+
+// SortKeyGreaterOrEqual matches sort keys greater than or equal to the value
+func SortKeyGreaterOrEqual(value string) SortKeyCondition {
+	return sortKeyComparison(">=", value)
+}
+
+// SortKeyBetween matches sort keys between the lower and upper value, both inclusive
+func SortKeyBetween(lower string, upper string) SortKeyCondition {
+	return SortKeyCondition{
+		expression: "#sortKey BETWEEN :sortKey0 AND :sortKey1",
+		values:     []string{lower, upper},
+	}
+}
+
+// SortKeyBeginsWith matches sort keys starting with the prefix
+func SortKeyBeginsWith(prefix string) SortKeyCondition {
+	return SortKeyCondition{
+		expression: "begins_with(#sortKey, :sortKey0)",
+		values:     []string{prefix},
+	}
+}
+
+func sortKeyComparison(operator string, value string) SortKeyCondition {
+	return SortKeyCondition{
+		expression: "#sortKey " + operator + " :sortKey0",
+		values:     []string{value},
+	}
+}
+
+// toQueryInput creates the input to query a partition, the conditions on the sort key are optional
+func (schema KeySchema) toQueryInput(tableName string, partitionKey string, conditions []SortKeyCondition) (*dynamodb.QueryInput, error) {
+	if len(conditions) > 1 {
+		return nil, fmt.Errorf("at most one sort key condition is supported but found %d", len(conditions))
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("#partitionKey = :partitionKey"),
+		ExpressionAttributeNames: map[string]*string{
+			"#partitionKey": aws.String(schema.PartitionKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":partitionKey": {S: aws.String(partitionKey)},
+		},
+	}
+	if len(conditions) == 0 {
+		return input, nil
+	}
+
+	if schema.SortKey == "" {
+		return nil, fmt.Errorf("table has no sort key")
+	}
+	condition := conditions[0]
+	input.KeyConditionExpression = aws.String(*input.KeyConditionExpression + " AND " + condition.expression)
+	input.ExpressionAttributeNames["#sortKey"] = aws.String(schema.SortKey)
+	for i, value := range condition.values {
+		input.ExpressionAttributeValues[fmt.Sprintf(":sortKey%d", i)] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	return input, nil
+}
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+var compositeKeySchema = KeySchema{PartitionKey: "user", SortKey: "item", Separator: "|"}
+
+func Test_KeySchema_Round_Trip(t *testing.T) {
+	for _, key := range []string{"user1|item1", "user1|item|with|separator"} {
+		attributes, err := compositeKeySchema.toAttributes(key)
+		checkError(err, t)
+		actual, err := compositeKeySchema.toKey(attributes)
+		checkError(err, t)
+
+		if actual != key {
+			t.Errorf("Expected %s but found %s", key, actual)
+		}
+	}
+}
+
+func Test_KeySchema_Partition_Only(t *testing.T) {
+	schema := defaultKeySchema()
+
+	attributes, err := schema.toAttributes("a|b")
+	checkError(err, t)
+
+	if len(attributes) != 1 || *attributes[keyName].S != "a|b" {
+		t.Errorf("Expected key to be stored as is but found %+v", attributes)
+	}
+}
+
+func Test_KeySchema_Invalid_Keys(t *testing.T) {
+	for _, key := range []string{"user1", "|item1", "user1|"} {
+		_, err := compositeKeySchema.toAttributes(key)
+
+		if !errors.Is(err, repository.ErrInvalidKey) {
+			t.Errorf("Expected %v for %s but found %v", repository.ErrInvalidKey, key, err)
+		}
+	}
+}
+
+func Test_KeySchema_Missing_Sort_Key(t *testing.T) {
+	_, err := compositeKeySchema.toKey(map[string]*dynamodb.AttributeValue{
+		"user": {S: aws.String("user1")},
+	})
+
+	checkFailure(err, t)
+}
+
+func Test_KeySchema_Table_Definition(t *testing.T) {
+	elements := compositeKeySchema.keySchemaElements()
+
+	if len(elements) != 2 || *elements[1].AttributeName != "item" || *elements[1].KeyType != dynamodb.KeyTypeRange {
+		t.Errorf("Expected sort key in key schema but found %+v", elements)
+	}
+	if len(compositeKeySchema.attributeDefinitions()) != 2 {
+		t.Errorf("Expected 2 attribute definitions")
+	}
+}
+
+func Test_Query_Input(t *testing.T) {
+	input, err := compositeKeySchema.toQueryInput(testTableName, "user1", []SortKeyCondition{SortKeyBetween("a", "c")})
+	checkError(err, t)
+
+	expected := "#partitionKey = :partitionKey AND #sortKey BETWEEN :sortKey0 AND :sortKey1"
+	if *input.KeyConditionExpression != expected {
+		t.Errorf("Expected %s but found %s", expected, *input.KeyConditionExpression)
+	}
+	if *input.ExpressionAttributeValues[":sortKey1"].S != "c" || *input.ExpressionAttributeNames["#sortKey"] != "item" {
+		t.Errorf("Unexpected expression attributes %+v", input)
+	}
+}
+
+func Test_Query_Input_Without_Sort_Key(t *testing.T) {
+	_, err := defaultKeySchema().toQueryInput(testTableName, "key", []SortKeyCondition{SortKeyBeginsWith("a")})
+
+	checkFailure(err, t)
+}
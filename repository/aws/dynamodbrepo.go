@@ -1,14 +1,17 @@
 package aws
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/jo-hoe/serverless-toolbox/repository"
 	"github.com/jo-hoe/serverless-toolbox/serialization"
@@ -17,12 +20,28 @@ import (
 const keyName = "key"
 const valueName = "value"
 
+// names of the metadata attributes
+const createdAtName = "createdAt"
+const updatedAtName = "updatedAt"
+const versionName = "version"
+const tagsName = "tags"
+
+// setMetadataExpression and addVersionExpression are parts of update expressions which maintain
+// the metadata of an item. They require the names and values of the metadata expression functions.
+const setMetadataExpression = "#" + updatedAtName + " = :now, #" + createdAtName + " = if_not_exists(#" + createdAtName + ", :now)"
+const addVersionExpression = "#" + versionName + " :one"
+
 // DynamoDBRepo stores all entities dynamo db
 type DynamoDBRepo struct {
 	mutex            sync.RWMutex
 	tableName        string
-	connection       *dynamodb.DynamoDB
+	connection       dynamodbiface.DynamoDBAPI
 	toStructFunction func(jsonString string) (interface{}, error)
+	keySchema        KeySchema
+	consistentRead   bool
+	nativeAttributes bool
+	tableSpec        TableSpec
+	scan             scanSettings
 }
 
 // GetConnection takes a configuration, creates a session and returns a connection
@@ -32,24 +51,12 @@ func GetConnection(config *aws.Config) *dynamodb.DynamoDB {
 	return dynamodb.New(session)
 }
 
-// NewStoreItemDynamoDBRepo creates a DynamoDBRepo and checks if the table exists. If not it will be created.
-func NewStoreItemDynamoDBRepo(config *aws.Config, tableName string, itemTemplate serialization.Serializable) *DynamoDBRepo {
-	connection := GetConnection(config)
-	exists, _ := doesTableExist(connection, tableName)
-	if !exists {
-		err := createTable(connection, tableName)
-		if err != nil {
-			log.Fatalf("Table %s could not be created.", tableName)
-		}
-	}
-	return &DynamoDBRepo{
-		tableName:        tableName,
-		connection:       connection,
-		toStructFunction: itemTemplate.ToStruct,
-	}
+// NewStoreItemDynamoDBRepo creates a DynamoDBRepo and ensures the table exists. If not it will be created.
+func NewStoreItemDynamoDBRepo(config *aws.Config, tableName string, itemTemplate serialization.Serializable, options ...DynamoDBOption) (*DynamoDBRepo, error) {
+	return NewDynamoDBRepo(config, tableName, itemTemplate.ToStruct, options...)
 }
 
-// NewDynamoDBRepo creates a DynamoDBRepo and checks if the table exists. If not it will be created.
+// NewDynamoDBRepo creates a DynamoDBRepo and ensures the table exists. If not it will be created.
 // the toStruct function allowed the internal unmarshalling
 //
 // Example:
@@ -63,20 +70,36 @@ func NewStoreItemDynamoDBRepo(config *aws.Config, tableName string, itemTemplate
 //	err := json.Unmarshal([]byte(jsonString), &person)
 //	return person, err
 // }
-func NewDynamoDBRepo(config *aws.Config, tableName string, toStruct func(jsonString string) (interface{}, error)) *DynamoDBRepo {
-	connection := GetConnection(config)
-	exists, _ := doesTableExist(connection, tableName)
-	if !exists {
-		err := createTable(connection, tableName)
-		if err != nil {
-			log.Fatalf("Table %s could not be created.", tableName)
-		}
+//
+// Options allow to configure the key schema and settings of the table, consistent reads and
+// the storage of values as native attributes.
+func NewDynamoDBRepo(config *aws.Config, tableName string, toStruct func(jsonString string) (interface{}, error), options ...DynamoDBOption) (*DynamoDBRepo, error) {
+	session, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
 	}
-	return &DynamoDBRepo{
+	return NewDynamoDBRepoWithConnection(dynamodb.New(session), tableName, toStruct, options...)
+}
+
+// NewDynamoDBRepoWithConnection creates a DynamoDBRepo using an existing connection and ensures
+// the table exists. The table is created or updated according to the table spec and an error
+// is returned if it does not become active in time.
+func NewDynamoDBRepoWithConnection(connection dynamodbiface.DynamoDBAPI, tableName string, toStruct func(jsonString string) (interface{}, error), options ...DynamoDBOption) (*DynamoDBRepo, error) {
+	repo := &DynamoDBRepo{
 		tableName:        tableName,
 		connection:       connection,
 		toStructFunction: toStruct,
+		keySchema:        defaultKeySchema(),
+	}
+	for _, option := range options {
+		option(repo)
 	}
+
+	err := EnsureTable(repo.connection, tableName, repo.keySchema, repo.tableSpec)
+	if err != nil {
+		return nil, fmt.Errorf("table %s could not be ensured: %w", tableName, err)
+	}
+	return repo, nil
 }
 
 // Save one item
@@ -93,75 +116,209 @@ func (repo *DynamoDBRepo) save(key string, in interface{}, overwrite bool) (repo
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
-	// converting item to storeable item
-	serialized, err := serialization.ToJSON(in)
-	if err != nil {
-		return getEmptyKeyValuePair(), err
+	condition := ""
+	names := map[string]*string{}
+	if !overwrite {
+		condition = "attribute_not_exists(#" + keyName + ")"
+		names["#"+keyName] = aws.String(repo.keySchema.PartitionKey)
+	}
+
+	var attributes map[string]*dynamodb.AttributeValue
+	if repo.nativeAttributes {
+		set, remove, err := repo.encodeNative(in)
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+		attributes, err = repo.updateAttributes(key, set, remove, condition, names, nil)
+		if err != nil {
+			return getEmptyKeyValuePair(), toAlreadyExistsError(err, dynamodb.ErrCodeConditionalCheckFailedException)
+		}
+	} else {
+		// converting item to storeable item
+		serialized, err := serialization.ToJSON(in)
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+		attributes, err = repo.updateValue(key, &dynamodb.AttributeValue{S: aws.String(serialized)}, condition, names, nil)
+		if err != nil {
+			return getEmptyKeyValuePair(), toAlreadyExistsError(err, dynamodb.ErrCodeConditionalCheckFailedException)
+		}
 	}
-	keyValuePair := repository.KeyValuePair{
-		Key:   key,
-		Value: serialized,
+
+	return repository.KeyValuePair{
+		Key:      key,
+		Value:    in,
+		Metadata: toItemMetadata(attributes),
+	}, nil
+}
+
+// updateValue sets the value attribute of an item and maintains its metadata attributes.
+// An optional condition expression can be passed with its attribute names and values.
+// All attributes of the updated item are returned.
+func (repo *DynamoDBRepo) updateValue(key string, value *dynamodb.AttributeValue, condition string,
+	names map[string]*string, values map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	return repo.updateAttributes(key, map[string]*dynamodb.AttributeValue{valueName: value}, nil, condition, names, values)
+}
+
+// updateAttributes sets and removes attributes of an item and maintains its metadata attributes.
+// Attribute names are passed via placeholders, so they may contain any character.
+func (repo *DynamoDBRepo) updateAttributes(key string, set map[string]*dynamodb.AttributeValue, remove []string, condition string,
+	names map[string]*string, values map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	setPaths := make([]pathValue, 0, len(set))
+	for _, name := range sortedAttributeNames(set) {
+		setPaths = append(setPaths, pathValue{path: attributePath{name}, value: set[name]})
+	}
+	removePaths := make([]attributePath, 0, len(remove))
+	for _, name := range remove {
+		removePaths = append(removePaths, attributePath{name})
 	}
+	return repo.updatePaths(key, setPaths, removePaths, condition, names, values)
+}
+
+// attributePath addresses a top-level or nested attribute by the names of its segments
+type attributePath []string
+
+// expression returns the document path of the attribute. The segment names are defined
+// in names using the given placeholder prefix.
+func (path attributePath) expression(prefix string, names map[string]*string) string {
+	segments := make([]string, len(path))
+	for i, name := range path {
+		placeholder := prefix
+		if len(path) > 1 {
+			placeholder += "_" + strconv.Itoa(i)
+		}
+		names[placeholder] = aws.String(name)
+		segments[i] = placeholder
+	}
+	return strings.Join(segments, ".")
+}
 
-	av, err := dynamodbattribute.MarshalMap(keyValuePair)
+// pathValue is the value to set for an attribute path
+type pathValue struct {
+	path  attributePath
+	value *dynamodb.AttributeValue
+}
+
+// updatePaths sets and removes top-level or nested attributes of an item and maintains its
+// metadata attributes. All attributes of the updated item are returned.
+func (repo *DynamoDBRepo) updatePaths(key string, set []pathValue, remove []attributePath, condition string,
+	names map[string]*string, values map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	keyAttributes, err := repo.keySchema.toAttributes(key)
 	if err != nil {
-		return getEmptyKeyValuePair(), err
+		return nil, err
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(repo.tableName),
+		Key:                       keyAttributes,
+		ExpressionAttributeNames:  metadataExpressionNames(),
+		ExpressionAttributeValues: metadataExpressionValues(),
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
 	}
 
-	input := dynamodb.PutItemInput{}
-	if overwrite {
-		input = dynamodb.PutItemInput{
-			Item:      av,
-			TableName: aws.String(repo.tableName),
-		}
+	assignments := make([]string, 0, len(set)+1)
+	for _, attribute := range set {
+		placeholder := "a" + strconv.Itoa(len(assignments))
+		path := attribute.path.expression("#"+placeholder, input.ExpressionAttributeNames)
+		input.ExpressionAttributeValues[":"+placeholder] = attribute.value
+		assignments = append(assignments, path+" = :"+placeholder)
+	}
+	assignments = append(assignments, setMetadataExpression)
+	expression := "SET " + strings.Join(assignments, ", ")
 
-	} else {
-		input = dynamodb.PutItemInput{
-			Item:      av,
-			TableName: aws.String(repo.tableName),
-			ExpressionAttributeNames: map[string]*string{
-				"#" + keyName: aws.String(keyName),
-			},
-			ConditionExpression: aws.String("attribute_not_exists(#" + keyName + ")"),
-		}
+	removals := make([]string, 0, len(remove))
+	for i, path := range remove {
+		removals = append(removals, path.expression("#r"+strconv.Itoa(i), input.ExpressionAttributeNames))
+	}
+	if len(removals) > 0 {
+		expression += " REMOVE " + strings.Join(removals, ", ")
+	}
+	input.UpdateExpression = aws.String(expression + " ADD " + addVersionExpression)
+
+	if condition != "" {
+		input.ConditionExpression = aws.String(condition)
+	}
+	for name, attribute := range names {
+		input.ExpressionAttributeNames[name] = attribute
+	}
+	for name, attribute := range values {
+		input.ExpressionAttributeValues[name] = attribute
 	}
-	_, err = repo.connection.PutItem(&input)
 
+	output, err := repo.connection.UpdateItem(input)
 	if err != nil {
-		return getEmptyKeyValuePair(), err
+		return nil, err
 	}
-	keyValuePair.Value = in
-	return keyValuePair, nil
+	return output.Attributes, nil
 }
 
-// FindAll items
+// FindAll items. The table is scanned page by page, in parallel segments if configured
+// with WithParallelScan.
 func (repo *DynamoDBRepo) FindAll() ([]repository.KeyValuePair, error) {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
-	params := &dynamodb.ScanInput{
-		TableName: aws.String(repo.tableName),
-	}
 
-	result, err := repo.connection.Scan(params)
+	segments := make([][]repository.KeyValuePair, repo.scan.totalSegments())
+	err := repo.scanSegments(context.Background(), func(segment int, items []map[string]*dynamodb.AttributeValue) error {
+		keyValuePairs, err := repo.toKeyValuePairs(items)
+		if err != nil {
+			return err
+		}
+		// each segment is scanned by a single worker
+		segments[segment] = append(segments[segment], keyValuePairs...)
+		return nil
+	})
 	if err != nil {
 		return []repository.KeyValuePair{}, err
 	}
 
-	items := []repository.KeyValuePair{}
+	result := []repository.KeyValuePair{}
+	for _, keyValuePairs := range segments {
+		result = append(result, keyValuePairs...)
+	}
+	return result, nil
+}
+
+// Query returns all items of a partition. For tables with a sort key, the items are ordered by
+// the sort key and can be restricted by a sort key condition.
+func (repo *DynamoDBRepo) Query(partitionKey string, conditions ...SortKeyCondition) ([]repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
 
-	// Unmarshal the Items field in the result value to the Item Go type.
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &items)
+	input, err := repo.keySchema.toQueryInput(repo.tableName, partitionKey, conditions)
 	if err != nil {
 		return []repository.KeyValuePair{}, err
 	}
+	input.ConsistentRead = aws.Bool(repo.consistentRead)
 
-	// convert string into struct
-	for i, item := range items {
-		result, _ := repo.toStructFunction(item.Value.(string))
-		items[i].Value = result
+	items := []map[string]*dynamodb.AttributeValue{}
+	err = repo.connection.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return []repository.KeyValuePair{}, err
 	}
+	return repo.toKeyValuePairs(items)
+}
+
+// toKeyValuePairs converts items of the table. Values which can not be decoded are returned as nil.
+func (repo *DynamoDBRepo) toKeyValuePairs(items []map[string]*dynamodb.AttributeValue) ([]repository.KeyValuePair, error) {
+	result := []repository.KeyValuePair{}
 
-	return items, nil
+	for _, item := range items {
+		keyValuePair := repository.KeyValuePair{}
+		key, err := repo.keySchema.toKey(item)
+		if err != nil {
+			return []repository.KeyValuePair{}, err
+		}
+		keyValuePair.Key = key
+		// convert string into struct
+		keyValuePair.Value, _ = repo.decodeItem(item)
+		keyValuePair.Metadata = toItemMetadata(item)
+		result = append(result, keyValuePair)
+	}
+
+	return result, nil
 }
 
 // Delete an item from the repository
@@ -169,12 +326,12 @@ func (repo *DynamoDBRepo) Delete(key string) error {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
+	keyAttributes, err := repo.keySchema.toAttributes(key)
+	if err != nil {
+		return err
+	}
 	input := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			keyName: {
-				S: aws.String(key),
-			},
-		},
+		Key:          keyAttributes,
 		TableName:    aws.String(repo.tableName),
 		ReturnValues: aws.String("ALL_OLD"),
 	}
@@ -183,7 +340,7 @@ func (repo *DynamoDBRepo) Delete(key string) error {
 		return err
 	}
 	if item.Attributes == nil {
-		return fmt.Errorf("could not find item with key %s", key)
+		return fmt.Errorf("could not find item with key %s: %w", key, repository.ErrNotFound)
 	}
 	return nil
 }
@@ -193,78 +350,316 @@ func (repo *DynamoDBRepo) Find(key string) (repository.KeyValuePair, error) {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
+	keyAttributes, err := repo.keySchema.toAttributes(key)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
 	result, err := repo.connection.GetItem(&dynamodb.GetItemInput{
-		TableName: aws.String(repo.tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			keyName: {
-				S: aws.String(key),
-			},
-		},
+		TableName:      aws.String(repo.tableName),
+		Key:            keyAttributes,
+		ConsistentRead: aws.Bool(repo.consistentRead),
 	})
 
-	if result.Item == nil && err == nil {
-		err = fmt.Errorf("could not find item with key %s", key)
+	if err == nil && result.Item == nil {
+		err = fmt.Errorf("could not find item with key %s: %w", key, repository.ErrNotFound)
 	}
 	if err != nil {
 		return getEmptyKeyValuePair(), err
 	}
 
 	keyValuePair := repository.KeyValuePair{}
-	err = dynamodbattribute.UnmarshalMap(result.Item, &keyValuePair)
+	keyValuePair.Key, err = repo.keySchema.toKey(result.Item)
 	if err != nil {
 		return getEmptyKeyValuePair(), err
 	}
-	stringValue := ""
-	err = dynamodbattribute.Unmarshal(result.Item[valueName], &stringValue)
+	storeItem, err := repo.decodeItem(result.Item)
 	if err != nil {
 		return getEmptyKeyValuePair(), err
 	}
-	storeItem, _ := repo.toStructFunction(stringValue)
 	keyValuePair.Value = storeItem
-	return keyValuePair, err
+	keyValuePair.Metadata = toItemMetadata(result.Item)
+	return keyValuePair, nil
 }
 
-func doesTableExist(connection *dynamodb.DynamoDB, tableName string) (bool, error) {
-	input := &dynamodb.ListTablesInput{}
-	result, err := connection.ListTables(input)
+// Increment atomically adds delta to the numeric value of a key using an update
+// expression. Missing items are created with delta as initial value.
+func (repo *DynamoDBRepo) Increment(key string, delta int64) (int64, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	keyAttributes, err := repo.keySchema.toAttributes(key)
 	if err != nil {
-		return false, err
+		return 0, err
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(repo.tableName),
+		Key:                       keyAttributes,
+		ExpressionAttributeNames:  metadataExpressionNames(),
+		ExpressionAttributeValues: metadataExpressionValues(),
+		UpdateExpression:          aws.String("SET " + setMetadataExpression + " ADD #" + valueName + " :delta, " + addVersionExpression),
+		ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+	input.ExpressionAttributeNames["#"+valueName] = aws.String(valueName)
+	input.ExpressionAttributeValues[":delta"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(delta, 10)),
 	}
 
-	for _, name := range result.TableNames {
-		if *name == tableName {
-			return true, nil
+	output, err := repo.connection.UpdateItem(input)
+	if err != nil {
+		return 0, err
+	}
+
+	result := int64(0)
+	err = dynamodbattribute.Unmarshal(output.Attributes[valueName], &result)
+	return result, err
+}
+
+// maxPatchAttempts limits the retries of Patch if the item was modified concurrently
+const maxPatchAttempts = 10
+
+// Patch applies a JSON merge patch to the value of an existing item. The patched value
+// is only written if the stored value was not changed in the meantime, otherwise the
+// patch is retried on the latest value. With WithNativeAttributes the patch is applied
+// on the server by a single update, see patchNative.
+func (repo *DynamoDBRepo) Patch(key string, mergePatch []byte) (repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if repo.nativeAttributes {
+		keyValuePair, err := repo.patchNative(key, mergePatch)
+		if err != errRewriteRequired {
+			return keyValuePair, err
+		}
+	}
+	keyAttributes, err := repo.keySchema.toAttributes(key)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		result, err := repo.connection.GetItem(&dynamodb.GetItemInput{
+			TableName:      aws.String(repo.tableName),
+			Key:            keyAttributes,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+		if result.Item == nil {
+			return getEmptyKeyValuePair(), fmt.Errorf("could not find item with key %s: %w", key, repository.ErrNotFound)
+		}
+		if repo.nativeAttributes {
+			keyValuePair, err := repo.rewriteNative(key, result.Item, mergePatch)
+			if err == repository.ErrConditionFailed {
+				continue // item was modified concurrently
+			}
+			return keyValuePair, err
+		}
+
+		current := ""
+		err = dynamodbattribute.Unmarshal(result.Item[valueName], &current)
+		if err != nil {
+			return getEmptyKeyValuePair(), err
 		}
+		patched, err := serialization.MergePatch([]byte(current), mergePatch)
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+
+		attributes, err := repo.putIfValue(key, current, string(patched))
+		if err == repository.ErrConditionFailed {
+			continue // item was modified concurrently
+		}
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+
+		value, err := repo.toStructFunction(string(patched))
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+		return repository.KeyValuePair{
+			Key:      key,
+			Value:    value,
+			Metadata: toItemMetadata(attributes),
+		}, nil
 	}
 
-	return false, nil
+	return getEmptyKeyValuePair(), fmt.Errorf("could not patch key %s after %d attempts", key, maxPatchAttempts)
+}
+
+// CompareAndSwap overwrites an item if its stored serialized value equals the serialized
+// expected value
+func (repo *DynamoDBRepo) CompareAndSwap(key string, expected interface{}, in interface{}) (repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if repo.nativeAttributes {
+		attributes, err := repo.compareAndWriteNative(key, expected, in)
+		if err != nil {
+			return getEmptyKeyValuePair(), err
+		}
+		return repository.KeyValuePair{
+			Key:      key,
+			Value:    in,
+			Metadata: toItemMetadata(attributes),
+		}, nil
+	}
+
+	serializedExpected, err := serialization.ToJSON(expected)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	serialized, err := serialization.ToJSON(in)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+
+	attributes, err := repo.putIfValue(key, serializedExpected, serialized)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	return repository.KeyValuePair{
+		Key:      key,
+		Value:    in,
+		Metadata: toItemMetadata(attributes),
+	}, nil
+}
+
+// CompareAndDelete deletes an item if its stored serialized value equals the serialized
+// expected value
+func (repo *DynamoDBRepo) CompareAndDelete(key string, expected interface{}) error {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if repo.nativeAttributes {
+		_, err := repo.compareAndWriteNative(key, expected, nil)
+		return err
+	}
+
+	serializedExpected, err := serialization.ToJSON(expected)
+	if err != nil {
+		return err
+	}
+	keyAttributes, err := repo.keySchema.toAttributes(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.connection.DeleteItem(&dynamodb.DeleteItemInput{
+		Key:       keyAttributes,
+		TableName: aws.String(repo.tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#" + valueName: aws.String(valueName),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":expected": {
+				S: aws.String(serializedExpected),
+			},
+		},
+		ConditionExpression: aws.String("#" + valueName + " = :expected"),
+	})
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return repository.ErrConditionFailed
+	}
+	return err
 }
 
-func createTable(connection *dynamodb.DynamoDB, tableName string) error {
-	input := &dynamodb.CreateTableInput{
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{
-			{
-				AttributeName: aws.String(keyName),
-				AttributeType: aws.String("S"),
+// putIfValue stores a serialized value if the currently stored value equals expected and
+// returns all attributes of the updated item
+func (repo *DynamoDBRepo) putIfValue(key string, expected string, serialized string) (map[string]*dynamodb.AttributeValue, error) {
+	attributes, err := repo.updateValue(key, &dynamodb.AttributeValue{S: aws.String(serialized)},
+		"#"+valueName+" = :expected", map[string]*string{"#" + valueName: aws.String(valueName)}, map[string]*dynamodb.AttributeValue{
+			":expected": {
+				S: aws.String(expected),
 			},
+		})
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return nil, repository.ErrConditionFailed
+	}
+	return attributes, err
+}
+
+// Tag adds tags to an existing item. The tags are stored in a map attribute.
+func (repo *DynamoDBRepo) Tag(key string, tags map[string]string) error {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	keyAttributes, err := repo.keySchema.toAttributes(key)
+	if err != nil {
+		return err
+	}
+
+	// nested attributes can only be set if the map attribute exists
+	_, err = repo.connection.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(repo.tableName),
+		Key:       keyAttributes,
+		ExpressionAttributeNames: map[string]*string{
+			"#" + keyName:  aws.String(repo.keySchema.PartitionKey),
+			"#" + tagsName: aws.String(tagsName),
 		},
-		KeySchema: []*dynamodb.KeySchemaElement{
-			{
-				AttributeName: aws.String(keyName),
-				KeyType:       aws.String("HASH"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":empty": {
+				M: map[string]*dynamodb.AttributeValue{},
 			},
 		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(10),
-			WriteCapacityUnits: aws.Int64(10),
+		ConditionExpression: aws.String("attribute_exists(#" + keyName + ")"),
+		UpdateExpression:    aws.String("SET #" + tagsName + " = if_not_exists(#" + tagsName + ", :empty)"),
+	})
+	if isAWSErrorCode(err, dynamodb.ErrCodeConditionalCheckFailedException) {
+		return fmt.Errorf("could not find item with key %s: %w", key, repository.ErrNotFound)
+	}
+	if err != nil || len(tags) == 0 {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(repo.tableName),
+		Key:       keyAttributes,
+		ExpressionAttributeNames: map[string]*string{
+			"#" + tagsName: aws.String(tagsName),
 		},
-		TableName: aws.String(tableName),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+	}
+	assignments := make([]string, 0, len(tags))
+	for name, value := range tags {
+		placeholder := strconv.Itoa(len(assignments))
+		input.ExpressionAttributeNames["#tag"+placeholder] = aws.String(name)
+		input.ExpressionAttributeValues[":tag"+placeholder] = &dynamodb.AttributeValue{S: aws.String(value)}
+		assignments = append(assignments, "#"+tagsName+".#tag"+placeholder+" = :tag"+placeholder)
 	}
+	input.UpdateExpression = aws.String("SET " + strings.Join(assignments, ", "))
 
-	_, err := connection.CreateTable(input)
+	_, err = repo.connection.UpdateItem(input)
 	return err
 }
 
+// decodeValue converts a stored value attribute into a struct. Values are either
+// serialized json strings or numbers maintained by Increment.
+func (repo *DynamoDBRepo) decodeValue(value *dynamodb.AttributeValue) (interface{}, error) {
+	stringValue := ""
+	err := dynamodbattribute.Unmarshal(value, &stringValue)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toStructFunction(stringValue)
+}
+
+func doesTableExist(connection *dynamodb.DynamoDB, tableName string) (bool, error) {
+	input := &dynamodb.ListTablesInput{}
+	result, err := connection.ListTables(input)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range result.TableNames {
+		if *name == tableName {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func dropTable(connection *dynamodb.DynamoDB, tableName string) error {
 	input := &dynamodb.DeleteTableInput{
 		TableName: aws.String(tableName),
@@ -288,6 +683,47 @@ func isConnected(connection *dynamodb.DynamoDB) bool {
 	}
 }
 
+func metadataExpressionNames() map[string]*string {
+	return map[string]*string{
+		"#" + createdAtName: aws.String(createdAtName),
+		"#" + updatedAtName: aws.String(updatedAtName),
+		"#" + versionName:   aws.String(versionName),
+	}
+}
+
+func metadataExpressionValues() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		":now": {
+			S: aws.String(time.Now().UTC().Format(time.RFC3339Nano)),
+		},
+		":one": {
+			N: aws.String("1"),
+		},
+	}
+}
+
+// toItemMetadata reads the metadata attributes of an item. Attributes which are missing,
+// e.g. for items written by older versions, are left empty.
+func toItemMetadata(item map[string]*dynamodb.AttributeValue) *repository.Metadata {
+	metadata := repository.Metadata{}
+	if attribute, ok := item[createdAtName]; ok {
+		_ = dynamodbattribute.Unmarshal(attribute, &metadata.CreatedAt)
+	}
+	if attribute, ok := item[updatedAtName]; ok {
+		_ = dynamodbattribute.Unmarshal(attribute, &metadata.UpdatedAt)
+	}
+	if attribute, ok := item[versionName]; ok {
+		_ = dynamodbattribute.Unmarshal(attribute, &metadata.Version)
+	}
+	if attribute, ok := item[tagsName]; ok {
+		_ = dynamodbattribute.Unmarshal(attribute, &metadata.Tags)
+		if len(metadata.Tags) == 0 {
+			metadata.Tags = nil
+		}
+	}
+	return &metadata
+}
+
 func getEmptyKeyValuePair() repository.KeyValuePair {
 	return repository.KeyValuePair{
 		Key:   "",
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -28,9 +29,9 @@ var nestedMockedItem = serialization.NestedMockItem{
 }
 
 func TestMain(m *testing.M) {
-	m.Run()
+	code := m.Run()
 	cleanup()
-	os.Exit(0)
+	os.Exit(code)
 }
 
 func cleanup() {
@@ -52,7 +53,8 @@ func TestNewDynamoDBRepo(t *testing.T) {
 	defer cleanup()
 	skipTestIfNoConnectionAvaiable(t)
 	mockItem := serialization.MockItem{}
-	repo := NewDynamoDBRepo(defaultConfig, testTableName, mockItem.ToStruct)
+	repo, err := NewDynamoDBRepo(defaultConfig, testTableName, mockItem.ToStruct)
+	checkError(err, t)
 
 	if repo == nil {
 		t.Errorf("Repo was not initialzed")
@@ -81,7 +83,7 @@ func TestDynamoDBSave(t *testing.T) {
 		Key:   testKey,
 		Value: mockedItem,
 	}
-	if !reflect.DeepEqual(expected, actual) {
+	if expected.Key != actual.Key || !reflect.DeepEqual(expected.Value, actual.Value) {
 		t.Errorf("Expected %+v but found %+v. Error: %v", expected, actual, err)
 	}
 }
@@ -265,14 +267,297 @@ func TestDynamoDBFindInvalid(t *testing.T) {
 	}
 }
 
+func TestDynamoDBIncrement(t *testing.T) {
+	defer cleanup()
+	skipTestIfNoConnectionAvaiable(t)
+	repo, err := NewDynamoDBRepo(defaultConfig, testTableName, func(jsonString string) (interface{}, error) {
+		return jsonString, nil
+	})
+	checkError(err, t)
+	testKey := getRandomKey()
+
+	_, err = repo.Increment(testKey, 2)
+	checkError(err, t)
+	value, err := repo.Increment(testKey, 3)
+	checkError(err, t)
+
+	if value != 5 {
+		t.Errorf("Expected 5 but retrieved %d", value)
+	}
+	item, err := repo.Find(testKey)
+	checkError(err, t)
+	if item.Value != "5" {
+		t.Errorf("Expected 5 but retrieved %v", item.Value)
+	}
+}
+
+func TestDynamoDBPatch(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionNestedMockItems(t)
+	testKey := getRandomKey()
+	_, err := repo.Save(testKey, nestedMockedItem)
+	checkError(err, t)
+
+	result, err := repo.Patch(testKey, []byte(`{"NestedItem":{"MockString":"patched"}}`))
+	checkError(err, t)
+
+	expected := serialization.NestedMockItem{
+		NestedItem: serialization.MockItem{MockString: "patched"},
+	}
+	if !reflect.DeepEqual(result.Value, expected) {
+		t.Errorf("Expected %+v but retrieved %+v", expected, result.Value)
+	}
+	stored, err := repo.Find(testKey)
+	if !reflect.DeepEqual(stored.Value, expected) {
+		t.Errorf("Expected %+v but retrieved %+v. Error: %v", expected, stored.Value, err)
+	}
+}
+
+func TestDynamoDBPatchMissing(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionMockItems(t)
+
+	_, err := repo.Patch(getRandomKey(), []byte(`{"MockString":"patched"}`))
+
+	if err == nil {
+		t.Errorf("Error is nil although Patch was called with an invalid key")
+	}
+}
+
+func TestDynamoDBCompareAndSwap(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionMockItems(t)
+	testKey := getRandomKey()
+	_, err := repo.Save(testKey, mockedItem)
+	checkError(err, t)
+	updatedItem := serialization.MockItem{MockString: "updated"}
+
+	_, err = repo.CompareAndSwap(testKey, updatedItem, updatedItem)
+	if err != repository.ErrConditionFailed {
+		t.Errorf("Expected %v but found %v", repository.ErrConditionFailed, err)
+	}
+	_, err = repo.CompareAndSwap(testKey, mockedItem, updatedItem)
+	checkError(err, t)
+
+	result, err := repo.Find(testKey)
+	if !reflect.DeepEqual(result.Value, updatedItem) {
+		t.Errorf("Expected %+v but retrieved %+v. Error: %v", updatedItem, result.Value, err)
+	}
+}
+
+func TestDynamoDBCompareAndDelete(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionMockItems(t)
+	testKey := getRandomKey()
+	_, err := repo.Save(testKey, mockedItem)
+	checkError(err, t)
+
+	err = repo.CompareAndDelete(testKey, serialization.MockItem{MockString: "other"})
+	if err != repository.ErrConditionFailed {
+		t.Errorf("Expected %v but found %v", repository.ErrConditionFailed, err)
+	}
+	err = repo.CompareAndDelete(testKey, mockedItem)
+	checkError(err, t)
+
+	_, err = repo.Find(testKey)
+	checkFailure(err, t)
+}
+
+func TestDynamoDBMetadata(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionMockItems(t)
+	testKey := getRandomKey()
+
+	saved, err := repo.Save(testKey, mockedItem)
+	checkError(err, t)
+	_, err = repo.Overwrite(testKey, mockedItem)
+	checkError(err, t)
+	err = repo.Tag(testKey, map[string]string{"owner": "me"})
+	checkError(err, t)
+
+	result, err := repo.Find(testKey)
+	checkError(err, t)
+	if result.Metadata.Version != 2 {
+		t.Errorf("Expected version 2 but found %d", result.Metadata.Version)
+	}
+	if !result.Metadata.CreatedAt.Equal(saved.Metadata.CreatedAt) {
+		t.Errorf("Expected creation date %v but found %v", saved.Metadata.CreatedAt, result.Metadata.CreatedAt)
+	}
+	if result.Metadata.Tags["owner"] != "me" {
+		t.Errorf("Expected tag owner but found %+v", result.Metadata.Tags)
+	}
+}
+
+func TestDynamoDBTagMissing(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionMockItems(t)
+
+	err := repo.Tag(getRandomKey(), map[string]string{"owner": "me"})
+
+	checkFailure(err, t)
+}
+
+func TestDynamoDBCompositeKey(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionCompositeKey(t)
+
+	_, err := repo.Save("user1|item1", mockedItem)
+	checkError(err, t)
+	item, err := repo.Find("user1|item1")
+	checkError(err, t)
+
+	if item.Key != "user1|item1" {
+		t.Errorf("Expected key user1|item1 but found %s", item.Key)
+	}
+}
+
+func TestDynamoDBCompositeKeyInvalid(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionCompositeKey(t)
+
+	_, err := repo.Save("user1", mockedItem)
+
+	if !errors.Is(err, repository.ErrInvalidKey) {
+		t.Errorf("Expected %v but found %v", repository.ErrInvalidKey, err)
+	}
+}
+
+func TestDynamoDBQuery(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionCompositeKey(t)
+	for _, key := range []string{"user1|a", "user1|b", "user1|c", "user2|a"} {
+		_, err := repo.Save(key, mockedItem)
+		checkError(err, t)
+	}
+
+	all, err := repo.Query("user1")
+	checkError(err, t)
+	greater, err := repo.Query("user1", SortKeyGreaterThan("a"))
+	checkError(err, t)
+	between, err := repo.Query("user1", SortKeyBetween("a", "b"))
+	checkError(err, t)
+
+	if len(all) != 3 || all[0].Key != "user1|a" {
+		t.Errorf("Expected 3 ordered items but found %+v", all)
+	}
+	if len(greater) != 2 {
+		t.Errorf("Expected 2 items but found %+v", greater)
+	}
+	if len(between) != 2 {
+		t.Errorf("Expected 2 items but found %+v", between)
+	}
+}
+
+func TestDynamoDBNativeAttributes(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionNativeAttributes(t)
+	testKey := getRandomKey()
+
+	_, err := repo.Save(testKey, mockedItem)
+	checkError(err, t)
+	result, err := repo.connection.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(testTableName),
+		Key:       map[string]*dynamodb.AttributeValue{keyName: {S: aws.String(testKey)}},
+	})
+	checkError(err, t)
+	actual, err := repo.Find(testKey)
+	checkError(err, t)
+
+	if _, ok := result.Item["MockString"]; !ok {
+		t.Errorf("Expected native attribute but found %+v", result.Item)
+	}
+	if !reflect.DeepEqual(actual.Value, mockedItem) {
+		t.Errorf("Expected %+v but retrieved %+v", mockedItem, actual.Value)
+	}
+}
+
+func TestDynamoDBNativePatch(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionNativeAttributes(t)
+	testKey := getRandomKey()
+	_, err := repo.Save(testKey, mockedItem)
+	checkError(err, t)
+
+	_, err = repo.Patch(testKey, []byte(`{"MockString":"patched"}`))
+	checkError(err, t)
+
+	expected := serialization.MockItem{MockString: "patched"}
+	stored, err := repo.Find(testKey)
+	if !reflect.DeepEqual(stored.Value, expected) {
+		t.Errorf("Expected %+v but retrieved %+v. Error: %v", expected, stored.Value, err)
+	}
+}
+
+func TestDynamoDBNativeCompareAndSwap(t *testing.T) {
+	defer cleanup()
+	repo := createLocalConnectionNativeAttributes(t)
+	testKey := getRandomKey()
+	_, err := repo.Save(testKey, mockedItem)
+	checkError(err, t)
+	updatedItem := serialization.MockItem{MockString: "updated"}
+
+	_, err = repo.CompareAndSwap(testKey, updatedItem, updatedItem)
+	if err != repository.ErrConditionFailed {
+		t.Errorf("Expected %v but found %v", repository.ErrConditionFailed, err)
+	}
+	_, err = repo.CompareAndSwap(testKey, mockedItem, updatedItem)
+	checkError(err, t)
+	err = repo.CompareAndDelete(testKey, updatedItem)
+	checkError(err, t)
+
+	_, err = repo.Find(testKey)
+	checkFailure(err, t)
+}
+
+func TestDynamoDBMigrateToNativeAttributes(t *testing.T) {
+	defer cleanup()
+	stringRepo := createLocalConnectionMockItems(t)
+	testKey := getRandomKey()
+	_, err := stringRepo.Save(testKey, mockedItem)
+	checkError(err, t)
+	repo := createLocalConnectionNativeAttributes(t)
+
+	migrated, err := repo.MigrateToNativeAttributes()
+	checkError(err, t)
+	again, err := repo.MigrateToNativeAttributes()
+	checkError(err, t)
+
+	if migrated != 1 || again != 0 {
+		t.Errorf("Expected 1 and 0 migrated items but found %d and %d", migrated, again)
+	}
+	actual, err := repo.Find(testKey)
+	if !reflect.DeepEqual(actual.Value, mockedItem) {
+		t.Errorf("Expected %+v but retrieved %+v. Error: %v", mockedItem, actual.Value, err)
+	}
+}
+
+func createLocalConnectionCompositeKey(t *testing.T) *DynamoDBRepo {
+	skipTestIfNoConnectionAvaiable(t)
+	repo, err := NewStoreItemDynamoDBRepo(defaultConfig, testTableName, serialization.MockItem{},
+		WithKeySchema(KeySchema{PartitionKey: "user", SortKey: "item"}), WithConsistentRead())
+	checkError(err, t)
+	return repo
+}
+
+func createLocalConnectionNativeAttributes(t *testing.T) *DynamoDBRepo {
+	skipTestIfNoConnectionAvaiable(t)
+	repo, err := NewStoreItemDynamoDBRepo(defaultConfig, testTableName, serialization.MockItem{}, WithNativeAttributes())
+	checkError(err, t)
+	return repo
+}
+
 func createLocalConnectionMockItems(t *testing.T) *DynamoDBRepo {
 	skipTestIfNoConnectionAvaiable(t)
-	return NewStoreItemDynamoDBRepo(defaultConfig, testTableName, serialization.MockItem{})
+	repo, err := NewStoreItemDynamoDBRepo(defaultConfig, testTableName, serialization.MockItem{})
+	checkError(err, t)
+	return repo
 }
 
 func createLocalConnectionNestedMockItems(t *testing.T) *DynamoDBRepo {
 	skipTestIfNoConnectionAvaiable(t)
-	return NewStoreItemDynamoDBRepo(defaultConfig, testTableName, serialization.NestedMockItem{})
+	repo, err := NewStoreItemDynamoDBRepo(defaultConfig, testTableName, serialization.NestedMockItem{})
+	checkError(err, t)
+	return repo
 }
 
 func skipTestIfNoConnectionAvaiable(t *testing.T) {
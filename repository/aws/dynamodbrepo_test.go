@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+func createMockedRepo(t *testing.T) (*DynamoDBRepo, *mockDynamoDB) {
+	mock := newMockDynamoDB()
+	repo, err := NewDynamoDBRepoWithConnection(mock, testTableName, mockedItem.ToStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.items["key"] = map[string]*dynamodb.AttributeValue{
+		keyName:     {S: aws.String("key")},
+		valueName:   {S: aws.String(`{"MockString":"old"}`)},
+		versionName: {N: aws.String("1")},
+	}
+	return repo, mock
+}
+
+func Test_Save_Expression(t *testing.T) {
+	repo, _ := createMockedRepo(t)
+
+	_, err := repo.Save("new", mockedItem)
+	checkError(err, t)
+	_, err = repo.Overwrite("key", mockedItem)
+	checkError(err, t)
+}
+
+func Test_Compare_And_Swap_Expression(t *testing.T) {
+	repo, mock := createMockedRepo(t)
+
+	result, err := repo.CompareAndSwap("key", mockedItem, mockedItem)
+
+	checkError(err, t)
+	if len(mock.updateItems) != 1 || mock.updateItems[0].ConditionExpression == nil {
+		t.Errorf("Expected conditional update but found %+v", mock.updateItems)
+	}
+	if result.Metadata == nil || result.Metadata.Version != 1 {
+		t.Errorf("Expected metadata of the updated item but found %+v", result.Metadata)
+	}
+}
+
+func Test_Patch_Expression(t *testing.T) {
+	repo, _ := createMockedRepo(t)
+
+	result, err := repo.Patch("key", []byte(`{"MockString":"new"}`))
+
+	checkError(err, t)
+	if result.Metadata == nil || result.Metadata.Version != 1 {
+		t.Errorf("Expected metadata of the updated item but found %+v", result.Metadata)
+	}
+}
+
+func Test_Missing_Item_Is_Not_Found(t *testing.T) {
+	repo, _ := createMockedRepo(t)
+
+	_, patchErr := repo.Patch("missing", []byte(`{"MockString":"new"}`))
+	deleteErr := repo.Delete("missing")
+
+	if !errors.Is(patchErr, repository.ErrNotFound) || !errors.Is(deleteErr, repository.ErrNotFound) {
+		t.Errorf("Expected not found errors but found %v and %v", patchErr, deleteErr)
+	}
+}
+
+func Test_Native_Patch_Expression(t *testing.T) {
+	mock := newMockDynamoDB()
+	repo, err := NewDynamoDBRepoWithConnection(mock, testTableName, nativeItem{}.ToStruct, WithNativeAttributes())
+	checkError(err, t)
+	mock.items["key"] = map[string]*dynamodb.AttributeValue{
+		keyName:     {S: aws.String("key")},
+		"name":      {S: aws.String("a")},
+		versionName: {N: aws.String("1")},
+	}
+
+	result, err := repo.Patch("key", []byte(`{"name":"b","labels":null,"settings":{"mode":"slow","size":null},"count":2}`))
+
+	checkError(err, t)
+	if len(mock.updateItems) != 1 {
+		t.Fatalf("Expected a single update but found %+v", mock.updateItems)
+	}
+	expected := "SET #a0 = :a0, #a1 = :a1, #a2_0.#a2_1 = :a2, " + setMetadataExpression +
+		" REMOVE #r0, #r1_0.#r1_1 ADD " + addVersionExpression
+	if actual := aws.StringValue(mock.updateItems[0].UpdateExpression); actual != expected {
+		t.Errorf("Expected update expression %s but found %s", expected, actual)
+	}
+	condition := aws.StringValue(mock.updateItems[0].ConditionExpression)
+	if !strings.Contains(condition, "attribute_not_exists(#value)") || !strings.Contains(condition, "attribute_type(#m0, :map)") {
+		t.Errorf("Expected condition on the storage mode and map attribute but found %s", condition)
+	}
+	if !reflect.DeepEqual(result.Value, nativeItem{Name: "a"}) || result.Metadata.Version != 1 {
+		t.Errorf("Expected the returned item but found %+v", result)
+	}
+}
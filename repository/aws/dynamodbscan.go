@@ -0,0 +1,162 @@
+package aws
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// scanSettings configure how FindAll and ScanEach read the whole table
+type scanSettings struct {
+	segments int
+	workers  int
+	limiter  repository.Limiter
+}
+
+// WithParallelScan splits scans of the whole table into segments which are scanned by
+// a pool of workers. If workers is not positive, all segments are scanned in parallel.
+func WithParallelScan(segments int, workers int) DynamoDBOption {
+	return func(repo *DynamoDBRepo) {
+		repo.scan.segments = segments
+		repo.scan.workers = workers
+	}
+}
+
+// WithScanCapacityLimiter limits the read capacity consumed by scans of the whole table.
+// The limiter is waited for once per consumed capacity unit, so a TokenBucket with a rate
+// of 100 allows scans to consume 100 read capacity units per second across all workers.
+func WithScanCapacityLimiter(limiter repository.Limiter) DynamoDBOption {
+	return func(repo *DynamoDBRepo) {
+		repo.scan.limiter = limiter
+	}
+}
+
+// ScanEach streams all items of the table to a callback instead of collecting them. The
+// callback is never called concurrently, but items of different segments are interleaved.
+// Scanning stops at the first error of the callback or if the context is done.
+func (repo *DynamoDBRepo) ScanEach(ctx context.Context, callback func(repository.KeyValuePair) error) error {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	callbackMutex := sync.Mutex{}
+	return repo.scanSegments(ctx, func(segment int, items []map[string]*dynamodb.AttributeValue) error {
+		keyValuePairs, err := repo.toKeyValuePairs(items)
+		if err != nil {
+			return err
+		}
+		callbackMutex.Lock()
+		defer callbackMutex.Unlock()
+		for _, keyValuePair := range keyValuePairs {
+			if err := callback(keyValuePair); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scanSegments scans all segments of the table and passes each page to the handler. All
+// pages of a segment are handled by the same worker in order.
+func (repo *DynamoDBRepo) scanSegments(parent context.Context, handler func(segment int, items []map[string]*dynamodb.AttributeValue) error) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var firstErr error
+	errOnce := sync.Once{}
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	total := repo.scan.totalSegments()
+	segments := make(chan int)
+	wait := sync.WaitGroup{}
+	for i := 0; i < repo.scan.totalWorkers(); i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for segment := range segments {
+				if err := repo.scanSegment(ctx, segment, total, handler); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+schedule:
+	for segment := 0; segment < total; segment++ {
+		select {
+		case segments <- segment:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+	close(segments)
+	wait.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return parent.Err()
+}
+
+func (repo *DynamoDBRepo) scanSegment(ctx context.Context, segment int, total int, handler func(segment int, items []map[string]*dynamodb.AttributeValue) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:      aws.String(repo.tableName),
+		ConsistentRead: aws.Bool(repo.consistentRead),
+	}
+	if total > 1 {
+		input.Segment = aws.Int64(int64(segment))
+		input.TotalSegments = aws.Int64(int64(total))
+	}
+	if repo.scan.limiter != nil {
+		input.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	}
+
+	var pageErr error
+	err := repo.connection.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		pageErr = handler(segment, page.Items)
+		if pageErr == nil {
+			pageErr = repo.scan.waitForCapacity(ctx, page.ConsumedCapacity)
+		}
+		return pageErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return pageErr
+}
+
+// waitForCapacity blocks until the limiter allows the consumed capacity
+func (settings scanSettings) waitForCapacity(ctx context.Context, consumed *dynamodb.ConsumedCapacity) error {
+	if settings.limiter == nil || consumed == nil {
+		return nil
+	}
+	units := int(math.Ceil(aws.Float64Value(consumed.CapacityUnits)))
+	for i := 0; i < units; i++ {
+		if err := settings.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (settings scanSettings) totalSegments() int {
+	if settings.segments < 1 {
+		return 1
+	}
+	return settings.segments
+}
+
+func (settings scanSettings) totalWorkers() int {
+	if settings.workers < 1 || settings.workers > settings.totalSegments() {
+		return settings.totalSegments()
+	}
+	return settings.workers
+}
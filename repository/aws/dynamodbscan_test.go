@@ -0,0 +1,163 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+func createScanMock(count int) *mockDynamoDB {
+	mock := newMockDynamoDB()
+	mock.scanPageSize = 2
+	for i := 0; i < count; i++ {
+		mock.scanItems = append(mock.scanItems, map[string]*dynamodb.AttributeValue{
+			keyName:   {S: aws.String(fmt.Sprintf("key%d", i))},
+			valueName: {S: aws.String(`{"MockString":"mock"}`)},
+		})
+	}
+	return mock
+}
+
+func createScanRepo(t *testing.T, mock *mockDynamoDB, options ...DynamoDBOption) *DynamoDBRepo {
+	repo, err := NewDynamoDBRepoWithConnection(mock, testTableName, mockedItem.ToStruct, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func Test_FindAll_Pages(t *testing.T) {
+	mock := createScanMock(5)
+	repo := createScanRepo(t, mock)
+
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if len(items) != 5 || items[4].Value != mockedItem {
+		t.Errorf("Expected all items of all pages but found %+v", items)
+	}
+	if mock.scanInputs[0].TotalSegments != nil {
+		t.Errorf("Expected scan without segments but found %+v", mock.scanInputs[0])
+	}
+}
+
+func Test_FindAll_Parallel_Scan(t *testing.T) {
+	mock := createScanMock(20)
+	repo := createScanRepo(t, mock, WithParallelScan(8, 3))
+
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if len(items) != 20 {
+		t.Errorf("Expected 20 items but found %d", len(items))
+	}
+	if len(mock.scanInputs) != 8 || *mock.scanInputs[0].TotalSegments != 8 {
+		t.Errorf("Expected 8 segments but found %+v", mock.scanInputs)
+	}
+	if mock.maxActiveScans > 3 {
+		t.Errorf("Expected at most 3 parallel scans but found %d", mock.maxActiveScans)
+	}
+	// items are ordered by segment
+	if items[0].Key != "key0" || items[1].Key != "key8" {
+		t.Errorf("Expected items of first segment first but found %s and %s", items[0].Key, items[1].Key)
+	}
+}
+
+func Test_ScanEach(t *testing.T) {
+	mock := createScanMock(10)
+	repo := createScanRepo(t, mock, WithParallelScan(4, 0))
+	keys := map[string]bool{}
+
+	err := repo.ScanEach(context.Background(), func(item repository.KeyValuePair) error {
+		keys[item.Key] = true
+		return nil
+	})
+	checkError(err, t)
+
+	if len(keys) != 10 {
+		t.Errorf("Expected 10 keys but found %v", keys)
+	}
+}
+
+func Test_ScanEach_Stops_On_Error(t *testing.T) {
+	mock := createScanMock(10)
+	repo := createScanRepo(t, mock, WithParallelScan(4, 1))
+	expected := errors.New("stop")
+	count := 0
+
+	err := repo.ScanEach(context.Background(), func(item repository.KeyValuePair) error {
+		count++
+		return expected
+	})
+
+	if err != expected || count != 1 {
+		t.Errorf("Expected %v after 1 item but found %v after %d items", expected, err, count)
+	}
+}
+
+func Test_ScanEach_Context_Done(t *testing.T) {
+	mock := createScanMock(10)
+	repo := createScanRepo(t, mock, WithParallelScan(4, 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := repo.ScanEach(ctx, func(item repository.KeyValuePair) error {
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v but found %v", context.Canceled, err)
+	}
+}
+
+type countingLimiter struct {
+	mutex sync.Mutex
+	count int
+}
+
+func (limiter *countingLimiter) Wait(ctx context.Context) error {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.count++
+	return nil
+}
+
+func Test_Scan_Capacity_Limiter(t *testing.T) {
+	mock := createScanMock(7)
+	limiter := &countingLimiter{}
+	repo := createScanRepo(t, mock, WithParallelScan(2, 2), WithScanCapacityLimiter(limiter))
+
+	_, err := repo.FindAll()
+	checkError(err, t)
+
+	if limiter.count != 7 {
+		t.Errorf("Expected limiter to be waited for 7 capacity units but found %d", limiter.count)
+	}
+	if *mock.scanInputs[0].ReturnConsumedCapacity != dynamodb.ReturnConsumedCapacityTotal {
+		t.Errorf("Expected consumed capacity to be requested but found %+v", mock.scanInputs[0])
+	}
+}
+
+func Test_Scan_Capacity_Limiter_Rate_Limited(t *testing.T) {
+	mock := createScanMock(7)
+	bucket, err := repository.NewTokenBucket(0.001, 1)
+	checkError(err, t)
+	repo := createScanRepo(t, mock, WithScanCapacityLimiter(bucket))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = repo.ScanEach(ctx, func(item repository.KeyValuePair) error {
+		return nil
+	})
+
+	if !errors.Is(err, repository.ErrRateLimited) {
+		t.Errorf("Expected %v but found %v", repository.ErrRateLimited, err)
+	}
+}
@@ -0,0 +1,190 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// StreamHandler passes the changes of a DynamoDBRepo table, which are delivered by a DynamoDB
+// stream to a Lambda, to typed callbacks. Images of the stream records are decoded like items
+// of the repository, so values stored as json string and as native attributes are supported.
+//
+// Example:
+// handler := NewStreamHandler(repo)
+// handler.OnInsert(func(item repository.KeyValuePair) error { ... })
+// lambda.Start(handler.Handle)
+type StreamHandler struct {
+	repo     *DynamoDBRepo
+	onInsert func(item repository.KeyValuePair) error
+	onModify func(oldItem repository.KeyValuePair, newItem repository.KeyValuePair) error
+	onRemove func(item repository.KeyValuePair) error
+}
+
+// NewStreamHandler creates a StreamHandler for the stream of the table of the repository
+func NewStreamHandler(repo *DynamoDBRepo) *StreamHandler {
+	return &StreamHandler{
+		repo: repo,
+	}
+}
+
+// OnInsert sets the callback for created items
+func (handler *StreamHandler) OnInsert(callback func(item repository.KeyValuePair) error) {
+	handler.onInsert = callback
+}
+
+// OnModify sets the callback for changed items. The old item has no value if the stream
+// does not contain old images.
+func (handler *StreamHandler) OnModify(callback func(oldItem repository.KeyValuePair, newItem repository.KeyValuePair) error) {
+	handler.onModify = callback
+}
+
+// OnRemove sets the callback for deleted items. The item has no value if the stream does
+// not contain old images.
+func (handler *StreamHandler) OnRemove(callback func(item repository.KeyValuePair) error) {
+	handler.onRemove = callback
+}
+
+// Handle passes the records of an event to the callbacks in order. Processing stops at the first
+// record which can not be decoded or whose callback fails. This record is reported as batch item
+// failure, so Lambda retries it and all following records while the order of changes is kept.
+// Requires ReportBatchItemFailures to be enabled on the event source mapping.
+func (handler *StreamHandler) Handle(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	response := events.DynamoDBEventResponse{
+		BatchItemFailures: []events.DynamoDBBatchItemFailure{},
+	}
+	for _, record := range event.Records {
+		err := ctx.Err()
+		if err == nil {
+			err = handler.handleRecord(record)
+		}
+		if err != nil {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+			return response, nil
+		}
+	}
+	return response, nil
+}
+
+func (handler *StreamHandler) handleRecord(record events.DynamoDBEventRecord) error {
+	switch events.DynamoDBOperationType(record.EventName) {
+	case events.DynamoDBOperationTypeInsert:
+		if handler.onInsert == nil {
+			return nil
+		}
+		item, err := handler.toKeyValuePair(record.Change.Keys, record.Change.NewImage)
+		if err != nil {
+			return err
+		}
+		return handler.onInsert(item)
+	case events.DynamoDBOperationTypeModify:
+		if handler.onModify == nil {
+			return nil
+		}
+		oldItem, err := handler.toKeyValuePair(record.Change.Keys, record.Change.OldImage)
+		if err != nil {
+			return err
+		}
+		newItem, err := handler.toKeyValuePair(record.Change.Keys, record.Change.NewImage)
+		if err != nil {
+			return err
+		}
+		return handler.onModify(oldItem, newItem)
+	case events.DynamoDBOperationTypeRemove:
+		if handler.onRemove == nil {
+			return nil
+		}
+		item, err := handler.toKeyValuePair(record.Change.Keys, record.Change.OldImage)
+		if err != nil {
+			return err
+		}
+		return handler.onRemove(item)
+	}
+	return fmt.Errorf("unknown event %s of record %s", record.EventName, record.EventID)
+}
+
+// toKeyValuePair decodes an image of a stream record. If the image is missing, only the key is set.
+func (handler *StreamHandler) toKeyValuePair(keys map[string]events.DynamoDBAttributeValue, image map[string]events.DynamoDBAttributeValue) (repository.KeyValuePair, error) {
+	keyAttributes, err := toAttributeValues(keys)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	key, err := handler.repo.keySchema.toKey(keyAttributes)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	if len(image) == 0 {
+		return repository.KeyValuePair{Key: key}, nil
+	}
+
+	item, err := toAttributeValues(image)
+	if err != nil {
+		return getEmptyKeyValuePair(), err
+	}
+	value, err := handler.repo.decodeItem(item)
+	if err != nil {
+		return getEmptyKeyValuePair(), fmt.Errorf("could not decode item with key %s: %w", key, err)
+	}
+	return repository.KeyValuePair{
+		Key:      key,
+		Value:    value,
+		Metadata: toItemMetadata(item),
+	}, nil
+}
+
+func toAttributeValues(image map[string]events.DynamoDBAttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	result := make(map[string]*dynamodb.AttributeValue, len(image))
+	for name, value := range image {
+		converted, err := toAttributeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert attribute %s: %w", name, err)
+		}
+		result[name] = converted
+	}
+	return result, nil
+}
+
+// toAttributeValue converts an attribute of a stream record into an attribute of the sdk
+func toAttributeValue(value events.DynamoDBAttributeValue) (*dynamodb.AttributeValue, error) {
+	switch value.DataType() {
+	case events.DataTypeBinary:
+		return &dynamodb.AttributeValue{B: value.Binary()}, nil
+	case events.DataTypeBoolean:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(value.Boolean())}, nil
+	case events.DataTypeBinarySet:
+		return &dynamodb.AttributeValue{BS: value.BinarySet()}, nil
+	case events.DataTypeList:
+		list := make([]*dynamodb.AttributeValue, 0, len(value.List()))
+		for _, element := range value.List() {
+			converted, err := toAttributeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return &dynamodb.AttributeValue{L: list}, nil
+	case events.DataTypeMap:
+		converted, err := toAttributeValues(value.Map())
+		if err != nil {
+			return nil, err
+		}
+		return &dynamodb.AttributeValue{M: converted}, nil
+	case events.DataTypeNumber:
+		return &dynamodb.AttributeValue{N: aws.String(value.Number())}, nil
+	case events.DataTypeNumberSet:
+		return &dynamodb.AttributeValue{NS: aws.StringSlice(value.NumberSet())}, nil
+	case events.DataTypeNull:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}, nil
+	case events.DataTypeString:
+		return &dynamodb.AttributeValue{S: aws.String(value.String())}, nil
+	case events.DataTypeStringSet:
+		return &dynamodb.AttributeValue{SS: aws.StringSlice(value.StringSet())}, nil
+	}
+	return nil, fmt.Errorf("unsupported data type %v", value.DataType())
+}
@@ -0,0 +1,170 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+func createStreamHandler(t *testing.T, options ...DynamoDBOption) *StreamHandler {
+	repo, err := NewDynamoDBRepoWithConnection(newMockDynamoDB(), testTableName, mockedItem.ToStruct, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStreamHandler(repo)
+}
+
+func streamRecord(eventName events.DynamoDBOperationType, sequenceNumber string, key string, oldImage map[string]events.DynamoDBAttributeValue, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventName: string(eventName),
+		Change: events.DynamoDBStreamRecord{
+			Keys:           map[string]events.DynamoDBAttributeValue{keyName: events.NewStringAttribute(key)},
+			OldImage:       oldImage,
+			NewImage:       newImage,
+			SequenceNumber: sequenceNumber,
+		},
+	}
+}
+
+func stringImage(key string, value string) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		keyName:     events.NewStringAttribute(key),
+		valueName:   events.NewStringAttribute(value),
+		versionName: events.NewNumberAttribute("2"),
+	}
+}
+
+func Test_StreamHandler_Insert(t *testing.T) {
+	handler := createStreamHandler(t)
+	inserted := []repository.KeyValuePair{}
+	handler.OnInsert(func(item repository.KeyValuePair) error {
+		inserted = append(inserted, item)
+		return nil
+	})
+
+	response, err := handler.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord(events.DynamoDBOperationTypeInsert, "1", "key1", nil, stringImage("key1", `{"MockString":"mock"}`)),
+	}})
+	checkError(err, t)
+
+	if len(response.BatchItemFailures) != 0 {
+		t.Errorf("Expected no failures but found %+v", response.BatchItemFailures)
+	}
+	if len(inserted) != 1 || inserted[0].Key != "key1" || inserted[0].Value != mockedItem || inserted[0].Metadata.Version != 2 {
+		t.Errorf("Expected decoded item but found %+v", inserted)
+	}
+}
+
+func Test_StreamHandler_Modify_Native_Attributes(t *testing.T) {
+	handler := createStreamHandler(t, WithNativeAttributes())
+	var oldValue, newValue interface{}
+	handler.OnModify(func(oldItem repository.KeyValuePair, newItem repository.KeyValuePair) error {
+		oldValue, newValue = oldItem.Value, newItem.Value
+		return nil
+	})
+
+	_, err := handler.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord(events.DynamoDBOperationTypeModify, "1", "key1",
+			stringImage("key1", `{"MockString":"old"}`),
+			map[string]events.DynamoDBAttributeValue{
+				keyName:      events.NewStringAttribute("key1"),
+				"MockString": events.NewStringAttribute("new"),
+			}),
+	}})
+	checkError(err, t)
+
+	if oldValue != (serialization.MockItem{MockString: "old"}) || newValue != (serialization.MockItem{MockString: "new"}) {
+		t.Errorf("Expected old and new value but found %+v and %+v", oldValue, newValue)
+	}
+}
+
+func Test_StreamHandler_Remove_Keys_Only(t *testing.T) {
+	handler := createStreamHandler(t, WithKeySchema(compositeKeySchema))
+	removed := []repository.KeyValuePair{}
+	handler.OnRemove(func(item repository.KeyValuePair) error {
+		removed = append(removed, item)
+		return nil
+	})
+	record := streamRecord(events.DynamoDBOperationTypeRemove, "1", "", nil, nil)
+	record.Change.Keys = map[string]events.DynamoDBAttributeValue{
+		"user": events.NewStringAttribute("user1"),
+		"item": events.NewStringAttribute("item1"),
+	}
+
+	_, err := handler.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{record}})
+	checkError(err, t)
+
+	if len(removed) != 1 || removed[0].Key != "user1|item1" || removed[0].Value != nil {
+		t.Errorf("Expected removed key without value but found %+v", removed)
+	}
+}
+
+func Test_StreamHandler_Batch_Item_Failure(t *testing.T) {
+	handler := createStreamHandler(t)
+	handled := []string{}
+	handler.OnInsert(func(item repository.KeyValuePair) error {
+		handled = append(handled, item.Key)
+		if item.Key == "key2" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	response, err := handler.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord(events.DynamoDBOperationTypeInsert, "1", "key1", nil, stringImage("key1", `{}`)),
+		streamRecord(events.DynamoDBOperationTypeInsert, "2", "key2", nil, stringImage("key2", `{}`)),
+		streamRecord(events.DynamoDBOperationTypeInsert, "3", "key3", nil, stringImage("key3", `{}`)),
+	}})
+	checkError(err, t)
+
+	expected := []events.DynamoDBBatchItemFailure{{ItemIdentifier: "2"}}
+	if !reflect.DeepEqual(response.BatchItemFailures, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, response.BatchItemFailures)
+	}
+	if !reflect.DeepEqual(handled, []string{"key1", "key2"}) {
+		t.Errorf("Expected processing to stop at the failed record but found %v", handled)
+	}
+}
+
+func Test_StreamHandler_Decode_Failure(t *testing.T) {
+	handler := createStreamHandler(t)
+	handler.OnInsert(func(item repository.KeyValuePair) error {
+		return nil
+	})
+
+	response, err := handler.Handle(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord(events.DynamoDBOperationTypeInsert, "1", "key1", nil, stringImage("key1", `{invalid`)),
+	}})
+	checkError(err, t)
+
+	if len(response.BatchItemFailures) != 1 {
+		t.Errorf("Expected failure for invalid value but found %+v", response.BatchItemFailures)
+	}
+}
+
+func Test_To_Attribute_Value(t *testing.T) {
+	image := map[string]events.DynamoDBAttributeValue{
+		"binary":    events.NewBinaryAttribute([]byte("b")),
+		"boolean":   events.NewBooleanAttribute(true),
+		"binarySet": events.NewBinarySetAttribute([][]byte{[]byte("b")}),
+		"list":      events.NewListAttribute([]events.DynamoDBAttributeValue{events.NewNumberAttribute("1")}),
+		"map":       events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{"a": events.NewNullAttribute()}),
+		"numberSet": events.NewNumberSetAttribute([]string{"1", "2"}),
+		"string":    events.NewStringAttribute("s"),
+		"stringSet": events.NewStringSetAttribute([]string{"a"}),
+	}
+
+	converted, err := toAttributeValues(image)
+	checkError(err, t)
+
+	if string(converted["binary"].B) != "b" || !*converted["boolean"].BOOL || len(converted["binarySet"].BS) != 1 ||
+		*converted["list"].L[0].N != "1" || !*converted["map"].M["a"].NULL || len(converted["numberSet"].NS) != 2 ||
+		*converted["string"].S != "s" || *converted["stringSet"].SS[0] != "a" {
+		t.Errorf("Expected all attributes to be converted but found %+v", converted)
+	}
+}
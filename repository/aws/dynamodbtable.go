@@ -0,0 +1,489 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// default settings of tables which are created without explicit specification
+const (
+	defaultCapacityUnits = 10
+	defaultWaitTimeout   = 5 * time.Minute
+)
+
+// tableStatusPollInterval is the time between two checks if a table is active
+var tableStatusPollInterval = 2 * time.Second
+
+// TableSpec describes the settings of a table. Settings which are not set are left unchanged
+// on existing tables, e.g. an existing stream is not disabled.
+type TableSpec struct {
+	// OnDemand uses pay per request billing instead of provisioned throughput
+	OnDemand bool
+	// provisioned throughput of the table, both default to 10 capacity units for new tables
+	ReadCapacityUnits  int64
+	WriteCapacityUnits int64
+	// GlobalSecondaryIndexes are created if they do not exist
+	GlobalSecondaryIndexes []GlobalSecondaryIndex
+	// TTLAttribute enables time to live on an attribute which holds the expiry in epoch seconds
+	TTLAttribute string
+	// StreamViewType enables the stream of the table, e.g. dynamodb.StreamViewTypeNewAndOldImages
+	StreamViewType string
+	// SSE enables server side encryption with a KMS key. If no key id is set, the key
+	// managed by AWS is used.
+	SSE         bool
+	SSEKMSKeyID string
+	// Tags are added to the table, existing tags which are not part of the spec are kept
+	Tags map[string]string
+	// WaitTimeout limits the time to wait for the table to become active, defaults to 5 minutes
+	WaitTimeout time.Duration
+}
+
+// GlobalSecondaryIndex describes an index of a table
+type GlobalSecondaryIndex struct {
+	Name         string
+	PartitionKey string
+	// optional name of the sort key attribute
+	SortKey string
+	// attribute types of the keys, default to dynamodb.ScalarAttributeTypeS
+	PartitionKeyType string
+	SortKeyType      string
+	// ProjectionType defaults to dynamodb.ProjectionTypeAll, NonKeyAttributes are projected
+	// for dynamodb.ProjectionTypeInclude
+	ProjectionType   string
+	NonKeyAttributes []string
+	// provisioned throughput of the index, defaults to the throughput of the table
+	ReadCapacityUnits  int64
+	WriteCapacityUnits int64
+}
+
+// WithTableSpec sets the settings used to create or update the table. By default tables are
+// created with 10 provisioned read and write capacity units.
+func WithTableSpec(spec TableSpec) DynamoDBOption {
+	return func(repo *DynamoDBRepo) {
+		repo.tableSpec = spec
+	}
+}
+
+// EnsureTable creates a table if it does not exist and waits until it is active. Existing
+// tables are validated against the key schema and updated to match the spec, e.g. missing
+// indexes are created.
+func EnsureTable(connection dynamodbiface.DynamoDBAPI, tableName string, keySchema KeySchema, spec TableSpec) error {
+	output, err := connection.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if isAWSErrorCode(err, dynamodb.ErrCodeResourceNotFoundException) {
+		return createTable(connection, tableName, keySchema, spec)
+	}
+	if err != nil {
+		return err
+	}
+
+	table := output.Table
+	if err := validateKeySchema(table.KeySchema, keySchema.keySchemaElements()); err != nil {
+		return fmt.Errorf("table %s does not match the key schema: %w", tableName, err)
+	}
+	if err := waitUntilActive(connection, tableName, spec.waitTimeout()); err != nil {
+		return err
+	}
+	if err := updateTable(connection, table, keySchema, spec); err != nil {
+		return err
+	}
+	if err := ensureTimeToLive(connection, tableName, spec.TTLAttribute); err != nil {
+		return err
+	}
+	return ensureTags(connection, aws.StringValue(table.TableArn), spec.Tags)
+}
+
+func createTable(connection dynamodbiface.DynamoDBAPI, tableName string, keySchema KeySchema, spec TableSpec) error {
+	input := &dynamodb.CreateTableInput{
+		TableName:             aws.String(tableName),
+		AttributeDefinitions:  spec.attributeDefinitions(keySchema),
+		KeySchema:             keySchema.keySchemaElements(),
+		BillingMode:           aws.String(spec.billingMode()),
+		ProvisionedThroughput: spec.provisionedThroughput(),
+		StreamSpecification:   spec.streamSpecification(),
+		SSESpecification:      spec.sseSpecification(),
+	}
+	for _, index := range spec.GlobalSecondaryIndexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:             aws.String(index.Name),
+			KeySchema:             index.keySchemaElements(),
+			Projection:            index.projection(),
+			ProvisionedThroughput: spec.indexThroughput(index),
+		})
+	}
+	if len(spec.Tags) > 0 {
+		input.Tags = sortedTags(spec.Tags)
+	}
+
+	_, err := connection.CreateTable(input)
+	if err != nil {
+		return err
+	}
+	if err := waitUntilActive(connection, tableName, spec.waitTimeout()); err != nil {
+		return err
+	}
+	return ensureTimeToLive(connection, tableName, spec.TTLAttribute)
+}
+
+// updateTable changes the settings of an existing table which differ from the spec. Each
+// change is a separate update since DynamoDB only allows one kind of change per update.
+func updateTable(connection dynamodbiface.DynamoDBAPI, table *dynamodb.TableDescription, keySchema KeySchema, spec TableSpec) error {
+	tableName := aws.StringValue(table.TableName)
+	updates := []*dynamodb.UpdateTableInput{}
+
+	// the billing of existing tables is only changed if it is set explicitly
+	managesBilling := spec.OnDemand || spec.ReadCapacityUnits > 0 || spec.WriteCapacityUnits > 0
+	if managesBilling && (billingModeOf(table) != spec.billingMode() || (!spec.OnDemand && !hasThroughput(table, spec))) {
+		updates = append(updates, &dynamodb.UpdateTableInput{
+			BillingMode:           aws.String(spec.billingMode()),
+			ProvisionedThroughput: spec.provisionedThroughput(),
+		})
+	}
+
+	if spec.StreamViewType != "" {
+		stream := table.StreamSpecification
+		enabled := stream != nil && aws.BoolValue(stream.StreamEnabled)
+		if enabled && aws.StringValue(stream.StreamViewType) != spec.StreamViewType {
+			// the view type of a stream can only be changed by disabling it first
+			updates = append(updates, &dynamodb.UpdateTableInput{
+				StreamSpecification: &dynamodb.StreamSpecification{StreamEnabled: aws.Bool(false)},
+			})
+			enabled = false
+		}
+		if !enabled {
+			updates = append(updates, &dynamodb.UpdateTableInput{
+				StreamSpecification: spec.streamSpecification(),
+			})
+		}
+	}
+
+	if spec.SSE && !isSSEEnabled(table) {
+		updates = append(updates, &dynamodb.UpdateTableInput{
+			SSESpecification: spec.sseSpecification(),
+		})
+	}
+
+	existingIndexes := map[string]*dynamodb.GlobalSecondaryIndexDescription{}
+	for _, index := range table.GlobalSecondaryIndexes {
+		existingIndexes[aws.StringValue(index.IndexName)] = index
+	}
+	for _, index := range spec.GlobalSecondaryIndexes {
+		if existing, ok := existingIndexes[index.Name]; ok {
+			if err := validateKeySchema(existing.KeySchema, index.keySchemaElements()); err != nil {
+				return fmt.Errorf("index %s of table %s does not match the spec: %w", index.Name, tableName, err)
+			}
+			continue
+		}
+		updates = append(updates, &dynamodb.UpdateTableInput{
+			AttributeDefinitions: spec.attributeDefinitions(keySchema),
+			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:             aws.String(index.Name),
+					KeySchema:             index.keySchemaElements(),
+					Projection:            index.projection(),
+					ProvisionedThroughput: spec.indexThroughput(index),
+				},
+			}},
+		})
+	}
+
+	for _, update := range updates {
+		update.TableName = aws.String(tableName)
+		if _, err := connection.UpdateTable(update); err != nil {
+			return err
+		}
+		if err := waitUntilActive(connection, tableName, spec.waitTimeout()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitUntilActive polls the status of a table until the table and all its indexes are active
+func waitUntilActive(connection dynamodbiface.DynamoDBAPI, tableName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		output, err := connection.DescribeTable(&dynamodb.DescribeTableInput{
+			TableName: aws.String(tableName),
+		})
+		if err != nil {
+			return err
+		}
+		if isActive(output.Table) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("table %s is not active after %v", tableName, timeout)
+		}
+		time.Sleep(tableStatusPollInterval)
+	}
+}
+
+func isActive(table *dynamodb.TableDescription) bool {
+	if aws.StringValue(table.TableStatus) != dynamodb.TableStatusActive {
+		return false
+	}
+	for _, index := range table.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexStatus) != dynamodb.IndexStatusActive {
+			return false
+		}
+	}
+	return true
+}
+
+func ensureTimeToLive(connection dynamodbiface.DynamoDBAPI, tableName string, attribute string) error {
+	if attribute == "" {
+		return nil
+	}
+	output, err := connection.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return err
+	}
+
+	description := output.TimeToLiveDescription
+	if description != nil {
+		status := aws.StringValue(description.TimeToLiveStatus)
+		enabled := status == dynamodb.TimeToLiveStatusEnabled || status == dynamodb.TimeToLiveStatusEnabling
+		current := aws.StringValue(description.AttributeName)
+		if enabled && current == attribute {
+			return nil
+		}
+		if enabled {
+			return fmt.Errorf("time to live of table %s is enabled on attribute %s instead of %s", tableName, current, attribute)
+		}
+	}
+
+	_, err = connection.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(attribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+func ensureTags(connection dynamodbiface.DynamoDBAPI, tableArn string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	existing := map[string]string{}
+	input := &dynamodb.ListTagsOfResourceInput{
+		ResourceArn: aws.String(tableArn),
+	}
+	for {
+		output, err := connection.ListTagsOfResource(input)
+		if err != nil {
+			return err
+		}
+		for _, tag := range output.Tags {
+			existing[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+
+	missing := []*dynamodb.Tag{}
+	for _, tag := range sortedTags(tags) {
+		if value, ok := existing[aws.StringValue(tag.Key)]; !ok || value != aws.StringValue(tag.Value) {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	_, err := connection.TagResource(&dynamodb.TagResourceInput{
+		ResourceArn: aws.String(tableArn),
+		Tags:        missing,
+	})
+	return err
+}
+
+// validateKeySchema checks if the key schema of a table or index equals the expected one
+func validateKeySchema(actual []*dynamodb.KeySchemaElement, expected []*dynamodb.KeySchemaElement) error {
+	if len(actual) != len(expected) {
+		return fmt.Errorf("expected %d key attributes but found %d", len(expected), len(actual))
+	}
+	for i, element := range expected {
+		if aws.StringValue(actual[i].AttributeName) != aws.StringValue(element.AttributeName) ||
+			aws.StringValue(actual[i].KeyType) != aws.StringValue(element.KeyType) {
+			return fmt.Errorf("expected %s key %s but found %s key %s",
+				aws.StringValue(element.KeyType), aws.StringValue(element.AttributeName),
+				aws.StringValue(actual[i].KeyType), aws.StringValue(actual[i].AttributeName))
+		}
+	}
+	return nil
+}
+
+func billingModeOf(table *dynamodb.TableDescription) string {
+	if table.BillingModeSummary == nil || table.BillingModeSummary.BillingMode == nil {
+		// tables created before on demand billing existed have no summary
+		return dynamodb.BillingModeProvisioned
+	}
+	return aws.StringValue(table.BillingModeSummary.BillingMode)
+}
+
+func hasThroughput(table *dynamodb.TableDescription, spec TableSpec) bool {
+	throughput := table.ProvisionedThroughput
+	expected := spec.provisionedThroughput()
+	return throughput != nil &&
+		aws.Int64Value(throughput.ReadCapacityUnits) == aws.Int64Value(expected.ReadCapacityUnits) &&
+		aws.Int64Value(throughput.WriteCapacityUnits) == aws.Int64Value(expected.WriteCapacityUnits)
+}
+
+func isSSEEnabled(table *dynamodb.TableDescription) bool {
+	if table.SSEDescription == nil {
+		return false
+	}
+	status := aws.StringValue(table.SSEDescription.Status)
+	return status == dynamodb.SSEStatusEnabled || status == dynamodb.SSEStatusEnabling
+}
+
+func (spec TableSpec) billingMode() string {
+	if spec.OnDemand {
+		return dynamodb.BillingModePayPerRequest
+	}
+	return dynamodb.BillingModeProvisioned
+}
+
+func (spec TableSpec) provisionedThroughput() *dynamodb.ProvisionedThroughput {
+	if spec.OnDemand {
+		return nil
+	}
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(int64OrDefault(spec.ReadCapacityUnits, defaultCapacityUnits)),
+		WriteCapacityUnits: aws.Int64(int64OrDefault(spec.WriteCapacityUnits, defaultCapacityUnits)),
+	}
+}
+
+func (spec TableSpec) indexThroughput(index GlobalSecondaryIndex) *dynamodb.ProvisionedThroughput {
+	throughput := spec.provisionedThroughput()
+	if throughput == nil {
+		return nil
+	}
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(int64OrDefault(index.ReadCapacityUnits, *throughput.ReadCapacityUnits)),
+		WriteCapacityUnits: aws.Int64(int64OrDefault(index.WriteCapacityUnits, *throughput.WriteCapacityUnits)),
+	}
+}
+
+func (spec TableSpec) streamSpecification() *dynamodb.StreamSpecification {
+	if spec.StreamViewType == "" {
+		return nil
+	}
+	return &dynamodb.StreamSpecification{
+		StreamEnabled:  aws.Bool(true),
+		StreamViewType: aws.String(spec.StreamViewType),
+	}
+}
+
+func (spec TableSpec) sseSpecification() *dynamodb.SSESpecification {
+	if !spec.SSE {
+		return nil
+	}
+	specification := &dynamodb.SSESpecification{
+		Enabled: aws.Bool(true),
+		SSEType: aws.String(dynamodb.SSETypeKms),
+	}
+	if spec.SSEKMSKeyID != "" {
+		specification.KMSMasterKeyId = aws.String(spec.SSEKMSKeyID)
+	}
+	return specification
+}
+
+// attributeDefinitions contains the key attributes of the table and of all indexes
+func (spec TableSpec) attributeDefinitions(keySchema KeySchema) []*dynamodb.AttributeDefinition {
+	definitions := keySchema.attributeDefinitions()
+	defined := map[string]bool{}
+	for _, definition := range definitions {
+		defined[aws.StringValue(definition.AttributeName)] = true
+	}
+	add := func(name string, attributeType string) {
+		if name == "" || defined[name] {
+			return
+		}
+		defined[name] = true
+		definitions = append(definitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: aws.String(stringOrDefault(attributeType, dynamodb.ScalarAttributeTypeS)),
+		})
+	}
+	for _, index := range spec.GlobalSecondaryIndexes {
+		add(index.PartitionKey, index.PartitionKeyType)
+		add(index.SortKey, index.SortKeyType)
+	}
+	return definitions
+}
+
+func (spec TableSpec) waitTimeout() time.Duration {
+	if spec.WaitTimeout <= 0 {
+		return defaultWaitTimeout
+	}
+	return spec.WaitTimeout
+}
+
+func (index GlobalSecondaryIndex) keySchemaElements() []*dynamodb.KeySchemaElement {
+	elements := []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String(index.PartitionKey),
+			KeyType:       aws.String(dynamodb.KeyTypeHash),
+		},
+	}
+	if index.SortKey != "" {
+		elements = append(elements, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(index.SortKey),
+			KeyType:       aws.String(dynamodb.KeyTypeRange),
+		})
+	}
+	return elements
+}
+
+func (index GlobalSecondaryIndex) projection() *dynamodb.Projection {
+	projection := &dynamodb.Projection{
+		ProjectionType: aws.String(stringOrDefault(index.ProjectionType, dynamodb.ProjectionTypeAll)),
+	}
+	if len(index.NonKeyAttributes) > 0 {
+		projection.NonKeyAttributes = aws.StringSlice(index.NonKeyAttributes)
+	}
+	return projection
+}
+
+func sortedTags(tags map[string]string) []*dynamodb.Tag {
+	result := make([]*dynamodb.Tag, 0, len(tags))
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, &dynamodb.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return result
+}
+
+func int64OrDefault(value int64, defaultValue int64) int64 {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func stringOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
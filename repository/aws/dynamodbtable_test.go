@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func init() {
+	tableStatusPollInterval = time.Millisecond
+}
+
+var testTableSpec = TableSpec{
+	OnDemand: true,
+	GlobalSecondaryIndexes: []GlobalSecondaryIndex{
+		{Name: "byOwner", PartitionKey: "owner", SortKey: "createdAt"},
+	},
+	TTLAttribute:   "expiresAt",
+	StreamViewType: dynamodb.StreamViewTypeNewAndOldImages,
+	SSE:            true,
+	Tags:           map[string]string{"team": "toolbox"},
+}
+
+func Test_EnsureTable_Creates_Table(t *testing.T) {
+	mock := newMockDynamoDB()
+	mock.pendingDescribes = 3
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), testTableSpec)
+	checkError(err, t)
+
+	if len(mock.creates) != 1 {
+		t.Fatalf("Expected 1 create but found %d", len(mock.creates))
+	}
+	input := mock.creates[0]
+	if *input.BillingMode != dynamodb.BillingModePayPerRequest || input.ProvisionedThroughput != nil {
+		t.Errorf("Expected on demand billing but found %+v", input)
+	}
+	if len(input.AttributeDefinitions) != 3 || len(input.GlobalSecondaryIndexes) != 1 {
+		t.Errorf("Expected index and its attributes but found %+v", input)
+	}
+	if !*input.StreamSpecification.StreamEnabled || !*input.SSESpecification.Enabled || len(input.Tags) != 1 {
+		t.Errorf("Expected stream, encryption and tags but found %+v", input)
+	}
+	if !isActive(mock.tables[testTableName]) {
+		t.Error("Expected table to be active")
+	}
+	if *mock.ttl[testTableName].AttributeName != "expiresAt" {
+		t.Errorf("Expected time to live on expiresAt but found %+v", mock.ttl[testTableName])
+	}
+}
+
+func Test_EnsureTable_Default_Spec(t *testing.T) {
+	mock := newMockDynamoDB()
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{})
+	checkError(err, t)
+
+	throughput := mock.creates[0].ProvisionedThroughput
+	if *throughput.ReadCapacityUnits != defaultCapacityUnits || *throughput.WriteCapacityUnits != defaultCapacityUnits {
+		t.Errorf("Expected default throughput but found %+v", throughput)
+	}
+}
+
+func Test_EnsureTable_Updates_Existing_Table(t *testing.T) {
+	mock := newMockDynamoDB()
+	checkError(EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{}), t)
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), testTableSpec)
+	checkError(err, t)
+
+	// billing mode, stream, encryption and index are separate updates
+	if len(mock.updates) != 4 {
+		t.Errorf("Expected 4 updates but found %+v", mock.updates)
+	}
+	if len(mock.tables[testTableName].GlobalSecondaryIndexes) != 1 {
+		t.Errorf("Expected created index but found %+v", mock.tables[testTableName])
+	}
+	if mock.tags["arn:aws:dynamodb:table/"+testTableName]["team"] != "toolbox" {
+		t.Errorf("Expected tags but found %+v", mock.tags)
+	}
+}
+
+func Test_EnsureTable_Unchanged_Table(t *testing.T) {
+	mock := newMockDynamoDB()
+	spec := TableSpec{OnDemand: true, TTLAttribute: "expiresAt"}
+	checkError(EnsureTable(mock, testTableName, defaultKeySchema(), spec), t)
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), spec)
+	checkError(err, t)
+
+	if len(mock.updates) != 0 {
+		t.Errorf("Expected no updates but found %+v", mock.updates)
+	}
+}
+
+func Test_EnsureTable_Keeps_Billing_Of_Existing_Table(t *testing.T) {
+	mock := newMockDynamoDB()
+	checkError(EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{OnDemand: true}), t)
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{})
+	checkError(err, t)
+
+	if len(mock.updates) != 0 {
+		t.Errorf("Expected no updates but found %+v", mock.updates)
+	}
+}
+
+func Test_EnsureTable_Key_Schema_Mismatch(t *testing.T) {
+	mock := newMockDynamoDB()
+	checkError(EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{}), t)
+
+	err := EnsureTable(mock, testTableName, compositeKeySchema, TableSpec{})
+
+	checkFailure(err, t)
+}
+
+func Test_EnsureTable_Timeout(t *testing.T) {
+	mock := newMockDynamoDB()
+	mock.pendingDescribes = 1000
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{WaitTimeout: 10 * time.Millisecond})
+
+	checkFailure(err, t)
+}
+
+func Test_EnsureTable_Time_To_Live_Conflict(t *testing.T) {
+	mock := newMockDynamoDB()
+	mock.ttl[testTableName] = &dynamodb.TimeToLiveDescription{
+		AttributeName:    aws.String("other"),
+		TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusEnabled),
+	}
+
+	err := EnsureTable(mock, testTableName, defaultKeySchema(), TableSpec{TTLAttribute: "expiresAt"})
+
+	checkFailure(err, t)
+}
+
+func Test_NewDynamoDBRepoWithConnection(t *testing.T) {
+	mock := newMockDynamoDB()
+
+	repo, err := NewDynamoDBRepoWithConnection(mock, testTableName, mockedItem.ToStruct,
+		WithTableSpec(TableSpec{OnDemand: true}))
+	checkError(err, t)
+
+	if repo == nil || len(mock.creates) != 1 {
+		t.Errorf("Expected repository and created table but found %+v", mock.creates)
+	}
+}
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+const (
+	// maximum length of SSM parameter names
+	maxSSMNameLength = 1011
+	// maximum number of levels of the SSM parameter hierarchy
+	maxSSMHierarchyDepth = 15
+	// maximum size of DynamoDB partition keys in bytes
+	maxDynamoDBKeyLength = 2048
+)
+
+// SSMKeyPolicy escapes keys of a SSMParameterStoreRepo. Parameter names only allow letters,
+// digits and the characters _.-/ - all other bytes are escaped by _ and their hex value.
+// The / is escaped as well, so each key is a single level below the path of the repository.
+type SSMKeyPolicy struct {
+	path    string
+	escaper repository.EscapingKeyPolicy
+}
+
+// NewSSMKeyPolicy creates a policy for keys stored below the path. An error is returned if the
+// path leaves no space for keys.
+func NewSSMKeyPolicy(path string) (*SSMKeyPolicy, error) {
+	depth := len(strings.FieldsFunc(path, func(r rune) bool { return r == '/' }))
+	if depth >= maxSSMHierarchyDepth {
+		return nil, fmt.Errorf("path %s has %d levels but at most %d levels are allowed including the key", path, depth, maxSSMHierarchyDepth)
+	}
+	if len(path) >= maxSSMNameLength {
+		return nil, fmt.Errorf("path %s exceeds the maximum name length of %d", path, maxSSMNameLength)
+	}
+
+	return &SSMKeyPolicy{
+		path: path,
+		escaper: repository.EscapingKeyPolicy{
+			Allowed:    isSSMNameByte,
+			EscapeChar: '_',
+			MaxLength:  maxSSMNameLength - len(path),
+		},
+	}, nil
+}
+
+// Escape validates a key and escapes unsupported characters
+func (policy *SSMKeyPolicy) Escape(key string) (string, error) {
+	escaped, err := policy.escaper.Escape(key)
+	if err != nil {
+		return "", err
+	}
+	name := strings.ToLower(strings.TrimPrefix(policy.path+escaped, "/"))
+	if strings.HasPrefix(name, "aws") || strings.HasPrefix(name, "ssm") {
+		return "", repository.InvalidKeyError(key, "parameter names must not start with aws or ssm")
+	}
+	return escaped, nil
+}
+
+// Unescape converts a parameter name relative to the path into the original key
+func (policy *SSMKeyPolicy) Unescape(stored string) (string, error) {
+	return policy.escaper.Unescape(stored)
+}
+
+func isSSMNameByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') ||
+		b == '_' || b == '.' || b == '-'
+}
+
+// NewDynamoDBKeyPolicy creates a policy which validates the size of DynamoDB partition keys.
+// Keys are stored as is since DynamoDB supports all UTF-8 strings.
+func NewDynamoDBKeyPolicy() repository.KeyPolicy {
+	return repository.LengthKeyPolicy{
+		MaxLength: maxDynamoDBKeyLength,
+	}
+}
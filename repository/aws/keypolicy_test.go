@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+func Test_SSMKeyPolicy_Round_Trip(t *testing.T) {
+	policy, err := NewSSMKeyPolicy(testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip := func(key string) bool {
+		escaped, err := policy.Escape(key)
+		if err != nil {
+			return errors.Is(err, repository.ErrInvalidKey)
+		}
+		for i := 0; i < len(escaped); i++ {
+			if !isSSMNameByte(escaped[i]) {
+				return false
+			}
+		}
+		if len(testPath+escaped) > maxSSMNameLength {
+			return false
+		}
+		unescaped, err := policy.Unescape(escaped)
+		return err == nil && unescaped == key
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_SSMKeyPolicy_Invalid_Keys(t *testing.T) {
+	policy, err := NewSSMKeyPolicy("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "awsKey", "SSM.key", strings.Repeat("a", maxSSMNameLength)} {
+		if _, err := policy.Escape(key); !errors.Is(err, repository.ErrInvalidKey) {
+			t.Errorf("Expected %v for %q but found %v", repository.ErrInvalidKey, key, err)
+		}
+	}
+}
+
+func Test_SSMKeyPolicy_Path_Too_Deep(t *testing.T) {
+	_, err := NewSSMKeyPolicy(strings.Repeat("/level", maxSSMHierarchyDepth) + "/")
+
+	if err == nil {
+		t.Error("Expected error for path without space for keys")
+	}
+}
+
+func Test_SSMKeyPolicy_Repo(t *testing.T) {
+	policy, err := NewSSMKeyPolicy(testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := createFake(t)
+	repo := repository.NewKeyPolicyRepo(NewStringSSMParameterStoreRepo(testPath, fake), policy)
+
+	_, err = repo.Save("a/b c", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := repo.FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := fake.Parameters()[testPath+"a_2Fb_20c"]; !ok {
+		t.Errorf("Expected escaped parameter name but found %+v", fake.Parameters())
+	}
+	found := false
+	for _, item := range items {
+		found = found || item.Key == "a/b c"
+	}
+	if !found {
+		t.Errorf("Expected unescaped key in %+v", items)
+	}
+}
+
+func Test_DynamoDBKeyPolicy(t *testing.T) {
+	policy := NewDynamoDBKeyPolicy()
+
+	_, err := policy.Escape(strings.Repeat("a", maxDynamoDBKeyLength+1))
+
+	if !errors.Is(err, repository.ErrInvalidKey) {
+		t.Errorf("Expected %v but found %v", repository.ErrInvalidKey, err)
+	}
+}
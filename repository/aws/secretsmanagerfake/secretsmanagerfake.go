@@ -0,0 +1,562 @@
+// Package secretsmanagerfake provides an in-memory implementation of the Secrets Manager API
+// for tests. It covers the operations used by the SecretsManagerRepo including version stages,
+// rotations and scheduled deletions. Calls of other operations panic.
+package secretsmanagerfake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+const (
+	stageCurrent  = "AWSCURRENT"
+	stagePending  = "AWSPENDING"
+	stagePrevious = "AWSPREVIOUS"
+
+	defaultRecoveryWindowInDays = 30
+	defaultRotationDays         = 30
+	defaultPageSize             = 100
+)
+
+// SecretsManager is an in-memory Secrets Manager which is safe for concurrent use
+type SecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	// Rotator creates the new value of a secret when it is rotated. If it is not set,
+	// rotations fail like for secrets without rotation function.
+	Rotator func(name string, current string) (string, error)
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+	// PageSize limits the secrets returned per page of ListSecrets, defaults to 100
+	PageSize int
+
+	mutex         sync.Mutex
+	secrets       map[string]*secret
+	versionNumber int
+}
+
+type secret struct {
+	name             string
+	createdDate      time.Time
+	lastChangedDate  time.Time
+	lastRotatedDate  *time.Time
+	nextRotationDate *time.Time
+	rotationEnabled  bool
+	rotationDays     int64
+	deletedDate      *time.Time
+	tags             map[string]string
+	versions         map[string]*version
+}
+
+type version struct {
+	id          string
+	value       string
+	createdDate time.Time
+	stages      []string
+}
+
+// New creates an empty fake
+func New() *SecretsManager {
+	return &SecretsManager{
+		secrets: map[string]*secret{},
+	}
+}
+
+// CreateSecret creates a secret with the value as current version
+func (fake *SecretsManager) CreateSecret(input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	name := aws.StringValue(input.Name)
+	if existing, ok := fake.secrets[name]; ok {
+		if existing.deletedDate != nil {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, fmt.Sprintf("secret %s is scheduled for deletion", name), nil)
+		}
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, fmt.Sprintf("secret %s already exists", name), nil)
+	}
+	if name == "" {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "name is required", nil)
+	}
+
+	now := fake.now()
+	created := &secret{
+		name:            name,
+		createdDate:     now,
+		lastChangedDate: now,
+		tags:            map[string]string{},
+		versions:        map[string]*version{},
+	}
+	for _, tag := range input.Tags {
+		created.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	output := &secretsmanager.CreateSecretOutput{
+		ARN:  aws.String(toARN(name)),
+		Name: aws.String(name),
+	}
+	if input.SecretString != nil {
+		added := fake.addVersion(created, *input.SecretString, []string{stageCurrent})
+		output.VersionId = aws.String(added.id)
+	}
+	fake.secrets[name] = created
+	return output, nil
+}
+
+// GetSecretValue returns the version with the id or stage, AWSCURRENT by default
+func (fake *SecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.findActive(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	stage := aws.StringValue(input.VersionStage)
+	if stage == "" && input.VersionId == nil {
+		stage = stageCurrent
+	}
+
+	for _, candidate := range found.versions {
+		if input.VersionId != nil && candidate.id != *input.VersionId {
+			continue
+		}
+		if stage != "" && !hasStage(candidate.stages, stage) {
+			continue
+		}
+		return &secretsmanager.GetSecretValueOutput{
+			ARN:           aws.String(toARN(found.name)),
+			Name:          aws.String(found.name),
+			CreatedDate:   aws.Time(candidate.createdDate),
+			SecretString:  aws.String(candidate.value),
+			VersionId:     aws.String(candidate.id),
+			VersionStages: aws.StringSlice(candidate.stages),
+		}, nil
+	}
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, fmt.Sprintf("secret %s has no matching version", found.name), nil)
+}
+
+// PutSecretValue adds a version to a secret. The stages default to AWSCURRENT.
+func (fake *SecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.findActive(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	if input.SecretString == nil {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "secret string is required", nil)
+	}
+	stages := aws.StringValueSlice(input.VersionStages)
+	if len(stages) == 0 {
+		stages = []string{stageCurrent}
+	}
+
+	added := fake.addVersion(found, *input.SecretString, stages)
+	return &secretsmanager.PutSecretValueOutput{
+		ARN:           aws.String(toARN(found.name)),
+		Name:          aws.String(found.name),
+		VersionId:     aws.String(added.id),
+		VersionStages: aws.StringSlice(added.stages),
+	}, nil
+}
+
+// DescribeSecret returns the details of a secret including secrets scheduled for deletion
+func (fake *SecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.find(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	entry := toListEntry(found)
+	return &secretsmanager.DescribeSecretOutput{
+		ARN:                entry.ARN,
+		Name:               entry.Name,
+		CreatedDate:        entry.CreatedDate,
+		LastChangedDate:    entry.LastChangedDate,
+		LastRotatedDate:    entry.LastRotatedDate,
+		NextRotationDate:   entry.NextRotationDate,
+		RotationEnabled:    entry.RotationEnabled,
+		RotationRules:      entry.RotationRules,
+		DeletedDate:        entry.DeletedDate,
+		Tags:               entry.Tags,
+		VersionIdsToStages: entry.SecretVersionsToStages,
+	}, nil
+}
+
+// ListSecrets returns a page of secrets ordered by name. Only the name filter is supported,
+// which matches secrets of which the name starts with one of the values.
+func (fake *SecretsManager) ListSecrets(input *secretsmanager.ListSecretsInput) (*secretsmanager.ListSecretsOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	prefixes := []string{}
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Key) != secretsmanager.FilterNameStringTypeName {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, fmt.Sprintf("filter %s is not supported", aws.StringValue(filter.Key)), nil)
+		}
+		prefixes = append(prefixes, aws.StringValueSlice(filter.Values)...)
+	}
+
+	names := []string{}
+	for name, candidate := range fake.secrets {
+		if candidate.deletedDate != nil && !aws.BoolValue(input.IncludePlannedDeletion) {
+			continue
+		}
+		if len(prefixes) > 0 && !hasPrefix(name, prefixes) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	start := 0
+	if input.NextToken != nil {
+		parsed, err := strconv.Atoi(*input.NextToken)
+		if err != nil || parsed < 0 || parsed > len(names) {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidNextTokenException, "invalid next token", nil)
+		}
+		start = parsed
+	}
+	pageSize := fake.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if input.MaxResults != nil && int(*input.MaxResults) < pageSize {
+		pageSize = int(*input.MaxResults)
+	}
+	end := start + pageSize
+	if end > len(names) {
+		end = len(names)
+	}
+
+	output := &secretsmanager.ListSecretsOutput{
+		SecretList: []*secretsmanager.SecretListEntry{},
+	}
+	for _, name := range names[start:end] {
+		output.SecretList = append(output.SecretList, toListEntry(fake.secrets[name]))
+	}
+	if end < len(names) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+// ListSecretsPages iterates over all pages of ListSecrets
+func (fake *SecretsManager) ListSecretsPages(input *secretsmanager.ListSecretsInput, fn func(*secretsmanager.ListSecretsOutput, bool) bool) error {
+	pageInput := *input
+	for {
+		output, err := fake.ListSecrets(&pageInput)
+		if err != nil {
+			return err
+		}
+		lastPage := output.NextToken == nil
+		if !fn(output, lastPage) || lastPage {
+			return nil
+		}
+		pageInput.NextToken = output.NextToken
+	}
+}
+
+// DeleteSecret removes a secret immediately if ForceDeleteWithoutRecovery is set. Otherwise the
+// secret is scheduled for deletion and can be restored with RestoreSecret.
+func (fake *SecretsManager) DeleteSecret(input *secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.find(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	force := aws.BoolValue(input.ForceDeleteWithoutRecovery)
+	if force && input.RecoveryWindowInDays != nil {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "recovery window and force delete can not be combined", nil)
+	}
+	recoveryWindow := int64(defaultRecoveryWindowInDays)
+	if input.RecoveryWindowInDays != nil {
+		recoveryWindow = *input.RecoveryWindowInDays
+		if recoveryWindow < 7 || recoveryWindow > 30 {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "recovery window has to be between 7 and 30 days", nil)
+		}
+	}
+
+	now := fake.now()
+	output := &secretsmanager.DeleteSecretOutput{
+		ARN:  aws.String(toARN(found.name)),
+		Name: aws.String(found.name),
+	}
+	if force {
+		delete(fake.secrets, found.name)
+		output.DeletionDate = aws.Time(now)
+		return output, nil
+	}
+	if found.deletedDate == nil {
+		found.deletedDate = aws.Time(now)
+	}
+	output.DeletionDate = aws.Time(found.deletedDate.AddDate(0, 0, int(recoveryWindow)))
+	return output, nil
+}
+
+// RestoreSecret cancels the scheduled deletion of a secret
+func (fake *SecretsManager) RestoreSecret(input *secretsmanager.RestoreSecretInput) (*secretsmanager.RestoreSecretOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.find(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	found.deletedDate = nil
+	return &secretsmanager.RestoreSecretOutput{
+		ARN:  aws.String(toARN(found.name)),
+		Name: aws.String(found.name),
+	}, nil
+}
+
+// TagResource adds tags to a secret, tags with the same key are overwritten
+func (fake *SecretsManager) TagResource(input *secretsmanager.TagResourceInput) (*secretsmanager.TagResourceOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.findActive(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range input.Tags {
+		found.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return new(secretsmanager.TagResourceOutput), nil
+}
+
+// RotateSecret enables the rotation of a secret and rotates it with the Rotator. The new value
+// is stored as AWSPENDING version which then becomes AWSCURRENT, like a rotation function does.
+// Rotations always complete immediately.
+func (fake *SecretsManager) RotateSecret(input *secretsmanager.RotateSecretInput) (*secretsmanager.RotateSecretOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.findActive(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	if fake.Rotator == nil {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, fmt.Sprintf("secret %s has no rotation function", found.name), nil)
+	}
+	current := findStage(found, stageCurrent)
+	if current == nil {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, fmt.Sprintf("secret %s has no current version", found.name), nil)
+	}
+	if pending := findStage(found, stagePending); pending != nil && pending != current {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, fmt.Sprintf("a previous rotation of secret %s is not complete", found.name), nil)
+	}
+
+	value, err := fake.Rotator(found.name, current.value)
+	if err != nil {
+		return nil, err
+	}
+	rotated := fake.addVersion(found, value, []string{stagePending})
+	fake.moveStage(found, stageCurrent, rotated)
+	rotated.stages = removeStage(rotated.stages, stagePending)
+
+	if input.RotationRules != nil && input.RotationRules.AutomaticallyAfterDays != nil {
+		found.rotationDays = *input.RotationRules.AutomaticallyAfterDays
+	}
+	if found.rotationDays == 0 {
+		found.rotationDays = defaultRotationDays
+	}
+	now := fake.now()
+	found.rotationEnabled = true
+	found.lastRotatedDate = aws.Time(now)
+	found.nextRotationDate = aws.Time(now.AddDate(0, 0, int(found.rotationDays)))
+	return &secretsmanager.RotateSecretOutput{
+		ARN:       aws.String(toARN(found.name)),
+		Name:      aws.String(found.name),
+		VersionId: aws.String(rotated.id),
+	}, nil
+}
+
+// UpdateSecretVersionStage moves a stage between versions. If the stage is attached to a
+// version, this version has to be passed as RemoveFromVersionId.
+func (fake *SecretsManager) UpdateSecretVersionStage(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	found, err := fake.findActive(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	stage := aws.StringValue(input.VersionStage)
+	attached := findStage(found, stage)
+	if attached != nil && attached.id != aws.StringValue(input.RemoveFromVersionId) {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, fmt.Sprintf("stage %s is attached to version %s", stage, attached.id), nil)
+	}
+	if input.RemoveFromVersionId != nil && attached == nil {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, fmt.Sprintf("stage %s is not attached to version %s", stage, *input.RemoveFromVersionId), nil)
+	}
+
+	if input.MoveToVersionId == nil {
+		if attached == nil {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "either the version to move to or to remove from is required", nil)
+		}
+		attached.stages = removeStage(attached.stages, stage)
+	} else {
+		target, ok := found.versions[*input.MoveToVersionId]
+		if !ok {
+			return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, fmt.Sprintf("version %s of secret %s not found", *input.MoveToVersionId, found.name), nil)
+		}
+		fake.moveStage(found, stage, target)
+	}
+	found.lastChangedDate = fake.now()
+	return &secretsmanager.UpdateSecretVersionStageOutput{
+		ARN:  aws.String(toARN(found.name)),
+		Name: aws.String(found.name),
+	}, nil
+}
+
+// addVersion stores a new version with the stages, which are removed from other versions
+func (fake *SecretsManager) addVersion(target *secret, value string, stages []string) *version {
+	fake.versionNumber++
+	added := &version{
+		id:          fmt.Sprintf("00000000-0000-0000-0000-%012d", fake.versionNumber),
+		value:       value,
+		createdDate: fake.now(),
+	}
+	target.versions[added.id] = added
+	for _, stage := range stages {
+		fake.moveStage(target, stage, added)
+	}
+	target.lastChangedDate = added.createdDate
+	return added
+}
+
+// moveStage attaches a stage to a version. If AWSCURRENT is moved, the version which had
+// the stage before becomes AWSPREVIOUS.
+func (fake *SecretsManager) moveStage(target *secret, stage string, to *version) {
+	from := findStage(target, stage)
+	if from == to {
+		return
+	}
+	if from != nil {
+		from.stages = removeStage(from.stages, stage)
+		if stage == stageCurrent {
+			fake.moveStage(target, stagePrevious, from)
+		}
+	}
+	to.stages = append(to.stages, stage)
+}
+
+// find returns a secret by name or ARN
+func (fake *SecretsManager) find(secretID *string) (*secret, error) {
+	name := strings.TrimPrefix(aws.StringValue(secretID), toARN(""))
+	found, ok := fake.secrets[name]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, fmt.Sprintf("secret %s not found", name), nil)
+	}
+	return found, nil
+}
+
+// findActive returns a secret which is not scheduled for deletion
+func (fake *SecretsManager) findActive(secretID *string) (*secret, error) {
+	found, err := fake.find(secretID)
+	if err != nil {
+		return nil, err
+	}
+	if found.deletedDate != nil {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidRequestException, fmt.Sprintf("secret %s is scheduled for deletion", found.name), nil)
+	}
+	return found, nil
+}
+
+func (fake *SecretsManager) now() time.Time {
+	if fake.Now != nil {
+		return fake.Now()
+	}
+	return time.Now()
+}
+
+func findStage(target *secret, stage string) *version {
+	for _, candidate := range target.versions {
+		if hasStage(candidate.stages, stage) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func hasStage(stages []string, stage string) bool {
+	for _, candidate := range stages {
+		if candidate == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func removeStage(stages []string, stage string) []string {
+	result := []string{}
+	for _, candidate := range stages {
+		if candidate != stage {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+func hasPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func toListEntry(found *secret) *secretsmanager.SecretListEntry {
+	entry := &secretsmanager.SecretListEntry{
+		ARN:                    aws.String(toARN(found.name)),
+		Name:                   aws.String(found.name),
+		CreatedDate:            aws.Time(found.createdDate),
+		LastChangedDate:        aws.Time(found.lastChangedDate),
+		LastRotatedDate:        found.lastRotatedDate,
+		NextRotationDate:       found.nextRotationDate,
+		RotationEnabled:        aws.Bool(found.rotationEnabled),
+		DeletedDate:            found.deletedDate,
+		SecretVersionsToStages: map[string][]*string{},
+	}
+	if found.rotationEnabled {
+		entry.RotationRules = &secretsmanager.RotationRulesType{
+			AutomaticallyAfterDays: aws.Int64(found.rotationDays),
+		}
+	}
+	for id, candidate := range found.versions {
+		if len(candidate.stages) > 0 {
+			entry.SecretVersionsToStages[id] = aws.StringSlice(candidate.stages)
+		}
+	}
+	names := make([]string, 0, len(found.tags))
+	for name := range found.tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry.Tags = append(entry.Tags, &secretsmanager.Tag{
+			Key:   aws.String(name),
+			Value: aws.String(found.tags[name]),
+		})
+	}
+	return entry
+}
+
+func toARN(name string) string {
+	return "arn:aws:secretsmanager:us-east-1:000000000000:secret:" + name
+}
@@ -0,0 +1,179 @@
+package secretsmanagerfake
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+const testName = "testName"
+
+func createFake(t *testing.T) *SecretsManager {
+	fake := New()
+	_, err := fake.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(testName),
+		SecretString: aws.String("first"),
+	})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	return fake
+}
+
+func findStageValue(t *testing.T, fake *SecretsManager, stage string) string {
+	output, err := fake.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(testName),
+		VersionStage: aws.String(stage),
+	})
+	if err != nil {
+		t.Fatalf("Expected nil for stage %s but found error: %+s", stage, err)
+	}
+	return aws.StringValue(output.SecretString)
+}
+
+func checkErrorCode(t *testing.T, err error, code string) {
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != code {
+		t.Errorf("Expected error code %s but found %v", code, err)
+	}
+}
+
+func Test_Put_Moves_Stages(t *testing.T) {
+	fake := createFake(t)
+
+	_, err := fake.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(testName),
+		SecretString: aws.String("second"),
+	})
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if value := findStageValue(t, fake, stageCurrent); value != "second" {
+		t.Errorf("Expected current second but found %s", value)
+	}
+	if value := findStageValue(t, fake, stagePrevious); value != "first" {
+		t.Errorf("Expected previous first but found %s", value)
+	}
+}
+
+func Test_Update_Secret_Version_Stage(t *testing.T) {
+	fake := createFake(t)
+	pending, err := fake.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(testName),
+		SecretString:  aws.String("pending"),
+		VersionStages: aws.StringSlice([]string{stagePending}),
+	})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	current, err := fake.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String(testName)})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+
+	_, err = fake.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:        aws.String(testName),
+		VersionStage:    aws.String(stageCurrent),
+		MoveToVersionId: pending.VersionId,
+	})
+	checkErrorCode(t, err, secretsmanager.ErrCodeInvalidParameterException)
+
+	_, err = fake.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(testName),
+		VersionStage:        aws.String(stageCurrent),
+		MoveToVersionId:     pending.VersionId,
+		RemoveFromVersionId: current.VersionId,
+	})
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if value := findStageValue(t, fake, stageCurrent); value != "pending" {
+		t.Errorf("Expected current pending but found %s", value)
+	}
+	if value := findStageValue(t, fake, stagePrevious); value != "first" {
+		t.Errorf("Expected previous first but found %s", value)
+	}
+}
+
+func Test_Rotate_Secret(t *testing.T) {
+	fake := createFake(t)
+
+	_, err := fake.RotateSecret(&secretsmanager.RotateSecretInput{SecretId: aws.String(testName)})
+	checkErrorCode(t, err, secretsmanager.ErrCodeInvalidRequestException)
+
+	fake.Rotator = func(name string, current string) (string, error) {
+		return current + "Rotated", nil
+	}
+	_, err = fake.RotateSecret(&secretsmanager.RotateSecretInput{SecretId: aws.String(testName)})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	description, err := fake.DescribeSecret(&secretsmanager.DescribeSecretInput{SecretId: aws.String(testName)})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+
+	if value := findStageValue(t, fake, stageCurrent); value != "firstRotated" {
+		t.Errorf("Expected current firstRotated but found %s", value)
+	}
+	if !aws.BoolValue(description.RotationEnabled) || description.NextRotationDate == nil {
+		t.Errorf("Expected rotation to be enabled but found %+v", description)
+	}
+}
+
+func Test_Delete_And_Restore(t *testing.T) {
+	fake := createFake(t)
+
+	_, err := fake.DeleteSecret(&secretsmanager.DeleteSecretInput{SecretId: aws.String(testName)})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	_, err = fake.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String(testName)})
+	checkErrorCode(t, err, secretsmanager.ErrCodeInvalidRequestException)
+	_, err = fake.RestoreSecret(&secretsmanager.RestoreSecretInput{SecretId: aws.String(testName)})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+
+	if value := findStageValue(t, fake, stageCurrent); value != "first" {
+		t.Errorf("Expected first but found %s", value)
+	}
+}
+
+func Test_List_Secrets_Pages(t *testing.T) {
+	fake := New()
+	fake.PageSize = 2
+	for _, name := range []string{"a/1", "a/2", "a/3", "b/1"} {
+		_, err := fake.CreateSecret(&secretsmanager.CreateSecretInput{
+			Name:         aws.String(name),
+			SecretString: aws.String(name),
+		})
+		if err != nil {
+			t.Fatalf("Expected nil but found error: %+s", err)
+		}
+	}
+
+	names := []string{}
+	pages := 0
+	err := fake.ListSecretsPages(&secretsmanager.ListSecretsInput{
+		Filters: []*secretsmanager.Filter{{
+			Key:    aws.String(secretsmanager.FilterNameStringTypeName),
+			Values: aws.StringSlice([]string{"a/"}),
+		}},
+	}, func(page *secretsmanager.ListSecretsOutput, lastPage bool) bool {
+		pages++
+		for _, entry := range page.SecretList {
+			names = append(names, aws.StringValue(entry.Name))
+		}
+		return true
+	})
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if pages != 2 || len(names) != 3 || names[0] != "a/1" || names[2] != "a/3" {
+		t.Errorf("Expected a/1 to a/3 on 2 pages but found %v on %d pages", names, pages)
+	}
+}
@@ -0,0 +1,377 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+// Version stages which are maintained by Secrets Manager during a rotation
+const (
+	VersionStageCurrent  = "AWSCURRENT"
+	VersionStagePending  = "AWSPENDING"
+	VersionStagePrevious = "AWSPREVIOUS"
+)
+
+// SecretsManagerRepo stores entries as secrets in AWS Secrets Manager. The name of a secret is
+// the prefix followed by the key. Reads return the version of the configured stage, which is
+// AWSCURRENT by default.
+//
+// Secrets Manager identifies versions by ids, hence the version in the metadata is not set.
+type SecretsManagerRepo struct {
+	mutex            sync.Mutex
+	prefix           string
+	client           secretsmanageriface.SecretsManagerAPI
+	toStructFunction func(jsonString string) (interface{}, error)
+	versionStage     string
+	forceDelete      bool
+	cacheDuration    time.Duration
+	cache            map[string]cachedSecret
+	now              func() time.Time
+}
+
+// cachedSecret is a secret read from Secrets Manager, the item is valid until expiresAt
+type cachedSecret struct {
+	versionID string
+	item      repository.KeyValuePair
+	expiresAt time.Time
+}
+
+// SecretsManagerOption configures a SecretsManagerRepo
+type SecretsManagerOption func(*SecretsManagerRepo)
+
+// WithVersionStage reads and writes the version with the stage, e.g. VersionStagePending
+// to access the new secret within a rotation function. Save always creates the secret
+// with the value as current version.
+func WithVersionStage(stage string) SecretsManagerOption {
+	return func(repo *SecretsManagerRepo) {
+		repo.versionStage = stage
+	}
+}
+
+// WithSecretCache keeps secrets in memory for the duration. Cached secrets of which rotation
+// is enabled expire at the next rotation date at the latest. An expired secret is only read
+// again if the version of its stage changed in the meantime.
+func WithSecretCache(duration time.Duration) SecretsManagerOption {
+	return func(repo *SecretsManagerRepo) {
+		repo.cacheDuration = duration
+	}
+}
+
+// WithForceDelete deletes secrets without recovery window. By default a deleted secret can be
+// restored for 30 days and its name can not be used for a new secret within this time.
+func WithForceDelete() SecretsManagerOption {
+	return func(repo *SecretsManagerRepo) {
+		repo.forceDelete = true
+	}
+}
+
+// NewSecretsManagerRepo creates a new instance of the repository
+// The repo can take structs and store them in serialized form.
+func NewSecretsManagerRepo(prefix string, client secretsmanageriface.SecretsManagerAPI, itemTemplate serialization.Serializable, options ...SecretsManagerOption) *SecretsManagerRepo {
+	return newSecretsManagerRepo(prefix, client, itemTemplate.ToStruct, options)
+}
+
+// NewStringSecretsManagerRepo creates a new instance of the repository
+// The repo stores the string without conversion.
+func NewStringSecretsManagerRepo(prefix string, client secretsmanageriface.SecretsManagerAPI, options ...SecretsManagerOption) *SecretsManagerRepo {
+	return newSecretsManagerRepo(prefix, client, func(jsonString string) (interface{}, error) {
+		return jsonString, nil
+	}, options)
+}
+
+func newSecretsManagerRepo(prefix string, client secretsmanageriface.SecretsManagerAPI, toStruct func(jsonString string) (interface{}, error), options []SecretsManagerOption) *SecretsManagerRepo {
+	repo := &SecretsManagerRepo{
+		prefix:           prefix,
+		client:           client,
+		toStructFunction: toStruct,
+		versionStage:     VersionStageCurrent,
+		cache:            map[string]cachedSecret{},
+		now:              time.Now,
+	}
+	for _, option := range options {
+		option(repo)
+	}
+	return repo
+}
+
+// FindAll returns all secrets of which the name starts with the prefix. Secrets without a
+// version in the configured stage are skipped.
+func (repo *SecretsManagerRepo) FindAll() ([]repository.KeyValuePair, error) {
+	results := []repository.KeyValuePair{}
+	entries := []*secretsmanager.SecretListEntry{}
+
+	input := &secretsmanager.ListSecretsInput{}
+	if repo.prefix != "" {
+		input.Filters = []*secretsmanager.Filter{{
+			Key:    aws.String(secretsmanager.FilterNameStringTypeName),
+			Values: aws.StringSlice([]string{repo.prefix}),
+		}}
+	}
+	err := repo.client.ListSecretsPages(input, func(page *secretsmanager.ListSecretsOutput, lastPage bool) bool {
+		for _, entry := range page.SecretList {
+			// the name filter also matches words within the name, hence the prefix is checked
+			if strings.HasPrefix(aws.StringValue(entry.Name), repo.prefix) {
+				entries = append(entries, entry)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		key := strings.TrimPrefix(aws.StringValue(entry.Name), repo.prefix)
+		if cached, ok := repo.fromCache(key); ok {
+			results = append(results, cached)
+			continue
+		}
+		versionID := stageVersionID(entry.SecretVersionsToStages, repo.versionStage)
+		if versionID == "" {
+			continue
+		}
+		item, err := repo.load(key, versionID, toSecretMetadata(entry.CreatedDate, entry.LastChangedDate, entry.Tags), entry.RotationEnabled, entry.NextRotationDate)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+	return results, nil
+}
+
+// Find returns the version of the configured stage of a secret
+func (repo *SecretsManagerRepo) Find(key string) (repository.KeyValuePair, error) {
+	if cached, ok := repo.fromCache(key); ok {
+		return cached, nil
+	}
+
+	secret, err := repo.client.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(repo.prefix + key),
+	})
+	if err != nil {
+		return repository.KeyValuePair{}, toNotFoundError(err, secretsmanager.ErrCodeResourceNotFoundException)
+	}
+	versionID := stageVersionID(secret.VersionIdsToStages, repo.versionStage)
+	if versionID == "" {
+		return repository.KeyValuePair{}, fmt.Errorf("secret %s has no version with stage %s: %w", repo.prefix+key, repo.versionStage, repository.ErrNotFound)
+	}
+	return repo.load(key, versionID, toSecretMetadata(secret.CreatedDate, secret.LastChangedDate, secret.Tags), secret.RotationEnabled, secret.NextRotationDate)
+}
+
+// Save creates a new secret, an error is returned if the secret already exists
+func (repo *SecretsManagerRepo) Save(key string, in interface{}) (repository.KeyValuePair, error) {
+	serialized, err := serialization.ToJSON(in)
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+
+	_, err = repo.client.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(repo.prefix + key),
+		SecretString: aws.String(serialized),
+	})
+	if err != nil {
+		return repository.KeyValuePair{}, toAlreadyExistsError(err, secretsmanager.ErrCodeResourceExistsException)
+	}
+	repo.invalidate(key)
+	return repo.toKeyValuePair(key, in), nil
+}
+
+// Overwrite stores a new version of a secret in the configured stage. Missing secrets are created.
+func (repo *SecretsManagerRepo) Overwrite(key string, in interface{}) (repository.KeyValuePair, error) {
+	serialized, err := serialization.ToJSON(in)
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+
+	_, err = repo.client.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(repo.prefix + key),
+		SecretString:  aws.String(serialized),
+		VersionStages: aws.StringSlice([]string{repo.versionStage}),
+	})
+	if isAWSErrorCode(err, secretsmanager.ErrCodeResourceNotFoundException) {
+		return repo.Save(key, in)
+	}
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	repo.invalidate(key)
+	return repo.toKeyValuePair(key, in), nil
+}
+
+// Delete schedules the deletion of a secret, see WithForceDelete
+func (repo *SecretsManagerRepo) Delete(key string) error {
+	input := &secretsmanager.DeleteSecretInput{
+		SecretId: aws.String(repo.prefix + key),
+	}
+	if repo.forceDelete {
+		input.ForceDeleteWithoutRecovery = aws.Bool(true)
+	}
+	_, err := repo.client.DeleteSecret(input)
+	repo.invalidate(key)
+	return toNotFoundError(err, secretsmanager.ErrCodeResourceNotFoundException)
+}
+
+// Tag adds tags to an existing secret
+func (repo *SecretsManagerRepo) Tag(key string, tags map[string]string) error {
+	input := &secretsmanager.TagResourceInput{
+		SecretId: aws.String(repo.prefix + key),
+	}
+	for name, value := range tags {
+		input.Tags = append(input.Tags, &secretsmanager.Tag{
+			Key:   aws.String(name),
+			Value: aws.String(value),
+		})
+	}
+	_, err := repo.client.TagResource(input)
+	repo.invalidate(key)
+	return err
+}
+
+// Rotate starts an immediate rotation of a secret with its configured rotation function
+func (repo *SecretsManagerRepo) Rotate(key string) error {
+	_, err := repo.client.RotateSecret(&secretsmanager.RotateSecretInput{
+		SecretId:          aws.String(repo.prefix + key),
+		RotateImmediately: aws.Bool(true),
+	})
+	repo.invalidate(key)
+	return err
+}
+
+// load returns a version of a secret. If the version is cached, only its expiration is renewed.
+func (repo *SecretsManagerRepo) load(key string, versionID string, metadata *repository.Metadata, rotationEnabled *bool, nextRotation *time.Time) (repository.KeyValuePair, error) {
+	repo.mutex.Lock()
+	cached, ok := repo.cache[key]
+	repo.mutex.Unlock()
+
+	item := cached.item
+	if !ok || cached.versionID != versionID {
+		output, err := repo.client.GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId:  aws.String(repo.prefix + key),
+			VersionId: aws.String(versionID),
+		})
+		if err != nil {
+			return repository.KeyValuePair{}, err
+		}
+		if output.SecretString == nil {
+			return repository.KeyValuePair{}, fmt.Errorf("secret %s has no string value", repo.prefix+key)
+		}
+		value, err := repo.toStructFunction(*output.SecretString)
+		if err != nil {
+			return repository.KeyValuePair{}, fmt.Errorf("could not decode secret %s: %w", repo.prefix+key, err)
+		}
+		item = repository.KeyValuePair{
+			Key:   key,
+			Value: value,
+		}
+	}
+	item.Metadata = metadata
+
+	if repo.cacheDuration > 0 {
+		now := repo.now()
+		expiresAt := now.Add(repo.cacheDuration)
+		if aws.BoolValue(rotationEnabled) && nextRotation != nil && nextRotation.Before(expiresAt) {
+			expiresAt = *nextRotation
+		}
+		repo.mutex.Lock()
+		repo.cache[key] = cachedSecret{
+			versionID: versionID,
+			item:      item,
+			expiresAt: expiresAt,
+		}
+		repo.mutex.Unlock()
+	}
+	return copyItem(item), nil
+}
+
+// fromCache returns a secret which is cached and not expired
+func (repo *SecretsManagerRepo) fromCache(key string) (repository.KeyValuePair, bool) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	cached, ok := repo.cache[key]
+	if !ok || !repo.now().Before(cached.expiresAt) {
+		return repository.KeyValuePair{}, false
+	}
+	return copyItem(cached.item), true
+}
+
+func (repo *SecretsManagerRepo) invalidate(key string) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	delete(repo.cache, key)
+}
+
+func (repo *SecretsManagerRepo) toKeyValuePair(key string, in interface{}) repository.KeyValuePair {
+	return repository.KeyValuePair{
+		Key:   key,
+		Value: in,
+		Metadata: &repository.Metadata{
+			UpdatedAt: repo.now(),
+		},
+	}
+}
+
+// copyItem copies the metadata of a cached item, so callers can not modify the cache
+func copyItem(item repository.KeyValuePair) repository.KeyValuePair {
+	if item.Metadata == nil {
+		return item
+	}
+	metadata := *item.Metadata
+	if metadata.Tags != nil {
+		metadata.Tags = make(map[string]string, len(item.Metadata.Tags))
+		for name, value := range item.Metadata.Tags {
+			metadata.Tags[name] = value
+		}
+	}
+	item.Metadata = &metadata
+	return item
+}
+
+// stageVersionID returns the id of the version which has the stage or an empty string
+func stageVersionID(versions map[string][]*string, stage string) string {
+	for versionID, stages := range versions {
+		for _, versionStage := range stages {
+			if aws.StringValue(versionStage) == stage {
+				return versionID
+			}
+		}
+	}
+	return ""
+}
+
+func toSecretMetadata(createdAt *time.Time, updatedAt *time.Time, tags []*secretsmanager.Tag) *repository.Metadata {
+	metadata := &repository.Metadata{
+		CreatedAt: aws.TimeValue(createdAt),
+		UpdatedAt: aws.TimeValue(updatedAt),
+	}
+	if len(tags) > 0 {
+		metadata.Tags = make(map[string]string, len(tags))
+		for _, tag := range tags {
+			metadata.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return metadata
+}
+
+// NewSecretsManagerSession creates a session for the region which uses the shared config.
+// The client is created with secretsmanager.New(session).
+func NewSecretsManagerSession(region string) (*session.Session, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: aws.String(region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize Secrets Manager session: %w", err)
+	}
+	return sess, nil
+}
@@ -0,0 +1,279 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/repository/aws/secretsmanagerfake"
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+var testSecretPrefix = "testPrefix/"
+
+// countingSecretsManager counts the reads of secrets
+type countingSecretsManager struct {
+	*secretsmanagerfake.SecretsManager
+	describes int
+	gets      int
+}
+
+func (client *countingSecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	client.describes++
+	return client.SecretsManager.DescribeSecret(input)
+}
+
+func (client *countingSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	client.gets++
+	return client.SecretsManager.GetSecretValue(input)
+}
+
+func createSecretsManagerFake(t *testing.T) *secretsmanagerfake.SecretsManager {
+	fake := secretsmanagerfake.New()
+	_, err := fake.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(testSecretPrefix + testKey),
+		SecretString: aws.String(testValue),
+	})
+	checkError(err, t)
+	return fake
+}
+
+func Test_Secret_Save_And_Find(t *testing.T) {
+	addedTestValue := serialization.MockItem{
+		MockString: "Test",
+	}
+	repo := NewSecretsManagerRepo(testSecretPrefix, secretsmanagerfake.New(), serialization.MockItem{})
+
+	_, err := repo.Save(testKey, addedTestValue)
+	checkError(err, t)
+	result, err := repo.Find(testKey)
+
+	checkError(err, t)
+	if result.Value != addedTestValue {
+		t.Errorf("Expected %v but found %v", addedTestValue, result.Value)
+	}
+	if result.Metadata.CreatedAt.IsZero() {
+		t.Error("Expected creation date to be set")
+	}
+}
+
+func Test_Secret_Save_Existing(t *testing.T) {
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, createSecretsManagerFake(t))
+
+	_, err := repo.Save(testKey, "new")
+
+	if !isAWSErrorCode(err, secretsmanager.ErrCodeResourceExistsException) {
+		t.Errorf("Expected ResourceExistsException but found %v", err)
+	}
+}
+
+func Test_Secret_Overwrite(t *testing.T) {
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, createSecretsManagerFake(t))
+
+	for _, key := range []string{testKey, "missing"} {
+		_, err := repo.Overwrite(key, "new")
+		checkError(err, t)
+		result, err := repo.Find(key)
+
+		checkError(err, t)
+		if result.Value != "new" {
+			t.Errorf("Expected new but found %v for key %s", result.Value, key)
+		}
+	}
+}
+
+func Test_Secret_Delete(t *testing.T) {
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, createSecretsManagerFake(t), WithForceDelete())
+
+	err := repo.Delete(testKey)
+	checkError(err, t)
+	_, err = repo.Find(testKey)
+
+	if !isAWSErrorCode(err, secretsmanager.ErrCodeResourceNotFoundException) {
+		t.Errorf("Expected ResourceNotFoundException but found %v", err)
+	}
+}
+
+func Test_Secret_Delete_Missing_Is_Not_Found(t *testing.T) {
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, createSecretsManagerFake(t))
+
+	err := repo.Delete("missing")
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected not found error but found %v", err)
+	}
+}
+
+func Test_Secret_Delete_With_Recovery(t *testing.T) {
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, createSecretsManagerFake(t))
+
+	err := repo.Delete(testKey)
+	checkError(err, t)
+	items, err := repo.FindAll()
+	checkError(err, t)
+	_, saveErr := repo.Save(testKey, testValue)
+
+	if len(items) != 0 {
+		t.Errorf("Expected no items but found %+v", items)
+	}
+	if !isAWSErrorCode(saveErr, secretsmanager.ErrCodeInvalidRequestException) {
+		t.Errorf("Expected InvalidRequestException but found %v", saveErr)
+	}
+}
+
+func Test_Secret_Find_All(t *testing.T) {
+	fake := createSecretsManagerFake(t)
+	fake.PageSize = 1
+	_, err := fake.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String("otherPrefix/" + testKey),
+		SecretString: aws.String(testValue),
+	})
+	checkError(err, t)
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, fake)
+	_, err = repo.Save(testKey+"2", testValue+"2")
+	checkError(err, t)
+
+	items, err := repo.FindAll()
+
+	checkError(err, t)
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items but found %+v", items)
+	}
+	if items[0].Key != testKey || items[0].Value != testValue || items[1].Key != testKey+"2" {
+		t.Errorf("Unexpected items %+v", items)
+	}
+}
+
+func Test_Secret_Tag(t *testing.T) {
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, createSecretsManagerFake(t))
+
+	err := repo.Tag(testKey, map[string]string{"owner": "me"})
+	checkError(err, t)
+	item, err := repo.Find(testKey)
+
+	checkError(err, t)
+	if item.Metadata.Tags["owner"] != "me" {
+		t.Errorf("Expected tag owner but found %+v", item.Metadata.Tags)
+	}
+}
+
+func Test_Secret_Version_Stage(t *testing.T) {
+	fake := createSecretsManagerFake(t)
+	currentRepo := NewStringSecretsManagerRepo(testSecretPrefix, fake)
+	pendingRepo := NewStringSecretsManagerRepo(testSecretPrefix, fake, WithVersionStage(VersionStagePending))
+
+	_, err := pendingRepo.Find(testKey)
+	if err == nil {
+		t.Error("Error should not be nil without pending version")
+	}
+	_, err = pendingRepo.Overwrite(testKey, "pending")
+	checkError(err, t)
+	current, err := currentRepo.Find(testKey)
+	checkError(err, t)
+	pending, err := pendingRepo.Find(testKey)
+	checkError(err, t)
+
+	if current.Value != testValue {
+		t.Errorf("Expected %s but found %v", testValue, current.Value)
+	}
+	if pending.Value != "pending" {
+		t.Errorf("Expected pending but found %v", pending.Value)
+	}
+}
+
+func Test_Secret_Cache(t *testing.T) {
+	client := &countingSecretsManager{SecretsManager: createSecretsManagerFake(t)}
+	now := time.Now()
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, client, WithSecretCache(time.Minute))
+	repo.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.Find(testKey)
+		checkError(err, t)
+	}
+	if client.describes != 1 || client.gets != 1 {
+		t.Errorf("Expected 1 describe and get but found %d and %d", client.describes, client.gets)
+	}
+
+	// an expired secret is only read again if it changed
+	now = now.Add(2 * time.Minute)
+	_, err := repo.Find(testKey)
+	checkError(err, t)
+	if client.describes != 2 || client.gets != 1 {
+		t.Errorf("Expected 2 describes and 1 get but found %d and %d", client.describes, client.gets)
+	}
+
+	_, err = client.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(testSecretPrefix + testKey),
+		SecretString: aws.String("changed"),
+	})
+	checkError(err, t)
+	item, err := repo.Find(testKey)
+	checkError(err, t)
+	if item.Value != testValue {
+		t.Errorf("Expected cached value %s but found %v", testValue, item.Value)
+	}
+
+	now = now.Add(2 * time.Minute)
+	item, err = repo.Find(testKey)
+	checkError(err, t)
+	if item.Value != "changed" {
+		t.Errorf("Expected changed but found %v", item.Value)
+	}
+}
+
+func Test_Secret_Cache_Refreshes_On_Rotation(t *testing.T) {
+	now := time.Now()
+	fake := createSecretsManagerFake(t)
+	fake.Now = func() time.Time { return now }
+	fake.Rotator = func(name string, current string) (string, error) {
+		return current + "Rotated", nil
+	}
+	_, err := fake.RotateSecret(&secretsmanager.RotateSecretInput{
+		SecretId:      aws.String(testSecretPrefix + testKey),
+		RotationRules: &secretsmanager.RotationRulesType{AutomaticallyAfterDays: aws.Int64(1)},
+	})
+	checkError(err, t)
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, fake, WithSecretCache(7*24*time.Hour))
+	repo.now = func() time.Time { return now }
+	_, err = repo.Find(testKey)
+	checkError(err, t)
+
+	// simulates the scheduled rotation
+	now = now.Add(25 * time.Hour)
+	_, err = fake.RotateSecret(&secretsmanager.RotateSecretInput{
+		SecretId: aws.String(testSecretPrefix + testKey),
+	})
+	checkError(err, t)
+	item, err := repo.Find(testKey)
+
+	checkError(err, t)
+	if item.Value != testValue+"RotatedRotated" {
+		t.Errorf("Expected rotated value but found %v", item.Value)
+	}
+}
+
+func Test_Secret_Rotate(t *testing.T) {
+	fake := createSecretsManagerFake(t)
+	repo := NewStringSecretsManagerRepo(testSecretPrefix, fake, WithSecretCache(time.Hour))
+	_, err := repo.Find(testKey)
+	checkError(err, t)
+
+	if err := repo.Rotate(testKey); err == nil {
+		t.Error("Error should not be nil without rotation function")
+	}
+	fake.Rotator = func(name string, current string) (string, error) {
+		return "rotated", nil
+	}
+	err = repo.Rotate(testKey)
+	checkError(err, t)
+	item, err := repo.Find(testKey)
+
+	checkError(err, t)
+	if item.Value != "rotated" {
+		t.Errorf("Expected rotated but found %v", item.Value)
+	}
+}
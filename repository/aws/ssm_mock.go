@@ -1,84 +1,31 @@
 package aws
 
 import (
-	"errors"
 	"fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
-	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/jo-hoe/serverless-toolbox/repository/aws/ssmfake"
 )
 
-// for mocking suggestion, refer to https://docs.aws.amazon.com/sdk-for-go/api/service/ssm/ssmiface/
-// Define a mock struct to be used in your unit tests.
-type mockSSM struct {
-	ssmiface.SSMAPI
-	mapItem map[string]interface{}
-	path    string
-}
-
-func NewMockSSM(path string, mapItem map[string]interface{}) *mockSSM {
-	return &mockSSM{
-		mapItem: mapItem,
-		path:    path,
-	}
-}
-
-func (mock *mockSSM) PutParameter(input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
-	if _, ok := mock.mapItem[*input.Name]; ok && !*input.Overwrite {
-		// return an error if overwrite is not on and item is already in mock
-		return nil, errors.New("error")
-	} else {
-		mock.mapItem[*input.Name] = *input.Value
-		return new(ssm.PutParameterOutput), nil
-	}
-}
-
-func (mock *mockSSM) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
-	result := new(ssm.GetParameterOutput)
-	result.Parameter = new(ssm.Parameter)
-	err := errors.New("error")
-
-	if val, ok := mock.mapItem[*input.Name]; ok {
-		value := fmt.Sprintf("%v", val)
-		result.Parameter.Value = &value
-		err = nil
-	} else {
-		result = nil
-	}
-
-	return result, err
-}
-
-func (mock *mockSSM) DeleteParameter(input *ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error) {
-	result := new(ssm.DeleteParameterOutput)
-	err := errors.New("error")
-
-	if _, ok := mock.mapItem[*input.Name]; ok {
-		err = nil
-	} else {
-		result = nil
-	}
-
-	return result, err
-}
-
-func (mock *mockSSM) GetParametersByPathPages(input *ssm.GetParametersByPathInput, fn func(*ssm.GetParametersByPathOutput, bool) bool) error {
-	err := errors.New("error")
-
-	if *input.Path == mock.path {
-		allitems := make([]*ssm.Parameter, 0)
-		for key, element := range mock.mapItem {
-			param := new(ssm.Parameter)
-			keyCopy := key
-			param.Name = &keyCopy
-			value := fmt.Sprintf("%v", element)
-			param.Value = &value
-			allitems = append(allitems, param)
+// NewMockSSM creates an in-memory Parameter Store containing the given parameters. The keys of
+// mapItem are the full parameter names, the values are stored formatted with %v as String
+// parameters. The path is not used anymore as the fake supports any path.
+// It panics if a parameter can not be created, e.g. names containing a slash have to start
+// with a slash like in Parameter Store.
+//
+// Deprecated: use ssmfake.New, which also supports versions, labels, tags and throttling.
+func NewMockSSM(path string, mapItem map[string]interface{}) *ssmfake.SSM {
+	fake := ssmfake.New()
+	for name, value := range mapItem {
+		_, err := fake.PutParameter(&ssm.PutParameterInput{
+			Name:  aws.String(name),
+			Value: aws.String(fmt.Sprintf("%v", value)),
+			Type:  aws.String(ssm.ParameterTypeString),
+		})
+		if err != nil {
+			panic(fmt.Sprintf("could not create parameter %s: %v", name, err))
 		}
-		fn(&ssm.GetParametersByPathOutput{
-			Parameters: allitems,
-		}, true)
-		err = nil
 	}
-	return err
+	return fake
 }
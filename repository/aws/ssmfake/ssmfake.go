@@ -0,0 +1,652 @@
+// Package ssmfake provides an in-memory implementation of the Parameter Store operations of the
+// SSM API for tests. It models versions, labels, tags, hierarchies and pagination and returns
+// the error codes of Parameter Store. Calls of other operations panic.
+package ssmfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+const (
+	// ErrCodeThrottling is returned for calls which are throttled, see Throttle
+	ErrCodeThrottling = "ThrottlingException"
+	// ErrCodeValidation is returned for requests which violate the constraints of Parameter Store
+	ErrCodeValidation = "ValidationException"
+
+	maxVersions           = 100
+	maxLabelsPerVersion   = 10
+	maxHierarchyLevels    = 15
+	maxPathPageSize       = 10
+	maxHistoryPageSize    = 50
+	maxStandardValueSize  = 4096
+	maxAdvancedValueSize  = 8192
+	defaultSecureStringID = "alias/aws/ssm"
+)
+
+// SSM is an in-memory Parameter Store which is safe for concurrent use.
+//
+// Parameter names containing a slash have to start with a slash like in Parameter Store,
+// e.g. "/service/database/host".
+type SSM struct {
+	ssmiface.SSMAPI
+
+	// BeforePut is called once prior to the next put and allows to simulate concurrent writers
+	BeforePut func(input *ssm.PutParameterInput)
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+	// PageSize limits the results per page of GetParametersByPath and GetParameterHistory
+	// below the limits of Parameter Store
+	PageSize int
+
+	mutex          sync.Mutex
+	parameters     map[string]*parameter
+	throttledCalls int
+}
+
+type parameter struct {
+	// versions ordered from oldest to newest
+	versions []*ssm.ParameterHistory
+	tags     map[string]string
+}
+
+// New creates an empty fake
+func New() *SSM {
+	return &SSM{
+		parameters: map[string]*parameter{},
+	}
+}
+
+// Throttle rejects the next calls with ErrCodeThrottling. Each page of a paginated operation
+// counts as a call.
+func (fake *SSM) Throttle(calls int) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.throttledCalls = calls
+}
+
+// Parameters returns the values of the latest versions of all parameters by name
+func (fake *SSM) Parameters() map[string]string {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	result := make(map[string]string, len(fake.parameters))
+	for name, stored := range fake.parameters {
+		result[name] = aws.StringValue(stored.latest().Value)
+	}
+	return result
+}
+
+// PutParameter stores a new version of a parameter
+func (fake *SSM) PutParameter(input *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	fake.mutex.Lock()
+	hook := fake.BeforePut
+	fake.BeforePut = nil
+	fake.mutex.Unlock()
+	if hook != nil {
+		hook(input)
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.Name)
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	stored, exists := fake.parameters[name]
+	if exists && !aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(ssm.ErrCodeParameterAlreadyExists, fmt.Sprintf("parameter %s already exists", name), nil)
+	}
+
+	parameterType := aws.StringValue(input.Type)
+	if parameterType == "" && exists {
+		parameterType = aws.StringValue(stored.latest().Type)
+	}
+	tier := aws.StringValue(input.Tier)
+	if tier == "" {
+		tier = ssm.ParameterTierStandard
+	}
+	policies, err := validatePut(input, parameterType, tier)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		stored = &parameter{tags: map[string]string{}}
+	} else if len(stored.versions) == maxVersions {
+		if len(stored.versions[0].Labels) > 0 {
+			return nil, awserr.New(ssm.ErrCodeParameterMaxVersionLimitExceeded, fmt.Sprintf("the oldest version of parameter %s has labels", name), nil)
+		}
+		stored.versions = stored.versions[1:]
+	}
+
+	version := int64(1)
+	if exists {
+		version = aws.Int64Value(stored.latest().Version) + 1
+	}
+	added := &ssm.ParameterHistory{
+		Name:             aws.String(name),
+		Value:            input.Value,
+		Version:          aws.Int64(version),
+		LastModifiedDate: aws.Time(fake.now()),
+		Type:             aws.String(parameterType),
+		Tier:             aws.String(tier),
+		Policies:         policies,
+		Description:      input.Description,
+		AllowedPattern:   input.AllowedPattern,
+		Labels:           []*string{},
+	}
+	if parameterType == ssm.ParameterTypeSecureString {
+		added.KeyId = aws.String(defaultSecureStringID)
+		if input.KeyId != nil {
+			added.KeyId = input.KeyId
+		}
+	}
+	stored.versions = append(stored.versions, added)
+	for _, tag := range input.Tags {
+		stored.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	fake.parameters[name] = stored
+
+	return &ssm.PutParameterOutput{
+		Tier:    aws.String(tier),
+		Version: aws.Int64(version),
+	}, nil
+}
+
+// GetParameter returns the latest version of a parameter. A version or label can be selected
+// by appending it to the name, e.g. "/service/key:3" or "/service/key:stable".
+func (fake *SSM) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.Name)
+	selector := ""
+	if index := strings.Index(name, ":"); index >= 0 {
+		name, selector = name[:index], name[index+1:]
+	}
+	stored, err := fake.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := stored.latest()
+	if selector != "" {
+		selected = stored.selectVersion(selector)
+		if selected == nil {
+			return nil, awserr.New(ssm.ErrCodeParameterVersionNotFound, fmt.Sprintf("version %s of parameter %s not found", selector, name), nil)
+		}
+	}
+	result := toParameter(selected)
+	if selector != "" {
+		result.Selector = aws.String(":" + selector)
+	}
+	return &ssm.GetParameterOutput{
+		Parameter: result,
+	}, nil
+}
+
+// GetParametersByPath returns a page of the parameters below the path ordered by name. Only
+// direct children of the path are returned if the request is not recursive.
+func (fake *SSM) GetParametersByPath(input *ssm.GetParametersByPathInput) (*ssm.GetParametersByPathOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	path := aws.StringValue(input.Path)
+	if !strings.HasPrefix(path, "/") {
+		return nil, awserr.New(ErrCodeValidation, fmt.Sprintf("path %s has to start with a slash", path), nil)
+	}
+	path = strings.TrimSuffix(path, "/") + "/"
+
+	names := []string{}
+	for name := range fake.parameters {
+		relative := strings.TrimPrefix(name, path)
+		if !strings.HasPrefix(name, path) || (!aws.BoolValue(input.Recursive) && strings.Contains(relative, "/")) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	start, end, err := fake.page(len(names), input.NextToken, input.MaxResults, maxPathPageSize)
+	if err != nil {
+		return nil, err
+	}
+	output := &ssm.GetParametersByPathOutput{
+		Parameters: []*ssm.Parameter{},
+	}
+	for _, name := range names[start:end] {
+		output.Parameters = append(output.Parameters, toParameter(fake.parameters[name].latest()))
+	}
+	if end < len(names) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+// GetParametersByPathPages iterates over all pages of GetParametersByPath
+func (fake *SSM) GetParametersByPathPages(input *ssm.GetParametersByPathInput, fn func(*ssm.GetParametersByPathOutput, bool) bool) error {
+	pageInput := *input
+	for {
+		output, err := fake.GetParametersByPath(&pageInput)
+		if err != nil {
+			return err
+		}
+		lastPage := output.NextToken == nil
+		if !fn(output, lastPage) || lastPage {
+			return nil
+		}
+		pageInput.NextToken = output.NextToken
+	}
+}
+
+// GetParameterHistory returns a page of the versions of a parameter ordered from oldest to newest
+func (fake *SSM) GetParameterHistory(input *ssm.GetParameterHistoryInput) (*ssm.GetParameterHistoryOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	stored, err := fake.find(aws.StringValue(input.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := fake.page(len(stored.versions), input.NextToken, input.MaxResults, maxHistoryPageSize)
+	if err != nil {
+		return nil, err
+	}
+	output := &ssm.GetParameterHistoryOutput{
+		Parameters: []*ssm.ParameterHistory{},
+	}
+	for _, version := range stored.versions[start:end] {
+		output.Parameters = append(output.Parameters, copyVersion(version))
+	}
+	if end < len(stored.versions) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+// GetParameterHistoryPages iterates over all pages of GetParameterHistory
+func (fake *SSM) GetParameterHistoryPages(input *ssm.GetParameterHistoryInput, fn func(*ssm.GetParameterHistoryOutput, bool) bool) error {
+	pageInput := *input
+	for {
+		output, err := fake.GetParameterHistory(&pageInput)
+		if err != nil {
+			return err
+		}
+		lastPage := output.NextToken == nil
+		if !fn(output, lastPage) || lastPage {
+			return nil
+		}
+		pageInput.NextToken = output.NextToken
+	}
+}
+
+// LabelParameterVersion attaches labels to a version, the latest version by default, and
+// removes them from other versions. Labels which do not follow the naming rules of Parameter
+// Store are returned as invalid.
+func (fake *SSM) LabelParameterVersion(input *ssm.LabelParameterVersionInput) (*ssm.LabelParameterVersionOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.Name)
+	stored, err := fake.find(name)
+	if err != nil {
+		return nil, err
+	}
+	target := stored.latest()
+	if input.ParameterVersion != nil {
+		target = stored.selectVersion(strconv.FormatInt(*input.ParameterVersion, 10))
+		if target == nil {
+			return nil, awserr.New(ssm.ErrCodeParameterVersionNotFound, fmt.Sprintf("version %d of parameter %s not found", *input.ParameterVersion, name), nil)
+		}
+	}
+
+	output := &ssm.LabelParameterVersionOutput{
+		ParameterVersion: target.Version,
+		InvalidLabels:    []*string{},
+	}
+	valid := []string{}
+	for _, label := range aws.StringValueSlice(input.Labels) {
+		if isValidLabel(label) {
+			valid = append(valid, label)
+		} else {
+			output.InvalidLabels = append(output.InvalidLabels, aws.String(label))
+		}
+	}
+
+	targetLabels := target.Labels
+	for _, label := range valid {
+		targetLabels = append(removeLabel(targetLabels, label), aws.String(label))
+	}
+	if len(targetLabels) > maxLabelsPerVersion {
+		return nil, awserr.New(ssm.ErrCodeParameterVersionLabelLimitExceeded, fmt.Sprintf("version %d of parameter %s has too many labels", *target.Version, name), nil)
+	}
+	for _, label := range valid {
+		for _, other := range stored.versions {
+			other.Labels = removeLabel(other.Labels, label)
+		}
+	}
+	target.Labels = targetLabels
+	return output, nil
+}
+
+// DeleteParameter deletes a parameter with all its versions and tags
+func (fake *SSM) DeleteParameter(input *ssm.DeleteParameterInput) (*ssm.DeleteParameterOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.Name)
+	if _, err := fake.find(name); err != nil {
+		return nil, err
+	}
+	delete(fake.parameters, name)
+	return new(ssm.DeleteParameterOutput), nil
+}
+
+// AddTagsToResource adds tags to a parameter, tags with the same key are overwritten
+func (fake *SSM) AddTagsToResource(input *ssm.AddTagsToResourceInput) (*ssm.AddTagsToResourceOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	stored, err := fake.findResource(input.ResourceType, input.ResourceId)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range input.Tags {
+		stored.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return new(ssm.AddTagsToResourceOutput), nil
+}
+
+// ListTagsForResource returns the tags of a parameter ordered by key
+func (fake *SSM) ListTagsForResource(input *ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if err := fake.throttle(); err != nil {
+		return nil, err
+	}
+	stored, err := fake.findResource(input.ResourceType, input.ResourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(stored.tags))
+	for key := range stored.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	output := &ssm.ListTagsForResourceOutput{
+		TagList: []*ssm.Tag{},
+	}
+	for _, key := range keys {
+		output.TagList = append(output.TagList, &ssm.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(stored.tags[key]),
+		})
+	}
+	return output, nil
+}
+
+func (fake *SSM) throttle() error {
+	if fake.throttledCalls == 0 {
+		return nil
+	}
+	fake.throttledCalls--
+	return awserr.New(ErrCodeThrottling, "rate exceeded", nil)
+}
+
+func (fake *SSM) find(name string) (*parameter, error) {
+	stored, ok := fake.parameters[name]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, fmt.Sprintf("parameter %s not found", name), nil)
+	}
+	return stored, nil
+}
+
+func (fake *SSM) findResource(resourceType *string, resourceID *string) (*parameter, error) {
+	stored, ok := fake.parameters[aws.StringValue(resourceID)]
+	if !ok || aws.StringValue(resourceType) != ssm.ResourceTypeForTaggingParameter {
+		return nil, awserr.New(ssm.ErrCodeInvalidResourceId, fmt.Sprintf("invalid resource id %s", aws.StringValue(resourceID)), nil)
+	}
+	return stored, nil
+}
+
+// page returns the bounds of the page of the next token within count results
+func (fake *SSM) page(count int, nextToken *string, maxResults *int64, limit int) (int, int, error) {
+	start := 0
+	if nextToken != nil {
+		parsed, err := strconv.Atoi(*nextToken)
+		if err != nil || parsed < 0 || parsed > count {
+			return 0, 0, awserr.New(ssm.ErrCodeInvalidNextToken, "invalid next token", nil)
+		}
+		start = parsed
+	}
+
+	pageSize := limit
+	if maxResults != nil {
+		if *maxResults < 1 || *maxResults > int64(limit) {
+			return 0, 0, awserr.New(ErrCodeValidation, fmt.Sprintf("max results has to be between 1 and %d", limit), nil)
+		}
+		pageSize = int(*maxResults)
+	}
+	if fake.PageSize > 0 && fake.PageSize < pageSize {
+		pageSize = fake.PageSize
+	}
+	end := start + pageSize
+	if end > count {
+		end = count
+	}
+	return start, end, nil
+}
+
+func (fake *SSM) now() time.Time {
+	if fake.Now != nil {
+		return fake.Now()
+	}
+	return time.Now()
+}
+
+func (stored *parameter) latest() *ssm.ParameterHistory {
+	return stored.versions[len(stored.versions)-1]
+}
+
+// selectVersion returns the version with the number or label or nil if it does not exist
+func (stored *parameter) selectVersion(selector string) *ssm.ParameterHistory {
+	for _, version := range stored.versions {
+		if strconv.FormatInt(aws.Int64Value(version.Version), 10) == selector {
+			return version
+		}
+		for _, label := range version.Labels {
+			if aws.StringValue(label) == selector {
+				return version
+			}
+		}
+	}
+	return nil
+}
+
+// validateName checks the naming rules of Parameter Store
+func validateName(name string) error {
+	lower := strings.ToLower(strings.TrimPrefix(name, "/"))
+	if name == "" || len(name) > 1011 || strings.HasPrefix(lower, "aws") || strings.HasPrefix(lower, "ssm") {
+		return awserr.New(ErrCodeValidation, fmt.Sprintf("invalid parameter name %s", name), nil)
+	}
+	if strings.Contains(name, "/") {
+		if !strings.HasPrefix(name, "/") {
+			return awserr.New(ErrCodeValidation, fmt.Sprintf("parameter name %s has to be fully qualified", name), nil)
+		}
+		if strings.Count(name, "/") > maxHierarchyLevels {
+			return awserr.New(ssm.ErrCodeHierarchyLevelLimitExceededException, fmt.Sprintf("parameter name %s has too many levels", name), nil)
+		}
+	}
+	for _, character := range name {
+		if !isLabelCharacter(character) && character != '/' {
+			return awserr.New(ErrCodeValidation, fmt.Sprintf("parameter name %s contains invalid characters", name), nil)
+		}
+	}
+	return nil
+}
+
+// validatePut checks the input like Parameter Store and returns the policies of the parameter
+func validatePut(input *ssm.PutParameterInput, parameterType string, tier string) ([]*ssm.ParameterInlinePolicy, error) {
+	switch parameterType {
+	case ssm.ParameterTypeString, ssm.ParameterTypeStringList, ssm.ParameterTypeSecureString:
+	case "":
+		return nil, awserr.New(ErrCodeValidation, "type is required for new parameters", nil)
+	default:
+		return nil, awserr.New(ssm.ErrCodeUnsupportedParameterType, fmt.Sprintf("unsupported type %s", parameterType), nil)
+	}
+	if input.Value == nil {
+		return nil, awserr.New(ErrCodeValidation, "value is required", nil)
+	}
+	if input.KeyId != nil && parameterType != ssm.ParameterTypeSecureString {
+		return nil, awserr.New(ErrCodeValidation, "key id is only supported for SecureString", nil)
+	}
+	if len(input.Tags) > 0 && aws.BoolValue(input.Overwrite) {
+		return nil, awserr.New(ErrCodeValidation, "tags can not be used with overwrite", nil)
+	}
+
+	maxSize := maxStandardValueSize
+	switch tier {
+	case ssm.ParameterTierStandard:
+	case ssm.ParameterTierAdvanced, ssm.ParameterTierIntelligentTiering:
+		maxSize = maxAdvancedValueSize
+	default:
+		return nil, awserr.New(ErrCodeValidation, fmt.Sprintf("invalid tier %s", tier), nil)
+	}
+	if len(*input.Value) > maxSize {
+		return nil, awserr.New(ErrCodeValidation, "value exceeds the maximum size of the tier", nil)
+	}
+
+	if input.AllowedPattern != nil {
+		pattern, err := regexp.Compile(*input.AllowedPattern)
+		if err != nil {
+			return nil, awserr.New(ssm.ErrCodeInvalidAllowedPatternException, err.Error(), nil)
+		}
+		if !pattern.MatchString(*input.Value) {
+			return nil, awserr.New(ssm.ErrCodeParameterPatternMismatchException, "value does not match the allowed pattern", nil)
+		}
+	}
+
+	if input.Policies == nil {
+		return nil, nil
+	}
+	if tier == ssm.ParameterTierStandard {
+		return nil, awserr.New(ssm.ErrCodeIncompatiblePolicyException, "policies require the advanced tier", nil)
+	}
+	texts := []json.RawMessage{}
+	if err := json.Unmarshal([]byte(*input.Policies), &texts); err != nil {
+		return nil, awserr.New(ssm.ErrCodeInvalidPolicyAttributeException, err.Error(), nil)
+	}
+	result := make([]*ssm.ParameterInlinePolicy, 0, len(texts))
+	for _, text := range texts {
+		policy := struct {
+			Type string `json:"Type"`
+		}{}
+		if err := json.Unmarshal(text, &policy); err != nil {
+			return nil, awserr.New(ssm.ErrCodeInvalidPolicyAttributeException, err.Error(), nil)
+		}
+		switch policy.Type {
+		case "Expiration", "ExpirationNotification", "NoChangeNotification":
+		default:
+			return nil, awserr.New(ssm.ErrCodeInvalidPolicyTypeException, fmt.Sprintf("unknown policy type %s", policy.Type), nil)
+		}
+		result = append(result, &ssm.ParameterInlinePolicy{
+			PolicyText:   aws.String(string(text)),
+			PolicyType:   aws.String(policy.Type),
+			PolicyStatus: aws.String("Pending"),
+		})
+	}
+	return result, nil
+}
+
+func isValidLabel(label string) bool {
+	lower := strings.ToLower(label)
+	if label == "" || len(label) > 100 || (label[0] >= '0' && label[0] <= '9') ||
+		strings.HasPrefix(lower, "aws") || strings.HasPrefix(lower, "ssm") {
+		return false
+	}
+	for _, character := range label {
+		if !isLabelCharacter(character) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLabelCharacter(character rune) bool {
+	return (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z') ||
+		(character >= '0' && character <= '9') || character == '.' || character == '-' || character == '_'
+}
+
+func removeLabel(labels []*string, label string) []*string {
+	result := []*string{}
+	for _, existing := range labels {
+		if *existing != label {
+			result = append(result, existing)
+		}
+	}
+	return result
+}
+
+// copyVersion copies a version, so callers can not modify the stored labels
+func copyVersion(version *ssm.ParameterHistory) *ssm.ParameterHistory {
+	copied := *version
+	copied.Labels = append([]*string{}, version.Labels...)
+	return &copied
+}
+
+func toParameter(version *ssm.ParameterHistory) *ssm.Parameter {
+	return &ssm.Parameter{
+		ARN:              aws.String("arn:aws:ssm:us-east-1:000000000000:parameter" + withLeadingSlash(aws.StringValue(version.Name))),
+		DataType:         aws.String("text"),
+		Name:             version.Name,
+		Value:            aws.String(aws.StringValue(version.Value)),
+		Version:          version.Version,
+		LastModifiedDate: version.LastModifiedDate,
+		Type:             version.Type,
+	}
+}
+
+func withLeadingSlash(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return "/" + name
+}
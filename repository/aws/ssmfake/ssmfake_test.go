@@ -0,0 +1,230 @@
+package ssmfake
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const testName = "/service/key"
+
+func put(t *testing.T, fake *SSM, name string, value string, overwrite bool) *ssm.PutParameterOutput {
+	output, err := fake.PutParameter(&ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      aws.String(ssm.ParameterTypeString),
+		Overwrite: aws.Bool(overwrite),
+	})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	return output
+}
+
+func checkErrorCode(t *testing.T, err error, code string) {
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != code {
+		t.Errorf("Expected error code %s but found %v", code, err)
+	}
+}
+
+func Test_Put_Versions(t *testing.T) {
+	fake := New()
+	put(t, fake, testName, "first", false)
+
+	_, err := fake.PutParameter(&ssm.PutParameterInput{
+		Name:  aws.String(testName),
+		Value: aws.String("second"),
+	})
+	checkErrorCode(t, err, ssm.ErrCodeParameterAlreadyExists)
+	output := put(t, fake, testName, "second", true)
+
+	if *output.Version != 2 {
+		t.Errorf("Expected version 2 but found %d", *output.Version)
+	}
+	first, err := fake.GetParameter(&ssm.GetParameterInput{Name: aws.String(testName + ":1")})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	if *first.Parameter.Value != "first" {
+		t.Errorf("Expected first but found %s", *first.Parameter.Value)
+	}
+}
+
+func Test_Put_Invalid_Names(t *testing.T) {
+	fake := New()
+
+	for _, name := range []string{"service/key", "/aws/key", "/service/k y", ""} {
+		_, err := fake.PutParameter(&ssm.PutParameterInput{
+			Name:  aws.String(name),
+			Value: aws.String("value"),
+			Type:  aws.String(ssm.ParameterTypeString),
+		})
+		checkErrorCode(t, err, ErrCodeValidation)
+	}
+}
+
+func Test_Put_Type_Required_For_New_Parameters(t *testing.T) {
+	fake := New()
+
+	_, err := fake.PutParameter(&ssm.PutParameterInput{
+		Name:  aws.String(testName),
+		Value: aws.String("value"),
+	})
+
+	checkErrorCode(t, err, ErrCodeValidation)
+}
+
+func Test_Max_Versions(t *testing.T) {
+	fake := New()
+	put(t, fake, testName, "0", false)
+	for i := 1; i < maxVersions; i++ {
+		put(t, fake, testName, strconv.Itoa(i), true)
+	}
+
+	// the oldest version is removed
+	put(t, fake, testName, "100", true)
+	_, err := fake.GetParameter(&ssm.GetParameterInput{Name: aws.String(testName + ":1")})
+	checkErrorCode(t, err, ssm.ErrCodeParameterVersionNotFound)
+
+	// unless it has a label
+	_, err = fake.LabelParameterVersion(&ssm.LabelParameterVersionInput{
+		Name:             aws.String(testName),
+		ParameterVersion: aws.Int64(2),
+		Labels:           aws.StringSlice([]string{"stable"}),
+	})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	_, err = fake.PutParameter(&ssm.PutParameterInput{
+		Name:      aws.String(testName),
+		Value:     aws.String("101"),
+		Overwrite: aws.Bool(true),
+	})
+	checkErrorCode(t, err, ssm.ErrCodeParameterMaxVersionLimitExceeded)
+}
+
+func Test_Get_Parameters_By_Path(t *testing.T) {
+	fake := New()
+	fake.PageSize = 2
+	for _, name := range []string{"/service/a", "/service/b", "/service/c", "/service/nested/d", "/other/e"} {
+		put(t, fake, name, name, false)
+	}
+
+	tests := []struct {
+		recursive bool
+		expected  int
+		pages     int
+	}{
+		{recursive: false, expected: 3, pages: 2},
+		{recursive: true, expected: 4, pages: 2},
+	}
+	for _, tt := range tests {
+		names := []string{}
+		pages := 0
+		err := fake.GetParametersByPathPages(&ssm.GetParametersByPathInput{
+			Path:      aws.String("/service"),
+			Recursive: aws.Bool(tt.recursive),
+		}, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
+			pages++
+			for _, parameter := range page.Parameters {
+				names = append(names, *parameter.Name)
+			}
+			return true
+		})
+
+		if err != nil {
+			t.Errorf("Expected nil but found error: %+s", err)
+		}
+		if len(names) != tt.expected || pages != tt.pages {
+			t.Errorf("Expected %d parameters on %d pages but found %v on %d pages", tt.expected, tt.pages, names, pages)
+		}
+	}
+}
+
+func Test_Get_Parameters_By_Path_Invalid(t *testing.T) {
+	fake := New()
+
+	_, err := fake.GetParametersByPath(&ssm.GetParametersByPathInput{Path: aws.String("service")})
+	checkErrorCode(t, err, ErrCodeValidation)
+	_, err = fake.GetParametersByPath(&ssm.GetParametersByPathInput{Path: aws.String("/service"), MaxResults: aws.Int64(11)})
+	checkErrorCode(t, err, ErrCodeValidation)
+	_, err = fake.GetParametersByPath(&ssm.GetParametersByPathInput{Path: aws.String("/service"), NextToken: aws.String("invalid")})
+	checkErrorCode(t, err, ssm.ErrCodeInvalidNextToken)
+}
+
+func Test_Throttle(t *testing.T) {
+	fake := New()
+	put(t, fake, testName, "value", false)
+	fake.Throttle(2)
+
+	for i := 0; i < 2; i++ {
+		_, err := fake.GetParameter(&ssm.GetParameterInput{Name: aws.String(testName)})
+		checkErrorCode(t, err, ErrCodeThrottling)
+	}
+	_, err := fake.GetParameter(&ssm.GetParameterInput{Name: aws.String(testName)})
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+}
+
+func Test_Label_Selector(t *testing.T) {
+	fake := New()
+	put(t, fake, testName, "first", false)
+	put(t, fake, testName, "second", true)
+
+	output, err := fake.LabelParameterVersion(&ssm.LabelParameterVersionInput{
+		Name:             aws.String(testName),
+		ParameterVersion: aws.Int64(1),
+		Labels:           aws.StringSlice([]string{"stable", "1invalid"}),
+	})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	labeled, err := fake.GetParameter(&ssm.GetParameterInput{Name: aws.String(testName + ":stable")})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+
+	if len(output.InvalidLabels) != 1 || *output.InvalidLabels[0] != "1invalid" {
+		t.Errorf("Expected invalid label 1invalid but found %+v", output.InvalidLabels)
+	}
+	if *labeled.Parameter.Value != "first" || *labeled.Parameter.Selector != ":stable" {
+		t.Errorf("Expected first version by label but found %+v", labeled.Parameter)
+	}
+}
+
+func Test_Delete_Removes_Tags(t *testing.T) {
+	fake := New()
+	_, err := fake.PutParameter(&ssm.PutParameterInput{
+		Name:  aws.String(testName),
+		Value: aws.String("value"),
+		Type:  aws.String(ssm.ParameterTypeSecureString),
+		Tags:  []*ssm.Tag{{Key: aws.String("owner"), Value: aws.String("me")}},
+	})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+
+	_, err = fake.DeleteParameter(&ssm.DeleteParameterInput{Name: aws.String(testName)})
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	put(t, fake, testName, "value", false)
+	output, err := fake.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceId:   aws.String(testName),
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+	})
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if len(output.TagList) != 0 {
+		t.Errorf("Expected no tags but found %+v", output.TagList)
+	}
+	_, err = fake.DeleteParameter(&ssm.DeleteParameterInput{Name: aws.String("/missing")})
+	checkErrorCode(t, err, ssm.ErrCodeParameterNotFound)
+}
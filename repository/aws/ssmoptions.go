@@ -0,0 +1,182 @@
+package aws
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// SSMOption configures a SSMParameterStoreRepo
+type SSMOption func(*SSMParameterStoreRepo)
+
+// WithRecursive includes all parameters below the path in FindAll, not only the direct
+// children. Keys of nested parameters are relative to the path, e.g. "database/host".
+func WithRecursive() SSMOption {
+	return func(repo *SSMParameterStoreRepo) {
+		repo.recursive = true
+	}
+}
+
+// WithTags loads the tags of a parameter into the metadata returned by Find. This requires
+// an additional request per Find, without this option the metadata does not contain tags.
+func WithTags() SSMOption {
+	return func(repo *SSMParameterStoreRepo) {
+		repo.loadTags = true
+	}
+}
+
+// WithParameterOptions sets the options used for all writes of the repository
+func WithParameterOptions(options ParameterOptions) SSMOption {
+	return func(repo *SSMParameterStoreRepo) {
+		repo.parameterOptions = options
+	}
+}
+
+// ParameterOptions configure how parameters are written. Options which are not set use the
+// defaults of Parameter Store, except the type which defaults to SecureString.
+type ParameterOptions struct {
+	// Type is one of ssm.ParameterTypeString, ssm.ParameterTypeStringList and
+	// ssm.ParameterTypeSecureString
+	Type string
+	// KeyID of the KMS key which encrypts SecureString parameters, defaults to the key
+	// managed by AWS
+	KeyID string
+	// Tier is one of ssm.ParameterTierStandard, ssm.ParameterTierAdvanced and
+	// ssm.ParameterTierIntelligentTiering. Policies require the advanced tier.
+	Tier     string
+	Policies []ParameterPolicy
+	// Description of the parameter
+	Description string
+	// AllowedPattern is a regular expression values have to match
+	AllowedPattern string
+	// Tags are added to the parameter
+	Tags map[string]string
+}
+
+// ParameterPolicy is a policy of an advanced parameter, see ExpirationPolicy,
+// ExpirationNotificationPolicy and NoChangeNotificationPolicy
+type ParameterPolicy struct {
+	Type       string            `json:"Type"`
+	Version    string            `json:"Version"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// ExpirationPolicy deletes a parameter at the expiration date
+func ExpirationPolicy(expiration time.Time) ParameterPolicy {
+	return ParameterPolicy{
+		Type:    "Expiration",
+		Version: "1.0",
+		Attributes: map[string]string{
+			"Timestamp": expiration.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// ExpirationNotificationPolicy sends an event to EventBridge before the parameter expires.
+// The unit is either "Days" or "Hours".
+func ExpirationNotificationPolicy(before int, unit string) ParameterPolicy {
+	return ParameterPolicy{
+		Type:    "ExpirationNotification",
+		Version: "1.0",
+		Attributes: map[string]string{
+			"Before": strconv.Itoa(before),
+			"Unit":   unit,
+		},
+	}
+}
+
+// NoChangeNotificationPolicy sends an event to EventBridge if the parameter was not changed
+// for the given time. The unit is either "Days" or "Hours".
+func NoChangeNotificationPolicy(after int, unit string) ParameterPolicy {
+	return ParameterPolicy{
+		Type:    "NoChangeNotification",
+		Version: "1.0",
+		Attributes: map[string]string{
+			"After": strconv.Itoa(after),
+			"Unit":  unit,
+		},
+	}
+}
+
+// merge returns the options with all fields replaced which are set in override
+func (options ParameterOptions) merge(override ParameterOptions) ParameterOptions {
+	if override.Type != "" {
+		options.Type = override.Type
+	}
+	if override.KeyID != "" {
+		options.KeyID = override.KeyID
+	}
+	if override.Tier != "" {
+		options.Tier = override.Tier
+	}
+	if override.Policies != nil {
+		options.Policies = override.Policies
+	}
+	if override.Description != "" {
+		options.Description = override.Description
+	}
+	if override.AllowedPattern != "" {
+		options.AllowedPattern = override.AllowedPattern
+	}
+	if override.Tags != nil {
+		options.Tags = override.Tags
+	}
+	return options
+}
+
+func (options ParameterOptions) toPutParameterInput(name string, value string, overwrite bool) (*ssm.PutParameterInput, error) {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      aws.String(ssm.ParameterTypeSecureString),
+		Overwrite: aws.Bool(overwrite),
+	}
+	if options.Type != "" {
+		input.Type = aws.String(options.Type)
+	}
+	if options.KeyID != "" {
+		input.KeyId = aws.String(options.KeyID)
+	}
+	if options.Tier != "" {
+		input.Tier = aws.String(options.Tier)
+	}
+	if len(options.Policies) > 0 {
+		policies, err := json.Marshal(options.Policies)
+		if err != nil {
+			return nil, err
+		}
+		input.Policies = aws.String(string(policies))
+	}
+	if options.Description != "" {
+		input.Description = aws.String(options.Description)
+	}
+	if options.AllowedPattern != "" {
+		input.AllowedPattern = aws.String(options.AllowedPattern)
+	}
+	if !overwrite && len(options.Tags) > 0 {
+		input.Tags = toSSMTags(options.Tags)
+	}
+	return input, nil
+}
+
+// toSSMTags converts tags ordered by their name
+func toSSMTags(tags map[string]string) []*ssm.Tag {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]*ssm.Tag, 0, len(tags))
+	for _, name := range names {
+		result = append(result, &ssm.Tag{
+			Key:   aws.String(name),
+			Value: aws.String(tags[name]),
+		})
+	}
+	return result
+}
@@ -1,9 +1,13 @@
 package aws
 
 import (
+	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -14,76 +18,148 @@ import (
 )
 
 // SSMParameterStoreRepo stores entries in AWS Parameter Store.
-// Values will always be stored encrypted
+// By default values are stored encrypted as SecureString, see WithParameterOptions.
 type SSMParameterStoreRepo struct {
 	mutex            sync.RWMutex
+	updateMutex      sync.Mutex
 	path             string
 	ssmClient        ssmiface.SSMAPI
 	toStructFunction func(jsonString string) (interface{}, error)
+	recursive        bool
+	loadTags         bool
+	parameterOptions ParameterOptions
 }
 
 // NewSSMParameterStoreRepo creates a new instance of the repository
 // The repo can take structs and store them in serialized form.
-func NewSSMParameterStoreRepo(path string, ssmClient ssmiface.SSMAPI, itemTemplate serialization.Serializable) *SSMParameterStoreRepo {
-	return &SSMParameterStoreRepo{
-		path:             path,
-		ssmClient:        ssmClient,
-		toStructFunction: itemTemplate.ToStruct,
-	}
+func NewSSMParameterStoreRepo(path string, ssmClient ssmiface.SSMAPI, itemTemplate serialization.Serializable, options ...SSMOption) *SSMParameterStoreRepo {
+	return newSSMParameterStoreRepo(path, ssmClient, itemTemplate.ToStruct, options)
 }
 
 // NewStringSSMParameterStoreRepo creates a new instance of the repository
 // The repo stores the string without conversion.
-func NewStringSSMParameterStoreRepo(path string, ssmClient ssmiface.SSMAPI) *SSMParameterStoreRepo {
-	return &SSMParameterStoreRepo{
-		path:      path,
-		ssmClient: ssmClient,
-		toStructFunction: func(jsonString string) (interface{}, error) {
-			return jsonString, nil
-		},
-	}
+func NewStringSSMParameterStoreRepo(path string, ssmClient ssmiface.SSMAPI, options ...SSMOption) *SSMParameterStoreRepo {
+	return newSSMParameterStoreRepo(path, ssmClient, func(jsonString string) (interface{}, error) {
+		return jsonString, nil
+	}, options)
+}
 
+func newSSMParameterStoreRepo(path string, ssmClient ssmiface.SSMAPI, toStruct func(jsonString string) (interface{}, error), options []SSMOption) *SSMParameterStoreRepo {
+	repo := &SSMParameterStoreRepo{
+		path:             path,
+		ssmClient:        ssmClient,
+		toStructFunction: toStruct,
+	}
+	for _, option := range options {
+		option(repo)
+	}
+	return repo
 }
 
+// FindAll returns all parameters of the path. To avoid an additional request per parameter
+// the metadata of the items does not contain tags.
 func (repo *SSMParameterStoreRepo) FindAll() ([]repository.KeyValuePair, error) {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
+	return repo.findByPath(repo.recursive)
+}
+
+// Tree returns all parameters below the path as nested maps following the hierarchy of the
+// parameter names, e.g. the value of "database/host" is found in tree["database"]["host"].
+// An error is returned if a parameter is also the parent of other parameters.
+func (repo *SSMParameterStoreRepo) Tree() (map[string]interface{}, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	items, err := repo.findByPath(true)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+
+	tree := map[string]interface{}{}
+	for _, item := range items {
+		levels := strings.Split(item.Key, "/")
+		node := tree
+		for _, level := range levels[:len(levels)-1] {
+			child, ok := node[level]
+			if !ok {
+				child = map[string]interface{}{}
+				node[level] = child
+			}
+			childNode, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("parameter %s is a value and a parent of %s", repo.path+level, repo.path+item.Key)
+			}
+			node = childNode
+		}
+		leaf := levels[len(levels)-1]
+		if _, ok := node[leaf]; ok {
+			return nil, fmt.Errorf("parameter %s is a value and a parent of other parameters", repo.path+item.Key)
+		}
+		node[leaf] = item.Value
+	}
+	return tree, nil
+}
+
+// findByPath returns the decoded parameters of the path with keys relative to the path
+func (repo *SSMParameterStoreRepo) findByPath(recursive bool) ([]repository.KeyValuePair, error) {
 	results := []repository.KeyValuePair{}
 
 	getParametersByPathInput := &ssm.GetParametersByPathInput{
 		Path:           aws.String(repo.path),
+		Recursive:      aws.Bool(recursive),
 		WithDecryption: aws.Bool(true),
 	}
 
+	var decodeErr error
 	err := repo.ssmClient.GetParametersByPathPages(getParametersByPathInput, func(resp *ssm.GetParametersByPathOutput, lastPage bool) bool {
 		for _, param := range resp.Parameters {
-			fullKey := *param.Name
-			item := repository.KeyValuePair{
-				Key:   strings.TrimPrefix(fullKey, repo.path), // remove path from key
-				Value: *param.Value,
+			key := strings.TrimPrefix(*param.Name, repo.path) // remove path from key
+			value, err := repo.toStructFunction(aws.StringValue(param.Value))
+			if err != nil {
+				decodeErr = fmt.Errorf("could not decode parameter %s: %w", *param.Name, err)
+				return false
 			}
-
-			results = append(results, item)
+			results = append(results, repository.KeyValuePair{
+				Key:      key,
+				Value:    value,
+				Metadata: toParameterMetadata(param),
+			})
 		}
 		return true
 	})
-
+	if err == nil {
+		err = decodeErr
+	}
 	if err != nil {
-		results = nil
+		return nil, err
 	}
-	return results, err
+	return results, nil
 }
 
 func (repo *SSMParameterStoreRepo) Save(key string, in interface{}) (repository.KeyValuePair, error) {
-	return repo.save(key, in, false)
+	return repo.save(key, in, false, ParameterOptions{})
 }
 
 func (repo *SSMParameterStoreRepo) Overwrite(key string, in interface{}) (repository.KeyValuePair, error) {
-	return repo.save(key, in, true)
+	return repo.save(key, in, true, ParameterOptions{})
+}
+
+// SaveWithOptions stores a new parameter. Options which are set override the options of the repository.
+func (repo *SSMParameterStoreRepo) SaveWithOptions(key string, in interface{}, options ParameterOptions) (repository.KeyValuePair, error) {
+	return repo.save(key, in, false, options)
 }
 
-func (repo *SSMParameterStoreRepo) save(key string, in interface{}, overwrite bool) (repository.KeyValuePair, error) {
+// OverwriteWithOptions stores a parameter. Options which are set override the options of the repository.
+func (repo *SSMParameterStoreRepo) OverwriteWithOptions(key string, in interface{}, options ParameterOptions) (repository.KeyValuePair, error) {
+	return repo.save(key, in, true, options)
+}
+
+func (repo *SSMParameterStoreRepo) save(key string, in interface{}, overwrite bool, options ParameterOptions) (repository.KeyValuePair, error) {
 	repo.mutex.RLock()
 	defer repo.mutex.RUnlock()
 
@@ -94,16 +170,16 @@ func (repo *SSMParameterStoreRepo) save(key string, in interface{}, overwrite bo
 		return result, err
 	}
 
-	_, err = repo.ssmClient.PutParameter(&ssm.PutParameterInput{
-		Name:      aws.String(repo.path + key),
-		Value:     aws.String(serialized),
-		Type:      aws.String("SecureString"),
-		Overwrite: aws.Bool(overwrite),
-	})
+	version, err := repo.putValue(repo.path+key, serialized, overwrite, repo.parameterOptions.merge(options))
+	err = toAlreadyExistsError(err, ssm.ErrCodeParameterAlreadyExists)
 
 	if err == nil {
 		result.Key = key
 		result.Value = in
+		result.Metadata = &repository.Metadata{
+			UpdatedAt: time.Now(),
+			Version:   version,
+		}
 	}
 
 	return result, err
@@ -120,7 +196,7 @@ func (repo *SSMParameterStoreRepo) Delete(key string) error {
 		Name: aws.String(repo.path + key),
 	}
 	_, err := repo.ssmClient.DeleteParameter(input)
-	return err
+	return toNotFoundError(err, ssm.ErrCodeParameterNotFound)
 }
 
 func (repo *SSMParameterStoreRepo) Find(key string) (repository.KeyValuePair, error) {
@@ -134,7 +210,7 @@ func (repo *SSMParameterStoreRepo) Find(key string) (repository.KeyValuePair, er
 	param, err := repo.ssmClient.GetParameter(input)
 
 	if err != nil {
-		return repository.KeyValuePair{}, err
+		return repository.KeyValuePair{}, toNotFoundError(err, ssm.ErrCodeParameterNotFound)
 	}
 
 	value, err := repo.toStructFunction(*param.Parameter.Value)
@@ -142,14 +218,339 @@ func (repo *SSMParameterStoreRepo) Find(key string) (repository.KeyValuePair, er
 		return repository.KeyValuePair{}, err
 	}
 
+	metadata := toParameterMetadata(param.Parameter)
+	if repo.loadTags {
+		metadata.Tags, err = repo.findTags(key)
+		if err != nil {
+			return repository.KeyValuePair{}, err
+		}
+	}
+
 	result := repository.KeyValuePair{
-		Key:   key,
-		Value: value,
+		Key:      key,
+		Value:    value,
+		Metadata: metadata,
 	}
 
 	return result, err
 }
 
+// History returns all versions of a parameter ordered from oldest to newest.
+// Parameter Store keeps the last 100 versions of a parameter.
+func (repo *SSMParameterStoreRepo) History(key string) ([]repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	results := []repository.KeyValuePair{}
+	var decodeErr error
+	err := repo.ssmClient.GetParameterHistoryPages(&ssm.GetParameterHistoryInput{
+		Name:           aws.String(repo.path + key),
+		WithDecryption: aws.Bool(true),
+	}, func(page *ssm.GetParameterHistoryOutput, lastPage bool) bool {
+		for _, param := range page.Parameters {
+			item, err := repo.toHistoryKeyValuePair(key, param)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			results = append(results, item)
+		}
+		return true
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Metadata.Version < results[j].Metadata.Version
+	})
+	return results, nil
+}
+
+// Tag adds tags to an existing parameter
+func (repo *SSMParameterStoreRepo) Tag(key string, tags map[string]string) error {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	input := &ssm.AddTagsToResourceInput{
+		ResourceId:   aws.String(repo.path + key),
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+		Tags:         toSSMTags(tags),
+	}
+	_, err := repo.ssmClient.AddTagsToResource(input)
+	return err
+}
+
+func (repo *SSMParameterStoreRepo) findTags(key string) (map[string]string, error) {
+	output, err := repo.ssmClient.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceId:   aws.String(repo.path + key),
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.TagList) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(output.TagList))
+	for _, tag := range output.TagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// toParameterMetadata maps the version information of a parameter. Parameter Store does not provide
+// the creation date of a parameter, hence it is not set.
+func toParameterMetadata(param *ssm.Parameter) *repository.Metadata {
+	return &repository.Metadata{
+		UpdatedAt: aws.TimeValue(param.LastModifiedDate),
+		Version:   aws.Int64Value(param.Version),
+	}
+}
+
+// Increment adds delta to the numeric value of a parameter. Missing parameters are created
+// with delta as initial value.
+//
+// Unlike other CounterRepo implementations the increment is not atomic. Parameter Store does
+// not support conditional writes, so a concurrent write is only detected afterwards and an
+// error wrapping repository.ErrConditionFailed is returned. The increment can be retried.
+// See update for details.
+func (repo *SSMParameterStoreRepo) Increment(key string, delta int64) (int64, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	value, err := repo.update(key, true, func(current *string) (string, error) {
+		number := int64(0)
+		if current != nil {
+			parsed, err := strconv.ParseInt(*current, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("value of key %s is not numeric: %v", key, err)
+			}
+			number = parsed
+		}
+		return strconv.FormatInt(number+delta, 10), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Patch applies a JSON merge patch to the value of an existing parameter
+func (repo *SSMParameterStoreRepo) Patch(key string, mergePatch []byte) (repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	value, err := repo.update(key, false, func(current *string) (string, error) {
+		patched, err := serialization.MergePatch([]byte(*current), mergePatch)
+		return string(patched), err
+	})
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+
+	structValue, err := repo.toStructFunction(value)
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	return repository.KeyValuePair{
+		Key:   key,
+		Value: structValue,
+	}, nil
+}
+
+// update applies modify to the current value of a parameter and stores the result.
+// The current value passed to modify is nil if the parameter does not exist, which is
+// only the case if create is set. Otherwise a missing parameter results in an error.
+//
+// Parameter Store does not support conditional writes, so updates are not atomic. Updates
+// of the same repository are serialized, for other writers the version returned by the put
+// is compared with the version the new value was based on. If another writer got in between,
+// the value preceding the own write is restored and an error wrapping
+// repository.ErrConditionFailed is returned. With several concurrent writers in other
+// processes the restore itself may replace a newer value, so updates can still be lost.
+func (repo *SSMParameterStoreRepo) update(key string, create bool, modify func(current *string) (string, error)) (string, error) {
+	repo.updateMutex.Lock()
+	defer repo.updateMutex.Unlock()
+
+	name := repo.path + key
+	param, err := repo.ssmClient.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if create && isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+		value, err := modify(nil)
+		if err != nil {
+			return "", err
+		}
+		_, err = repo.putValue(name, value, false, repo.parameterOptions)
+		if isAWSErrorCode(err, ssm.ErrCodeParameterAlreadyExists) {
+			return "", fmt.Errorf("key %s was created concurrently: %w", key, repository.ErrConditionFailed)
+		}
+		return value, err
+	}
+	if err != nil {
+		return "", toNotFoundError(err, ssm.ErrCodeParameterNotFound)
+	}
+
+	current := aws.StringValue(param.Parameter.Value)
+	baseVersion := aws.Int64Value(param.Parameter.Version)
+	value, err := modify(&current)
+	if err != nil {
+		return "", err
+	}
+	version, err := repo.putValue(name, value, true, repo.parameterOptions)
+	if err != nil {
+		return "", err
+	}
+	if version == baseVersion+1 {
+		return value, nil
+	}
+
+	// another writer stored a value in the meantime, which the own write replaced
+	previous, err := repo.findVersionValue(name, version-1)
+	if err != nil {
+		return "", err
+	}
+	if _, err = repo.putValue(name, previous, true, repo.parameterOptions); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("key %s was modified concurrently: %w", key, repository.ErrConditionFailed)
+}
+
+func (repo *SSMParameterStoreRepo) putValue(name string, value string, overwrite bool, options ParameterOptions) (int64, error) {
+	input, err := options.toPutParameterInput(name, value, overwrite)
+	if err != nil {
+		return 0, err
+	}
+	output, err := repo.ssmClient.PutParameter(input)
+	if err != nil {
+		return 0, err
+	}
+	if overwrite && len(options.Tags) > 0 {
+		// Parameter Store does not allow tags when overwriting a parameter
+		_, err = repo.ssmClient.AddTagsToResource(&ssm.AddTagsToResourceInput{
+			ResourceId:   aws.String(name),
+			ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+			Tags:         toSSMTags(options.Tags),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return aws.Int64Value(output.Version), nil
+}
+
+func (repo *SSMParameterStoreRepo) findVersionValue(name string, version int64) (string, error) {
+	param, err := repo.findInHistory(name, func(param *ssm.ParameterHistory) bool {
+		return aws.Int64Value(param.Version) == version
+	})
+	if err != nil {
+		return "", err
+	}
+	if param == nil {
+		return "", fmt.Errorf("version %d of parameter %s %w", version, name, repository.ErrNotFound)
+	}
+	return aws.StringValue(param.Value), nil
+}
+
+// findInHistory returns the first version of a parameter which matches or nil if none matches
+func (repo *SSMParameterStoreRepo) findInHistory(name string, match func(*ssm.ParameterHistory) bool) (*ssm.ParameterHistory, error) {
+	var result *ssm.ParameterHistory
+	err := repo.ssmClient.GetParameterHistoryPages(&ssm.GetParameterHistoryInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}, func(page *ssm.GetParameterHistoryOutput, lastPage bool) bool {
+		for _, param := range page.Parameters {
+			if match(param) {
+				result = param
+				return false
+			}
+		}
+		return true
+	})
+	return result, toNotFoundError(err, ssm.ErrCodeParameterNotFound, ssm.ErrCodeParameterVersionNotFound)
+}
+
+// FindVersion returns a specific version of a parameter
+func (repo *SSMParameterStoreRepo) FindVersion(key string, version int64) (repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	param, err := repo.findInHistory(repo.path+key, func(param *ssm.ParameterHistory) bool {
+		return aws.Int64Value(param.Version) == version
+	})
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	if param == nil {
+		return repository.KeyValuePair{}, fmt.Errorf("version %d of key %s %w", version, key, repository.ErrNotFound)
+	}
+	return repo.toHistoryKeyValuePair(key, param)
+}
+
+// FindLabel returns the version of a parameter which has the label, e.g. "stable"
+func (repo *SSMParameterStoreRepo) FindLabel(key string, label string) (repository.KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	param, err := repo.findInHistory(repo.path+key, func(param *ssm.ParameterHistory) bool {
+		for _, versionLabel := range param.Labels {
+			if aws.StringValue(versionLabel) == label {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	if param == nil {
+		return repository.KeyValuePair{}, fmt.Errorf("label %s of key %s %w", label, key, repository.ErrNotFound)
+	}
+	return repo.toHistoryKeyValuePair(key, param)
+}
+
+// LabelVersion attaches labels to a version of a parameter. A label is moved if it is
+// attached to another version of the parameter. Parameter Store allows up to 10 labels
+// per version. Labels must not start with a number, aws or ssm.
+func (repo *SSMParameterStoreRepo) LabelVersion(key string, version int64, labels ...string) error {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	output, err := repo.ssmClient.LabelParameterVersion(&ssm.LabelParameterVersionInput{
+		Name:             aws.String(repo.path + key),
+		ParameterVersion: aws.Int64(version),
+		Labels:           aws.StringSlice(labels),
+	})
+	if err != nil {
+		return toNotFoundError(err, ssm.ErrCodeParameterNotFound, ssm.ErrCodeParameterVersionNotFound)
+	}
+	if len(output.InvalidLabels) > 0 {
+		return fmt.Errorf("invalid labels %s for key %s", strings.Join(aws.StringValueSlice(output.InvalidLabels), ", "), key)
+	}
+	return nil
+}
+
+func (repo *SSMParameterStoreRepo) toHistoryKeyValuePair(key string, param *ssm.ParameterHistory) (repository.KeyValuePair, error) {
+	value, err := repo.toStructFunction(aws.StringValue(param.Value))
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	return repository.KeyValuePair{
+		Key:   key,
+		Value: value,
+		Metadata: &repository.Metadata{
+			UpdatedAt: aws.TimeValue(param.LastModifiedDate),
+			Version:   aws.Int64Value(param.Version),
+		},
+	}, nil
+}
+
 func NewSSMSession(region string) ssmiface.SSMAPI {
 	sess, err := session.NewSessionWithOptions(session.Options{
 		Config:            aws.Config{Region: aws.String(region)},
@@ -1,23 +1,62 @@
 package aws
 
 import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/jo-hoe/serverless-toolbox/repository"
+	"github.com/jo-hoe/serverless-toolbox/repository/aws/ssmfake"
 	"github.com/jo-hoe/serverless-toolbox/serialization"
 )
 
 var testValue = "testValue"
 var testKey = "testKey"
-var testPath = "testPath/"
+var testPath = "/testPath/"
 
-func createMock() *mockSSM {
-	return NewMockSSM(testPath, map[string]interface{}{
+func createFake(t *testing.T) *ssmfake.SSM {
+	return createFakeWithParameters(t, map[string]string{
 		testPath + testKey:       testValue,
 		testPath + testKey + "2": testValue + "2",
 	})
 }
 
+func createFakeWithParameters(t *testing.T, parameters map[string]string) *ssmfake.SSM {
+	fake := ssmfake.New()
+	for name, value := range parameters {
+		_, err := fake.PutParameter(&ssm.PutParameterInput{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+			Type:  aws.String(ssm.ParameterTypeString),
+		})
+		if err != nil {
+			t.Fatalf("Could not create parameter %s: %+s", name, err)
+		}
+	}
+	return fake
+}
+
+func findTags(t *testing.T, fake *ssmfake.SSM, name string) map[string]string {
+	output, err := fake.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceId:   aws.String(name),
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+	})
+	if err != nil {
+		t.Fatalf("Could not list tags of %s: %+s", name, err)
+	}
+	tags := map[string]string{}
+	for _, tag := range output.TagList {
+		tags[*tag.Key] = *tag.Value
+	}
+	return tags
+}
+
 func Test_NewSSMSession(t *testing.T) {
 	session := NewSSMSession("")
 	if session == nil {
@@ -25,9 +64,24 @@ func Test_NewSSMSession(t *testing.T) {
 	}
 }
 
+func Test_NewMockSSM(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, NewMockSSM(testPath, map[string]interface{}{
+		testPath + testKey: 1,
+	}))
+
+	item, err := repo.Find(testKey)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if item.Value != "1" {
+		t.Errorf("Expected 1 but found %v", item.Value)
+	}
+}
+
 func Test_Find_All_Strings(t *testing.T) {
-	mock := createMock()
-	repo := NewStringSSMParameterStoreRepo(testPath, mock)
+	fake := createFake(t)
+	repo := NewStringSSMParameterStoreRepo(testPath, fake)
 
 	items, err := repo.FindAll()
 
@@ -37,10 +91,11 @@ func Test_Find_All_Strings(t *testing.T) {
 	if items == nil {
 		t.Error("items should not be nil")
 	}
-	if len(items) != len(mock.mapItem) {
-		t.Errorf("Expected %d items but found %d", len(mock.mapItem), len(items))
+	parameters := fake.Parameters()
+	if len(items) != len(parameters) {
+		t.Errorf("Expected %d items but found %d", len(parameters), len(items))
 	}
-	for key, element := range mock.mapItem {
+	for key, element := range parameters {
 		item := repository.KeyValuePair{
 			Key:   key[len(testPath):],
 			Value: element,
@@ -52,7 +107,7 @@ func Test_Find_All_Strings(t *testing.T) {
 }
 
 func Test_Find_All_Wrong_Path(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo("wrongPath", createMock())
+	repo := NewStringSSMParameterStoreRepo("wrongPath", createFake(t))
 
 	items, err := repo.FindAll()
 
@@ -65,7 +120,7 @@ func Test_Find_All_Wrong_Path(t *testing.T) {
 }
 
 func Test_Save(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo(testPath, createMock())
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
 	addedTestKey := "addedTestKey"
 	addedTestValue := "addedTestValue"
 
@@ -87,7 +142,7 @@ func Test_Save(t *testing.T) {
 }
 
 func Test_Save_Twice(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo(testPath, createMock())
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
 	addedTestKey := "addedTestKey"
 	addedTestValue := "addedTestValue"
 
@@ -107,7 +162,7 @@ func Test_Save_Twice(t *testing.T) {
 }
 
 func Test_Overwrite(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo(testPath, createMock())
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
 	addedTestKey := "addedTestKey"
 	addedTestValue := "addedTestValue"
 
@@ -127,7 +182,7 @@ func Test_Overwrite(t *testing.T) {
 }
 
 func Test_Save_And_Find_String(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo(testPath, createMock())
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
 	addedTestKey := "addedTestKey"
 	addedTestValue := "addedTestValue"
 
@@ -151,14 +206,7 @@ func Test_Save_And_Find(t *testing.T) {
 	addedTestValue := serialization.MockItem{
 		MockString: "Test",
 	}
-	mock := mockSSM{
-		mapItem: map[string]interface{}{
-			testPath + testKey: addedTestValue,
-		},
-		path: testPath,
-	}
-
-	repo := NewSSMParameterStoreRepo(testPath, &mock, serialization.MockItem{})
+	repo := NewSSMParameterStoreRepo(testPath, createFake(t), serialization.MockItem{})
 
 	_, err := repo.Save(addedTestKey, addedTestValue)
 	if err != nil {
@@ -176,7 +224,7 @@ func Test_Save_And_Find(t *testing.T) {
 }
 
 func Test_Delete(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo(testPath, createMock())
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
 
 	err := repo.Delete(testKey)
 
@@ -186,7 +234,7 @@ func Test_Delete(t *testing.T) {
 }
 
 func Test_Delete_Wrong_path(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo("wrongPath", createMock())
+	repo := NewStringSSMParameterStoreRepo("wrongPath", createFake(t))
 
 	err := repo.Delete(testKey)
 
@@ -195,8 +243,18 @@ func Test_Delete_Wrong_path(t *testing.T) {
 	}
 }
 
+func Test_Delete_Missing_Is_Not_Found(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	err := repo.Delete("missing")
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected not found error but found %v", err)
+	}
+}
+
 func Test_Find(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo(testPath, createMock())
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
 
 	value, err := repo.Find(testKey)
 
@@ -216,7 +274,7 @@ func Test_Find(t *testing.T) {
 }
 
 func Test_Find_Wrong_Path(t *testing.T) {
-	repo := NewStringSSMParameterStoreRepo("wrongPath", createMock())
+	repo := NewStringSSMParameterStoreRepo("wrongPath", createFake(t))
 
 	value, err := repo.Find(testKey)
 
@@ -225,15 +283,561 @@ func Test_Find_Wrong_Path(t *testing.T) {
 	}
 	empty := repository.KeyValuePair{}
 	if value != empty {
-		t.Errorf("Value should be empty but it is %+s", value)
+		t.Errorf("Value should be empty but it is %+v", value)
+	}
+}
+
+func Test_Find_Missing_Is_Not_Found(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	_, err := repo.Find("missing")
+
+	if !errors.Is(err, repository.ErrNotFound) || !isAWSErrorCode(err, ssm.ErrCodeParameterNotFound) {
+		t.Errorf("Expected not found error but found %v", err)
 	}
 }
 
 func contains(s []repository.KeyValuePair, e repository.KeyValuePair) bool {
 	for _, a := range s {
-		if a == e {
+		if a.Key == e.Key && a.Value == e.Value {
 			return true
 		}
 	}
 	return false
 }
+
+func Test_Increment_Missing_Key(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	value, err := repo.Increment("counter", 2)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if value != 2 {
+		t.Errorf("Expected 2 but received %d", value)
+	}
+}
+
+func Test_Increment_Existing_Key(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	_, err := repo.Save("counter", "40")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	value, err := repo.Increment("counter", 2)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if value != 42 {
+		t.Errorf("Expected 42 but received %d", value)
+	}
+	item, err := repo.Find("counter")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if item.Value != "42" {
+		t.Errorf("Expected 42 but received %v", item.Value)
+	}
+}
+
+func Test_Increment_Concurrent_Writer(t *testing.T) {
+	fake := createFake(t)
+	repo := NewStringSSMParameterStoreRepo(testPath, fake)
+	_, err := repo.Save("counter", "10")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	// simulate another lambda incrementing between read and write
+	fake.BeforePut = func(input *ssm.PutParameterInput) {
+		_, err := fake.PutParameter(&ssm.PutParameterInput{
+			Name:      input.Name,
+			Value:     aws.String("11"),
+			Overwrite: aws.Bool(true),
+		})
+		if err != nil {
+			t.Errorf("Expected nil but found error: %+s", err)
+		}
+	}
+
+	_, err = repo.Increment("counter", 1)
+
+	if !errors.Is(err, repository.ErrConditionFailed) {
+		t.Errorf("Expected %v but found %v", repository.ErrConditionFailed, err)
+	}
+	item, err := repo.Find("counter")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if item.Value != "11" {
+		t.Errorf("Expected value of other writer 11 but received %v", item.Value)
+	}
+	value, err := repo.Increment("counter", 1)
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if value != 12 {
+		t.Errorf("Expected 12 but received %d", value)
+	}
+}
+
+func Test_Increment_Concurrent(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	increments := 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < increments; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Increment("counter", 1)
+			for errors.Is(err, repository.ErrConditionFailed) {
+				_, err = repo.Increment("counter", 1)
+			}
+			if err != nil {
+				t.Errorf("Expected nil but found error: %+s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	item, err := repo.Find("counter")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if item.Value != strconv.Itoa(increments) {
+		t.Errorf("Expected %d but received %v", increments, item.Value)
+	}
+}
+
+func Test_Increment_Non_Numeric(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	_, err := repo.Increment(testKey, 1)
+
+	if err == nil {
+		t.Error("Error should not be nil")
+	}
+}
+
+func Test_Patch(t *testing.T) {
+	repo := NewSSMParameterStoreRepo(testPath, createFake(t), serialization.NestedMockItem{})
+	_, err := repo.Save(testKey+"3", serialization.NestedMockItem{
+		NestedItem: serialization.MockItem{MockString: "a"},
+	})
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	result, err := repo.Patch(testKey+"3", []byte(`{"NestedItem":{"MockString":"b"}}`))
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	expected := serialization.NestedMockItem{
+		NestedItem: serialization.MockItem{MockString: "b"},
+	}
+	if result.Value != expected {
+		t.Errorf("Expected %+v but received %+v", expected, result.Value)
+	}
+	stored, _ := repo.Find(testKey + "3")
+	if stored.Value != expected {
+		t.Errorf("Expected %+v but received %+v", expected, stored.Value)
+	}
+}
+
+func Test_Patch_Missing_Key(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	_, err := repo.Patch("missing", []byte(`{"a":"b"}`))
+
+	if err == nil {
+		t.Error("Error should not be nil")
+	}
+}
+
+func Test_Find_Metadata(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	_, err := repo.Overwrite(testKey, "updated")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	item, err := repo.Find(testKey)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if item.Metadata == nil || item.Metadata.Version != 2 || item.Metadata.UpdatedAt.IsZero() {
+		t.Errorf("Expected version 2 with update date but found %+v", item.Metadata)
+	}
+}
+
+func Test_Find_All_Metadata(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	items, err := repo.FindAll()
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	for _, item := range items {
+		if item.Metadata == nil || item.Metadata.Version != 1 {
+			t.Errorf("Expected version 1 but found %+v", item.Metadata)
+		}
+	}
+}
+
+func Test_Tag(t *testing.T) {
+	fake := createFake(t)
+	repo := NewStringSSMParameterStoreRepo(testPath, fake, WithTags())
+
+	err := repo.Tag(testKey, map[string]string{"owner": "me"})
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	item, err := repo.Find(testKey)
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	withoutTags, err := NewStringSSMParameterStoreRepo(testPath, fake).Find(testKey)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if item.Metadata.Tags["owner"] != "me" {
+		t.Errorf("Expected tag owner but found %+v", item.Metadata.Tags)
+	}
+	if withoutTags.Metadata.Tags != nil {
+		t.Errorf("Expected no tags without WithTags but found %+v", withoutTags.Metadata.Tags)
+	}
+}
+
+func Test_Tag_Missing(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	err := repo.Tag("missing", map[string]string{"owner": "me"})
+
+	if err == nil {
+		t.Error("Error should not be nil")
+	}
+}
+
+func Test_History(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	_, err := repo.Overwrite(testKey, "updated")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	versions, err := repo.History(testKey)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions but found %d", len(versions))
+	}
+	if versions[0].Value != testValue || versions[0].Metadata.Version != 1 {
+		t.Errorf("Expected first version to be %s but found %+v", testValue, versions[0])
+	}
+	if versions[1].Value != "updated" || versions[1].Metadata.Version != 2 {
+		t.Errorf("Expected second version to be updated but found %+v", versions[1])
+	}
+}
+
+func Test_History_Missing(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	_, err := repo.History("missing")
+
+	if err == nil {
+		t.Error("Error should not be nil")
+	}
+}
+
+func createHierarchyFake(t *testing.T) *ssmfake.SSM {
+	return createFakeWithParameters(t, map[string]string{
+		testPath + "name":              `{"MockString":"name"}`,
+		testPath + "database/host":     `{"MockString":"host"}`,
+		testPath + "database/user/key": `{"MockString":"key"}`,
+	})
+}
+
+func Test_Find_All_Decodes_Values(t *testing.T) {
+	repo := NewSSMParameterStoreRepo(testPath, createHierarchyFake(t), serialization.MockItem{})
+
+	items, err := repo.FindAll()
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if len(items) != 1 || items[0].Value != (serialization.MockItem{MockString: "name"}) {
+		t.Errorf("Expected decoded direct child but found %+v", items)
+	}
+}
+
+func Test_Find_All_Decode_Error(t *testing.T) {
+	repo := NewSSMParameterStoreRepo(testPath, createFake(t), serialization.MockItem{})
+
+	items, err := repo.FindAll()
+
+	if err == nil {
+		t.Errorf("Expected error for values which are no json but found %+v", items)
+	}
+}
+
+func Test_Find_All_Recursive(t *testing.T) {
+	repo := NewSSMParameterStoreRepo(testPath, createHierarchyFake(t), serialization.MockItem{}, WithRecursive())
+
+	items, err := repo.FindAll()
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	expected := repository.KeyValuePair{Key: "database/user/key", Value: serialization.MockItem{MockString: "key"}}
+	if len(items) != 3 || !contains(items, expected) {
+		t.Errorf("Expected %+v in all nested items but found %+v", expected, items)
+	}
+	found, err := repo.Find(expected.Key)
+	if err != nil || found.Value != expected.Value {
+		t.Errorf("Expected to find %+v by its relative key but found %+v. Error: %v", expected, found, err)
+	}
+}
+
+func Test_Tree(t *testing.T) {
+	repo := NewSSMParameterStoreRepo(testPath, createHierarchyFake(t), serialization.MockItem{})
+
+	tree, err := repo.Tree()
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	expected := map[string]interface{}{
+		"name": serialization.MockItem{MockString: "name"},
+		"database": map[string]interface{}{
+			"host": serialization.MockItem{MockString: "host"},
+			"user": map[string]interface{}{
+				"key": serialization.MockItem{MockString: "key"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, tree)
+	}
+}
+
+func Test_Tree_Value_And_Parent(t *testing.T) {
+	fake := createHierarchyFake(t)
+	_, err := fake.PutParameter(&ssm.PutParameterInput{
+		Name:  aws.String(testPath + "database"),
+		Value: aws.String(`{"MockString":"database"}`),
+		Type:  aws.String(ssm.ParameterTypeString),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewSSMParameterStoreRepo(testPath, fake, serialization.MockItem{})
+
+	_, err = repo.Tree()
+
+	if err == nil {
+		t.Error("Error should not be nil")
+	}
+}
+
+func Test_Find_Version(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	_, err := repo.Overwrite(testKey, "updated")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	first, err := repo.FindVersion(testKey, 1)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if first.Value != testValue || first.Metadata.Version != 1 {
+		t.Errorf("Expected first version but found %+v", first)
+	}
+	if _, err := repo.FindVersion(testKey, 3); err == nil {
+		t.Error("Error should not be nil for missing version")
+	}
+}
+
+func Test_Label_Version(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	_, err := repo.Overwrite(testKey, "canary value")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	if err := repo.LabelVersion(testKey, 1, "stable", "canary"); err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	// labels are moved to the labeled version
+	if err := repo.LabelVersion(testKey, 2, "canary"); err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	stable, err := repo.FindLabel(testKey, "stable")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	canary, err := repo.FindLabel(testKey, "canary")
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+
+	if stable.Value != testValue || stable.Metadata.Version != 1 {
+		t.Errorf("Expected stable to be version 1 but found %+v", stable)
+	}
+	if canary.Value != "canary value" || canary.Metadata.Version != 2 {
+		t.Errorf("Expected canary to be version 2 but found %+v", canary)
+	}
+}
+
+func Test_Label_Version_Invalid(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	for _, labels := range [][]string{{"1stable"}, {"awsStable"}, {"sta ble"}} {
+		if err := repo.LabelVersion(testKey, 1, labels...); err == nil {
+			t.Errorf("Error should not be nil for labels %v", labels)
+		}
+	}
+	if err := repo.LabelVersion(testKey, 2, "stable"); err == nil {
+		t.Error("Error should not be nil for missing version")
+	}
+}
+
+func Test_Find_Label_Missing(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	_, err := repo.FindLabel(testKey, "stable")
+
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected not found error but found %v", err)
+	}
+}
+
+func Test_Find_Version_Missing_Is_Not_Found(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+	_, versionErr := repo.FindVersion(testKey, 2)
+	_, keyErr := repo.FindVersion("missing", 1)
+
+	if !errors.Is(versionErr, repository.ErrNotFound) || !errors.Is(keyErr, repository.ErrNotFound) {
+		t.Errorf("Expected not found errors but found %v and %v", versionErr, keyErr)
+	}
+}
+
+func Test_Save_Default_Type(t *testing.T) {
+	fake := createFake(t)
+	repo := NewStringSSMParameterStoreRepo(testPath, fake)
+
+	_, err := repo.Save("new", testValue)
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	output, err := fake.GetParameter(&ssm.GetParameterInput{Name: aws.String(testPath + "new")})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	if parameterType := *output.Parameter.Type; parameterType != ssm.ParameterTypeSecureString {
+		t.Errorf("Expected SecureString but found %s", parameterType)
+	}
+}
+
+func Test_Save_With_Options(t *testing.T) {
+	fake := createFake(t)
+	repo := NewStringSSMParameterStoreRepo(testPath, fake, WithParameterOptions(ParameterOptions{
+		Type:        ssm.ParameterTypeString,
+		Description: "description",
+	}))
+
+	_, err := repo.SaveWithOptions("new", testValue, ParameterOptions{
+		Tier:     ssm.ParameterTierAdvanced,
+		Policies: []ParameterPolicy{ExpirationPolicy(time.Now().Add(time.Hour)), NoChangeNotificationPolicy(5, "Days")},
+		Tags:     map[string]string{"owner": "me"},
+	})
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	history, err := fake.GetParameterHistory(&ssm.GetParameterHistoryInput{Name: aws.String(testPath + "new")})
+	if err != nil {
+		t.Fatalf("Expected nil but found error: %+s", err)
+	}
+	latest := history.Parameters[0]
+	if *latest.Type != ssm.ParameterTypeString || *latest.Tier != ssm.ParameterTierAdvanced ||
+		*latest.Description != "description" || len(latest.Policies) != 2 {
+		t.Errorf("Options were not applied: %+v", latest)
+	}
+	if tags := findTags(t, fake, testPath+"new"); tags["owner"] != "me" {
+		t.Errorf("Expected tag owner but found %+v", tags)
+	}
+}
+
+func Test_Overwrite_With_Tags(t *testing.T) {
+	fake := createFake(t)
+	repo := NewStringSSMParameterStoreRepo(testPath, fake)
+
+	_, err := repo.OverwriteWithOptions(testKey, "new", ParameterOptions{
+		Tags: map[string]string{"owner": "me"},
+	})
+
+	if err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+	if tags := findTags(t, fake, testPath+testKey); tags["owner"] != "me" {
+		t.Errorf("Expected tag owner but found %+v", tags)
+	}
+}
+
+func Test_Save_With_Invalid_Options(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ParameterOptions
+	}{
+		{
+			name:    "key id without SecureString",
+			options: ParameterOptions{Type: ssm.ParameterTypeString, KeyID: "alias/key"},
+		}, {
+			name:    "policies in standard tier",
+			options: ParameterOptions{Policies: []ParameterPolicy{ExpirationNotificationPolicy(1, "Days")}},
+		}, {
+			name:    "unknown policy",
+			options: ParameterOptions{Tier: ssm.ParameterTierAdvanced, Policies: []ParameterPolicy{{Type: "Unknown", Version: "1.0"}}},
+		}, {
+			name:    "pattern mismatch",
+			options: ParameterOptions{AllowedPattern: "^[0-9]+$"},
+		}, {
+			name:    "invalid type",
+			options: ParameterOptions{Type: "Binary"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+
+			if _, err := repo.SaveWithOptions("new", testValue, tt.options); err == nil {
+				t.Error("Error should not be nil")
+			}
+		})
+	}
+}
+
+func Test_Save_Exceeds_Standard_Tier(t *testing.T) {
+	repo := NewStringSSMParameterStoreRepo(testPath, createFake(t))
+	value := strings.Repeat("a", 5000)
+
+	if _, err := repo.Save("new", value); err == nil {
+		t.Error("Error should not be nil")
+	}
+	if _, err := repo.SaveWithOptions("new", value, ParameterOptions{Tier: ssm.ParameterTierAdvanced}); err != nil {
+		t.Errorf("Expected nil but found error: %+s", err)
+	}
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// DecodeValue converts a stored value into the struct target points to, independent of the
+// decoding of the repository. Values can be the struct itself, a pointer to it or a json
+// string, which is the case for repositories without item template.
+func DecodeValue(value interface{}, target interface{}) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return fmt.Errorf("target of type %T has to be a non-nil pointer", target)
+	}
+	targetType := targetValue.Elem().Type()
+
+	if jsonString, ok := value.(string); ok {
+		return json.Unmarshal([]byte(jsonString), target)
+	}
+	decoded := reflect.ValueOf(value)
+	if decoded.Kind() == reflect.Ptr && decoded.Type().Elem() == targetType && !decoded.IsNil() {
+		decoded = decoded.Elem()
+	}
+	if !decoded.IsValid() || decoded.Type() != targetType {
+		return fmt.Errorf("unexpected value of type %T for %s", value, targetType)
+	}
+	targetValue.Elem().Set(decoded)
+	return nil
+}
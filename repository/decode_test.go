@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"testing"
+)
+
+func TestDecodeValue(t *testing.T) {
+	expected := patchStruct{Name: "a", Count: 1}
+
+	for _, value := range []interface{}{expected, &expected, `{"Name":"a","Count":1}`} {
+		result := patchStruct{}
+		err := DecodeValue(value, &result)
+
+		checkError(err, t)
+		if result != expected {
+			t.Errorf("Expected %+v for %#v but found %+v", expected, value, result)
+		}
+	}
+}
+
+func TestDecodeValueUnexpectedType(t *testing.T) {
+	result := patchStruct{}
+
+	for _, value := range []interface{}{nil, 5, (*patchStruct)(nil), `{"Name":5}`} {
+		if err := DecodeValue(value, &result); err == nil {
+			t.Errorf("Expected error for %#v", value)
+		}
+	}
+}
+
+func TestDecodeValueInvalidTarget(t *testing.T) {
+	err := DecodeValue("{}", patchStruct{})
+
+	checkFailure(err, t)
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// hashTagName is the struct tag used to select the fields of a struct which are hashed
+const hashTagName = "hash"
+
+// Hasher creates the key of a value for the HashKeyValueRepo
+type Hasher interface {
+	Hash(in interface{}) (string, error)
+}
+
+// MD5Hasher creates keys as done by ToKey. The key depends on the formatting of the value,
+// e.g. pointers are not dereferenced. It is only meant to access data stored with older versions.
+type MD5Hasher struct{}
+
+// Hash creates the key of a value
+func (hasher MD5Hasher) Hash(in interface{}) (string, error) {
+	return ToKey(in), nil
+}
+
+// CanonicalHasher creates a SHA-256 hash of the canonical json representation of a value.
+// Equal values result in the same key, independent of map order or pointer indirection.
+//
+// Fields of structs can be selected via the struct tag `hash`. Fields tagged with `hash:"-"`
+// are ignored. If any field of a struct is tagged otherwise, e.g. `hash:"true"`, only the
+// tagged fields of this struct are hashed.
+type CanonicalHasher struct{}
+
+// Hash creates the key of a value
+func (hasher CanonicalHasher) Hash(in interface{}) (string, error) {
+	canonical, err := toCanonical(reflect.ValueOf(in))
+	if err != nil {
+		return "", err
+	}
+	// json encoding sorts map keys which results in a canonical form
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Rekey moves all items of a repository to the keys created by the hasher.
+// This allows to migrate data stored with another hasher, e.g. the MD5Hasher.
+// It returns the number of moved items.
+func Rekey(repo KeyValueRepo, hasher Hasher) (int, error) {
+	items, err := repo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, item := range items {
+		key, err := hasher.Hash(item.Value)
+		if err != nil {
+			return moved, err
+		}
+		if key == item.Key {
+			continue
+		}
+		// an equal value might have been stored with the new key already
+		if _, err := repo.Find(key); err != nil {
+			if _, err := repo.Save(key, item.Value); err != nil {
+				return moved, err
+			}
+		}
+		if err := repo.Delete(item.Key); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// toCanonical converts a value into maps, slices and primitives
+func toCanonical(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if value.Type().Implements(jsonMarshalerType) {
+		if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+			return nil, nil
+		}
+		return fromJSON(value.Interface())
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return toCanonical(value.Elem())
+	case reflect.Struct:
+		return structToCanonical(value)
+	case reflect.Map:
+		if value.IsNil() {
+			return nil, nil
+		}
+		result := make(map[string]interface{}, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			element, err := toCanonical(iterator.Value())
+			if err != nil {
+				return nil, err
+			}
+			result[fmt.Sprint(iterator.Key().Interface())] = element
+		}
+		return result, nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil, nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return fromJSON(value.Interface())
+		}
+		result := make([]interface{}, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element, err := toCanonical(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = element
+		}
+		return result, nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, fmt.Errorf("type %s can not be hashed", value.Type())
+	default:
+		return fromJSON(value.Interface())
+	}
+}
+
+func structToCanonical(value reflect.Value) (interface{}, error) {
+	valueType := value.Type()
+	selective := false
+	for i := 0; i < valueType.NumField(); i++ {
+		if tag, ok := valueType.Field(i).Tag.Lookup(hashTagName); ok && tag != "-" {
+			selective = true
+		}
+	}
+
+	result := make(map[string]interface{})
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		tag, tagged := field.Tag.Lookup(hashTagName)
+		if tag == "-" || (selective && !tagged) {
+			continue
+		}
+
+		name := field.Name
+		if jsonTag, ok := field.Tag.Lookup("json"); ok {
+			jsonName := strings.Split(jsonTag, ",")[0]
+			if jsonName == "-" {
+				continue
+			}
+			if jsonName != "" {
+				name = jsonName
+			}
+		}
+
+		element, err := toCanonical(value.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		result[name] = element
+	}
+	return result, nil
+}
+
+// fromJSON converts a value via its json representation
+func fromJSON(in interface{}) (interface{}, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	err = unmarshalUseNumber(data, &result)
+	return result, err
+}
+
+func unmarshalUseNumber(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
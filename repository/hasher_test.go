@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+type hashStruct struct {
+	Name    string
+	Values  map[string]int
+	Ignored string `hash:"-"`
+}
+
+type selectiveHashStruct struct {
+	ID      string `hash:"true"`
+	Comment string
+}
+
+func TestCanonicalHasherPointer(t *testing.T) {
+	hasher := CanonicalHasher{}
+	value := hashStruct{Name: "a"}
+
+	a, err := hasher.Hash(value)
+	checkError(err, t)
+	b, err := hasher.Hash(&value)
+	checkError(err, t)
+
+	if a != b {
+		t.Errorf("Expected '%s' be equal to '%s'", a, b)
+	}
+}
+
+func TestCanonicalHasherMapOrder(t *testing.T) {
+	hasher := CanonicalHasher{}
+	first := map[string]int{}
+	second := map[string]int{}
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		first[key] = i
+	}
+	for i, key := range []string{"e", "d", "c", "b", "a"} {
+		second[key] = 4 - i
+	}
+
+	a, err := hasher.Hash(hashStruct{Values: first})
+	checkError(err, t)
+	b, err := hasher.Hash(hashStruct{Values: second})
+	checkError(err, t)
+
+	if a != b {
+		t.Errorf("Expected '%s' be equal to '%s'", a, b)
+	}
+}
+
+func TestCanonicalHasherUnequal(t *testing.T) {
+	hasher := CanonicalHasher{}
+
+	a, err := hasher.Hash(hashStruct{Name: "dummy"})
+	checkError(err, t)
+	b, err := hasher.Hash(hashStruct{Name: "dummie"})
+	checkError(err, t)
+
+	if a == b {
+		t.Errorf("Expected '%s' be unequal to '%s'", a, b)
+	}
+}
+
+func TestCanonicalHasherIgnoredField(t *testing.T) {
+	hasher := CanonicalHasher{}
+
+	a, err := hasher.Hash(hashStruct{Name: "a", Ignored: "x"})
+	checkError(err, t)
+	b, err := hasher.Hash(hashStruct{Name: "a", Ignored: "y"})
+	checkError(err, t)
+
+	if a != b {
+		t.Errorf("Expected '%s' be equal to '%s'", a, b)
+	}
+}
+
+func TestCanonicalHasherSelectedFields(t *testing.T) {
+	hasher := CanonicalHasher{}
+
+	a, err := hasher.Hash(selectiveHashStruct{ID: "1", Comment: "x"})
+	checkError(err, t)
+	b, err := hasher.Hash(selectiveHashStruct{ID: "1", Comment: "y"})
+	checkError(err, t)
+	c, err := hasher.Hash(selectiveHashStruct{ID: "2", Comment: "x"})
+	checkError(err, t)
+
+	if a != b {
+		t.Errorf("Expected '%s' be equal to '%s'", a, b)
+	}
+	if a == c {
+		t.Errorf("Expected '%s' be unequal to '%s'", a, c)
+	}
+}
+
+func TestCanonicalHasherMarshaler(t *testing.T) {
+	hasher := CanonicalHasher{}
+	now := time.Now()
+
+	a, err := hasher.Hash(map[string]interface{}{"time": now})
+	checkError(err, t)
+	b, err := hasher.Hash(map[string]interface{}{"time": &now})
+	checkError(err, t)
+
+	if a != b {
+		t.Errorf("Expected '%s' be equal to '%s'", a, b)
+	}
+}
+
+func TestCanonicalHasherUnsupported(t *testing.T) {
+	_, err := CanonicalHasher{}.Hash(func() {})
+
+	if err == nil {
+		t.Error("Expected error for function value")
+	}
+}
+
+func TestRekey(t *testing.T) {
+	repo := NewInMemoryRepo()
+	value := hashStruct{Name: "a"}
+	_, err := NewHashKeyValueRepoWithHasher(repo, MD5Hasher{}).Save(value)
+	checkError(err, t)
+
+	moved, err := Rekey(repo, CanonicalHasher{})
+	checkError(err, t)
+
+	if moved != 1 {
+		t.Errorf("Expected 1 moved item but found %d", moved)
+	}
+	if !NewHashKeyValueRepo(repo).ContainsValue(value) {
+		t.Errorf("Could not find %v with canonical key", value)
+	}
+	items, _ := repo.FindAll()
+	if len(items) != 1 {
+		t.Errorf("Expected 1 item but found %d", len(items))
+	}
+}
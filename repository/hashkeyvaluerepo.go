@@ -12,12 +12,20 @@ import (
 // based upon a native persistence layer, but instead on an additional layer of abstraction.
 type HashKeyValueRepo struct {
 	wrappedRepo KeyValueRepo
+	hasher      Hasher
 }
 
-// NewHashKeyValueRepo creates a new instance and uses an initialized KeyValueRepo
+// NewHashKeyValueRepo creates a new instance and uses an initialized KeyValueRepo.
+// Keys are created with the CanonicalHasher.
 func NewHashKeyValueRepo(repo KeyValueRepo) *HashKeyValueRepo {
+	return NewHashKeyValueRepoWithHasher(repo, CanonicalHasher{})
+}
+
+// NewHashKeyValueRepoWithHasher creates a new instance which creates keys with the given hasher
+func NewHashKeyValueRepoWithHasher(repo KeyValueRepo, hasher Hasher) *HashKeyValueRepo {
 	return &HashKeyValueRepo{
 		wrappedRepo: repo,
+		hasher:      hasher,
 	}
 }
 
@@ -28,12 +36,20 @@ func (repo *HashKeyValueRepo) FindAll() ([]KeyValuePair, error) {
 
 // Save calls function of wrapped repository
 func (repo *HashKeyValueRepo) Save(in interface{}) (KeyValuePair, error) {
-	return repo.wrappedRepo.Save(ToKey(in), in)
+	key, err := repo.hasher.Hash(in)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Save(key, in)
 }
 
 // Overwrite calls function of wrapped repository
 func (repo *HashKeyValueRepo) Overwrite(in interface{}) (KeyValuePair, error) {
-	return repo.wrappedRepo.Overwrite(ToKey(in), in)
+	key, err := repo.hasher.Hash(in)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Overwrite(key, in)
 }
 
 // Delete calls function of wrapped repository
@@ -46,6 +62,12 @@ func (repo *HashKeyValueRepo) Find(key string) (KeyValuePair, error) {
 	return repo.wrappedRepo.Find(key)
 }
 
+// Patch applies a JSON merge patch to the item of the wrapped repository.
+// The key of the item is kept as is, even though it no longer reflects the patched value.
+func (repo *HashKeyValueRepo) Patch(key string, mergePatch []byte) (KeyValuePair, error) {
+	return Patch(repo.wrappedRepo, key, mergePatch)
+}
+
 // Count calls FindAll() and calculates the length
 func (repo *HashKeyValueRepo) Count() (int, error) {
 	items, _ := repo.FindAll()
@@ -60,10 +82,15 @@ func (repo *HashKeyValueRepo) Contains(key string) bool {
 
 // ContainsValue checks if a value is in the repository
 func (repo *HashKeyValueRepo) ContainsValue(in interface{}) bool {
-	return repo.Contains(ToKey(in))
+	key, err := repo.hasher.Hash(in)
+	if err != nil {
+		return false
+	}
+	return repo.Contains(key)
 }
 
-// ToKey transforms a struct into a hash key for this repository
+// ToKey transforms a struct into an MD5 based key. The key depends on the formatting
+// of the value, use a Hasher for stable keys.
 func ToKey(in interface{}) string {
 	h := md5.New()
 	h.Write([]byte(fmt.Sprintf("%v", in)))
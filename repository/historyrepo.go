@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+// VersionedRepo is implemented by repositories which natively keep previous versions of items
+type VersionedRepo interface {
+	// returns all versions of an item ordered from oldest to newest.
+	// The version and its creation date are part of the metadata.
+	History(key string) ([]KeyValuePair, error)
+}
+
+// HistoryRecord is the shadow record of a previous version of an item
+type HistoryRecord struct {
+	Key     string `json:"key"`
+	Version int64  `json:"version"`
+	// serialized value
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// point in time in which the version was overwritten or deleted
+	ReplacedAt time.Time `json:"replacedAt"`
+	Deleted    bool      `json:"deleted"`
+}
+
+// ToStruct converts json string to struct
+func (record HistoryRecord) ToStruct(jsonString string) (interface{}, error) {
+	err := json.Unmarshal([]byte(jsonString), &record)
+	return record, err
+}
+
+// HistoryRepo keeps previous versions of items stored in the wrapped repository.
+// If the wrapped repository implements VersionedRepo its history is used. Otherwise each
+// version which is overwritten or deleted is stored as HistoryRecord in the history repository
+// with the key "<key>.<version>". A copy of the latest record is kept as "<key>.latest" so that
+// the records of a key are read directly without listing the history repository.
+// Repositories which are decoding values need to use HistoryRecord as item template.
+//
+// Versions are numbered in the order they were written, starting with 1. Recording the history
+// is not atomic, concurrent writes to the same key may result in missing versions.
+type HistoryRepo struct {
+	wrappedRepo      KeyValueRepo
+	historyRepo      KeyValueRepo
+	toStructFunction func(jsonString string) (interface{}, error)
+}
+
+// NewHistoryRepo creates a HistoryRepo. The historyRepo stores the shadow records and may be nil
+// if the wrapped repository implements VersionedRepo. The toStruct function decodes values of
+// shadow records.
+func NewHistoryRepo(repo KeyValueRepo, historyRepo KeyValueRepo, toStruct func(jsonString string) (interface{}, error)) *HistoryRepo {
+	return &HistoryRepo{
+		wrappedRepo:      repo,
+		historyRepo:      historyRepo,
+		toStructFunction: toStruct,
+	}
+}
+
+// FindAll calls function of wrapped repository
+func (repo *HistoryRepo) FindAll() ([]KeyValuePair, error) {
+	return repo.wrappedRepo.FindAll()
+}
+
+// Find calls function of wrapped repository
+func (repo *HistoryRepo) Find(key string) (KeyValuePair, error) {
+	return repo.wrappedRepo.Find(key)
+}
+
+// Save calls function of wrapped repository
+func (repo *HistoryRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	return repo.wrappedRepo.Save(key, in)
+}
+
+// Overwrite records the current version of the item, if any, and overwrites it afterwards
+func (repo *HistoryRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	if repo.isNative() {
+		return repo.wrappedRepo.Overwrite(key, in)
+	}
+
+	previous, previousErr := repo.wrappedRepo.Find(key)
+	if previousErr != nil && !errors.Is(previousErr, ErrNotFound) {
+		return KeyValuePair{}, previousErr
+	}
+	result, err := repo.wrappedRepo.Overwrite(key, in)
+	if err != nil || previousErr != nil {
+		return result, err
+	}
+	return result, repo.record(previous, false)
+}
+
+// Delete records the current version of the item and deletes it afterwards
+func (repo *HistoryRepo) Delete(key string) error {
+	if repo.isNative() {
+		return repo.wrappedRepo.Delete(key)
+	}
+
+	previous, err := repo.wrappedRepo.Find(key)
+	if err != nil {
+		return err
+	}
+	if err := repo.wrappedRepo.Delete(key); err != nil {
+		return err
+	}
+	return repo.record(previous, true)
+}
+
+// History returns all versions of an item ordered from oldest to newest including the current
+// version. The metadata contains the version and the point in time it was written.
+func (repo *HistoryRepo) History(key string) ([]KeyValuePair, error) {
+	if versioned, ok := repo.wrappedRepo.(VersionedRepo); ok {
+		return versioned.History(key)
+	}
+
+	records, err := repo.findRecords(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]KeyValuePair, 0, len(records)+1)
+	for _, record := range records {
+		value, err := repo.toStructFunction(record.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, KeyValuePair{
+			Key:   key,
+			Value: value,
+			Metadata: &Metadata{
+				UpdatedAt: record.UpdatedAt,
+				Version:   record.Version,
+			},
+		})
+	}
+
+	current, err := repo.wrappedRepo.Find(key)
+	if err == nil {
+		current.Metadata = &Metadata{
+			UpdatedAt: updatedAt(current, time.Time{}),
+			Version:   int64(len(records) + 1),
+		}
+		result = append(result, current)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no history found for key %s", key)
+	}
+	return result, nil
+}
+
+// FindAt returns the version of an item which was current at the given point in time
+func (repo *HistoryRepo) FindAt(key string, at time.Time) (KeyValuePair, error) {
+	if versioned, ok := repo.wrappedRepo.(VersionedRepo); ok {
+		versions, err := versioned.History(key)
+		if err != nil {
+			return KeyValuePair{}, err
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			if !versions[i].Metadata.UpdatedAt.After(at) {
+				return versions[i], nil
+			}
+		}
+		return KeyValuePair{}, fmt.Errorf("key %s did not exist at %v", key, at)
+	}
+
+	records, err := repo.findRecords(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	for _, record := range records {
+		if !record.UpdatedAt.After(at) && record.ReplacedAt.After(at) {
+			value, err := repo.toStructFunction(record.Value)
+			return KeyValuePair{
+				Key:   key,
+				Value: value,
+				Metadata: &Metadata{
+					UpdatedAt: record.UpdatedAt,
+					Version:   record.Version,
+				},
+			}, err
+		}
+	}
+
+	current, err := repo.wrappedRepo.Find(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	since := time.Time{}
+	if len(records) > 0 {
+		since = records[len(records)-1].ReplacedAt
+	}
+	if updatedAt(current, since).After(at) {
+		return KeyValuePair{}, fmt.Errorf("key %s did not exist at %v", key, at)
+	}
+	return current, nil
+}
+
+// Restore overwrites an item with a previous version. The restored value becomes a new version.
+func (repo *HistoryRepo) Restore(key string, version int64) (KeyValuePair, error) {
+	versions, err := repo.History(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	for _, item := range versions {
+		if item.Metadata.Version == version {
+			return repo.Overwrite(key, item.Value)
+		}
+	}
+	return KeyValuePair{}, fmt.Errorf("could not find version %d of key %s", version, key)
+}
+
+func (repo *HistoryRepo) isNative() bool {
+	_, ok := repo.wrappedRepo.(VersionedRepo)
+	return ok
+}
+
+// record stores a shadow record of a replaced item
+func (repo *HistoryRepo) record(previous KeyValuePair, deleted bool) error {
+	if repo.historyRepo == nil {
+		return fmt.Errorf("no history repository configured")
+	}
+	latest := repo.findLatestRecord(previous.Key)
+	serialized, err := serialization.ToJSON(previous.Value)
+	if err != nil {
+		return err
+	}
+
+	record := HistoryRecord{
+		Key:        previous.Key,
+		Version:    latest.Version + 1,
+		Value:      serialized,
+		UpdatedAt:  updatedAt(previous, latest.ReplacedAt),
+		ReplacedAt: time.Now(),
+		Deleted:    deleted,
+	}
+	// saving fails if the version was already recorded by a concurrent write
+	if _, err = repo.historyRepo.Save(recordKey(previous.Key, record.Version), record); err != nil {
+		return err
+	}
+	_, err = repo.historyRepo.Overwrite(latestRecordKey(previous.Key), record)
+	return err
+}
+
+// findRecords returns the shadow records of a key ordered by version.
+// The records are read directly by their keys up to the latest recorded version.
+func (repo *HistoryRepo) findRecords(key string) ([]HistoryRecord, error) {
+	if repo.historyRepo == nil {
+		return nil, fmt.Errorf("no history repository configured")
+	}
+	latest := repo.findLatestRecord(key)
+
+	records := make([]HistoryRecord, 0, latest.Version)
+	for version := int64(1); version <= latest.Version; version++ {
+		item, err := repo.historyRepo.Find(recordKey(key, version))
+		if err != nil {
+			return nil, err
+		}
+		record, err := toHistoryRecord(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// findLatestRecord returns a copy of the latest shadow record of a key or an empty record
+// if no version was recorded yet
+func (repo *HistoryRepo) findLatestRecord(key string) HistoryRecord {
+	item, err := repo.historyRepo.Find(latestRecordKey(key))
+	if err != nil {
+		return HistoryRecord{}
+	}
+	record, err := toHistoryRecord(item.Value)
+	if err != nil {
+		return HistoryRecord{}
+	}
+	return record
+}
+
+func recordKey(key string, version int64) string {
+	return fmt.Sprintf("%s.%d", key, version)
+}
+
+func latestRecordKey(key string) string {
+	return key + ".latest"
+}
+
+// updatedAt returns the update date of the metadata or the fallback if the repository
+// does not provide metadata
+func updatedAt(item KeyValuePair, fallback time.Time) time.Time {
+	if item.Metadata == nil || item.Metadata.UpdatedAt.IsZero() {
+		return fallback
+	}
+	return item.Metadata.UpdatedAt
+}
+
+// toHistoryRecord converts stored values into a record independent of the decoding of the repository
+func toHistoryRecord(value interface{}) (HistoryRecord, error) {
+	result := HistoryRecord{}
+	err := DecodeValue(value, &result)
+	return result, err
+}
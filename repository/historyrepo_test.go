@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func toStringStruct(jsonString string) (interface{}, error) {
+	return jsonString, nil
+}
+
+func createHistoryRepo() *HistoryRepo {
+	return NewHistoryRepo(NewInMemoryRepo(), NewInMemoryRepo(), toStringStruct)
+}
+
+func TestHistoryRepoHistory(t *testing.T) {
+	repo := createHistoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "b")
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "c")
+	checkError(err, t)
+
+	versions, err := repo.History("key")
+	checkError(err, t)
+
+	if len(versions) != 3 {
+		t.Fatalf("Expected 3 versions but found %d", len(versions))
+	}
+	for i, expected := range []string{"a", "b", "c"} {
+		if versions[i].Value != expected || versions[i].Metadata.Version != int64(i+1) {
+			t.Errorf("Expected version %d to be %s but found %+v", i+1, expected, versions[i])
+		}
+	}
+}
+
+// unlistableRepo fails to list its items
+type unlistableRepo struct {
+	*InMemoryRepo
+}
+
+func (repo *unlistableRepo) FindAll() ([]KeyValuePair, error) {
+	return nil, fmt.Errorf("listing is not supported")
+}
+
+func TestHistoryRepoHistoryWithoutListing(t *testing.T) {
+	repo := NewHistoryRepo(NewInMemoryRepo(), &unlistableRepo{NewInMemoryRepo()}, toStringStruct)
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "b")
+	checkError(err, t)
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	versions, err := repo.History("key")
+	checkError(err, t)
+
+	if len(versions) != 2 || versions[0].Value != "a" || versions[1].Metadata.Version != 2 {
+		t.Errorf("Expected versions a and b but found %+v", versions)
+	}
+}
+
+func TestHistoryRepoOverwriteUnavailable(t *testing.T) {
+	wrapped := &failingRepo{InMemoryRepo: NewInMemoryRepo()}
+	repo := NewHistoryRepo(wrapped, NewInMemoryRepo(), toStringStruct)
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	wrapped.fail(1)
+
+	_, err = repo.Overwrite("key", "b")
+	checkFailure(err, t)
+	found, err := wrapped.Find("key")
+	checkError(err, t)
+
+	if found.Value != "a" {
+		t.Errorf("Expected unchanged value a but found %v", found.Value)
+	}
+}
+
+func TestHistoryRepoHistoryMissing(t *testing.T) {
+	repo := createHistoryRepo()
+
+	_, err := repo.History("invalid")
+
+	checkFailure(err, t)
+}
+
+func TestHistoryRepoHistoryAfterDelete(t *testing.T) {
+	repo := createHistoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	versions, err := repo.History("key")
+	checkError(err, t)
+
+	if len(versions) != 1 || versions[0].Value != "a" {
+		t.Errorf("Expected deleted version to be kept but found %+v", versions)
+	}
+}
+
+func TestHistoryRepoFindAt(t *testing.T) {
+	repo := createHistoryRepo()
+	beforeSave := time.Now()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	afterSave := time.Now()
+	_, err = repo.Overwrite("key", "b")
+	checkError(err, t)
+	afterOverwrite := time.Now()
+
+	_, err = repo.FindAt("key", beforeSave.Add(-time.Second))
+	checkFailure(err, t)
+	first, err := repo.FindAt("key", afterSave)
+	checkError(err, t)
+	second, err := repo.FindAt("key", afterOverwrite)
+	checkError(err, t)
+
+	if first.Value != "a" {
+		t.Errorf("Expected a but found %v", first.Value)
+	}
+	if second.Value != "b" {
+		t.Errorf("Expected b but found %v", second.Value)
+	}
+}
+
+func TestHistoryRepoFindAtDeleted(t *testing.T) {
+	repo := createHistoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	afterSave := time.Now()
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	item, err := repo.FindAt("key", afterSave)
+	checkError(err, t)
+	_, err = repo.FindAt("key", time.Now())
+	checkFailure(err, t)
+
+	if item.Value != "a" {
+		t.Errorf("Expected a but found %v", item.Value)
+	}
+}
+
+func TestHistoryRepoRestore(t *testing.T) {
+	repo := createHistoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "b")
+	checkError(err, t)
+
+	_, err = repo.Restore("key", 1)
+	checkError(err, t)
+
+	item, _ := repo.Find("key")
+	if item.Value != "a" {
+		t.Errorf("Expected a but found %v", item.Value)
+	}
+	versions, _ := repo.History("key")
+	if len(versions) != 3 {
+		t.Errorf("Expected restore to create a new version but found %d versions", len(versions))
+	}
+}
+
+func TestHistoryRepoRestoreInvalidVersion(t *testing.T) {
+	repo := createHistoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+
+	_, err = repo.Restore("key", 5)
+
+	checkFailure(err, t)
+}
+
+func TestHistoryRepoNative(t *testing.T) {
+	wrapped := &versionedRepo{InMemoryRepo: NewInMemoryRepo()}
+	repo := NewHistoryRepo(wrapped, nil, toStringStruct)
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "b")
+	checkError(err, t)
+
+	_, err = repo.Restore("key", 1)
+	checkError(err, t)
+
+	item, _ := repo.Find("key")
+	if item.Value != "a" {
+		t.Errorf("Expected a but found %v", item.Value)
+	}
+}
+
+// versionedRepo records all written values like a repository with native versioning
+type versionedRepo struct {
+	*InMemoryRepo
+	versions []KeyValuePair
+}
+
+func (repo *versionedRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	item, err := repo.InMemoryRepo.Save(key, in)
+	repo.versions = append(repo.versions, item)
+	return item, err
+}
+
+func (repo *versionedRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	item, err := repo.InMemoryRepo.Overwrite(key, in)
+	repo.versions = append(repo.versions, item)
+	return item, err
+}
+
+func (repo *versionedRepo) History(key string) ([]KeyValuePair, error) {
+	return repo.versions, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SaveEvent describes a pending Save or Overwrite. Hooks may change the key and the value.
+type SaveEvent struct {
+	// either OperationSave or OperationOverwrite
+	Operation Operation
+	Key       string
+	Value     interface{}
+}
+
+// BeforeSaveHook is called before an item is saved or overwritten. Returning an error
+// vetoes the operation.
+type BeforeSaveHook func(event *SaveEvent) error
+
+// AfterSaveHook is called after an item was saved or overwritten successfully
+type AfterSaveHook func(operation Operation, item KeyValuePair) error
+
+// BeforeDeleteHook is called before an item is deleted. Returning an error vetoes the operation.
+type BeforeDeleteHook func(key string) error
+
+// AfterDeleteHook is called after an item was deleted successfully
+type AfterDeleteHook func(key string) error
+
+// AfterFindHook is called for each item returned by Find and FindAll. Hooks may change the item,
+// returning an error fails the read.
+type AfterFindHook func(item *KeyValuePair) error
+
+// HookedRepo runs hooks around the operations of the wrapped repository, e.g. for audit logging
+// or cache invalidation. Hooks are called in the order they were registered. The first hook
+// returning an error stops the chain and the error is returned to the caller. Errors of after
+// hooks are returned even though the operation itself succeeded.
+type HookedRepo struct {
+	wrappedRepo  KeyValueRepo
+	beforeSave   []BeforeSaveHook
+	afterSave    []AfterSaveHook
+	beforeDelete []BeforeDeleteHook
+	afterDelete  []AfterDeleteHook
+	afterFind    []AfterFindHook
+	mutex        sync.RWMutex
+}
+
+// NewHookedRepo creates a HookedRepo without hooks
+func NewHookedRepo(repo KeyValueRepo) *HookedRepo {
+	return &HookedRepo{
+		wrappedRepo: repo,
+	}
+}
+
+// BeforeSave registers a hook which is called before Save and Overwrite
+func (repo *HookedRepo) BeforeSave(hook BeforeSaveHook) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.beforeSave = append(repo.beforeSave, hook)
+}
+
+// AfterSave registers a hook which is called after Save and Overwrite
+func (repo *HookedRepo) AfterSave(hook AfterSaveHook) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.afterSave = append(repo.afterSave, hook)
+}
+
+// BeforeDelete registers a hook which is called before Delete
+func (repo *HookedRepo) BeforeDelete(hook BeforeDeleteHook) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.beforeDelete = append(repo.beforeDelete, hook)
+}
+
+// AfterDelete registers a hook which is called after Delete
+func (repo *HookedRepo) AfterDelete(hook AfterDeleteHook) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.afterDelete = append(repo.afterDelete, hook)
+}
+
+// AfterFind registers a hook which is called for each item read by Find and FindAll
+func (repo *HookedRepo) AfterFind(hook AfterFindHook) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.afterFind = append(repo.afterFind, hook)
+}
+
+// FindAll calls function of wrapped repository and runs the AfterFind hooks for each item
+func (repo *HookedRepo) FindAll() ([]KeyValuePair, error) {
+	items, err := repo.wrappedRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if err := repo.runAfterFind(&items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Find calls function of wrapped repository and runs the AfterFind hooks
+func (repo *HookedRepo) Find(key string) (KeyValuePair, error) {
+	item, err := repo.wrappedRepo.Find(key)
+	if err != nil {
+		return item, err
+	}
+	if err := repo.runAfterFind(&item); err != nil {
+		return KeyValuePair{}, err
+	}
+	return item, nil
+}
+
+// Save runs the BeforeSave hooks, calls function of wrapped repository and runs the AfterSave hooks
+func (repo *HookedRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	return repo.save(OperationSave, key, in, repo.wrappedRepo.Save)
+}
+
+// Overwrite runs the BeforeSave hooks, calls function of wrapped repository and runs the AfterSave hooks
+func (repo *HookedRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	return repo.save(OperationOverwrite, key, in, repo.wrappedRepo.Overwrite)
+}
+
+// Delete runs the BeforeDelete hooks, calls function of wrapped repository and runs the AfterDelete hooks
+func (repo *HookedRepo) Delete(key string) error {
+	repo.mutex.RLock()
+	beforeDelete, afterDelete := repo.beforeDelete, repo.afterDelete
+	repo.mutex.RUnlock()
+
+	for i, hook := range beforeDelete {
+		if err := hook(key); err != nil {
+			return fmt.Errorf("before delete hook %d rejected key %s: %w", i, key, err)
+		}
+	}
+	if err := repo.wrappedRepo.Delete(key); err != nil {
+		return err
+	}
+	for i, hook := range afterDelete {
+		if err := hook(key); err != nil {
+			return fmt.Errorf("after delete hook %d failed for key %s: %w", i, key, err)
+		}
+	}
+	return nil
+}
+
+func (repo *HookedRepo) save(operation Operation, key string, in interface{}, write func(string, interface{}) (KeyValuePair, error)) (KeyValuePair, error) {
+	repo.mutex.RLock()
+	beforeSave, afterSave := repo.beforeSave, repo.afterSave
+	repo.mutex.RUnlock()
+
+	event := &SaveEvent{
+		Operation: operation,
+		Key:       key,
+		Value:     in,
+	}
+	for i, hook := range beforeSave {
+		if err := hook(event); err != nil {
+			return KeyValuePair{}, fmt.Errorf("before save hook %d rejected key %s: %w", i, event.Key, err)
+		}
+	}
+	item, err := write(event.Key, event.Value)
+	if err != nil {
+		return item, err
+	}
+	for i, hook := range afterSave {
+		if err := hook(operation, item); err != nil {
+			return item, fmt.Errorf("after save hook %d failed for key %s: %w", i, item.Key, err)
+		}
+	}
+	return item, nil
+}
+
+func (repo *HookedRepo) runAfterFind(item *KeyValuePair) error {
+	repo.mutex.RLock()
+	afterFind := repo.afterFind
+	repo.mutex.RUnlock()
+
+	for i, hook := range afterFind {
+		if err := hook(item); err != nil {
+			return fmt.Errorf("after find hook %d failed for key %s: %w", i, item.Key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var errVeto = errors.New("veto")
+
+func TestHookedRepoSaveOrder(t *testing.T) {
+	repo := NewHookedRepo(NewInMemoryRepo())
+	calls := make([]string, 0)
+	repo.BeforeSave(func(event *SaveEvent) error {
+		calls = append(calls, "before1:"+string(event.Operation))
+		return nil
+	})
+	repo.BeforeSave(func(event *SaveEvent) error {
+		calls = append(calls, "before2")
+		return nil
+	})
+	repo.AfterSave(func(operation Operation, item KeyValuePair) error {
+		calls = append(calls, "after:"+item.Key)
+		return nil
+	})
+
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "value")
+	checkError(err, t)
+
+	expected := []string{"before1:Save", "before2", "after:key", "before1:Overwrite", "before2", "after:key"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected %v but found %v", expected, calls)
+	}
+}
+
+func TestHookedRepoSaveMutate(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewHookedRepo(wrapped)
+	repo.BeforeSave(func(event *SaveEvent) error {
+		event.Key = "prefix." + event.Key
+		event.Value = strings.ToUpper(event.Value.(string))
+		return nil
+	})
+
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+
+	item, err := wrapped.Find("prefix.key")
+	checkError(err, t)
+	if item.Value != "VALUE" {
+		t.Errorf("Expected mutated value but found %v", item.Value)
+	}
+}
+
+func TestHookedRepoSaveVeto(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewHookedRepo(wrapped)
+	called := false
+	repo.BeforeSave(func(event *SaveEvent) error {
+		return errVeto
+	})
+	repo.BeforeSave(func(event *SaveEvent) error {
+		called = true
+		return nil
+	})
+
+	_, err := repo.Save("key", "value")
+
+	if !errors.Is(err, errVeto) {
+		t.Errorf("Expected %v but found %v", errVeto, err)
+	}
+	if called {
+		t.Error("Expected chain to stop after the first error")
+	}
+	if _, err := wrapped.Find("key"); err == nil {
+		t.Error("Expected vetoed item not to be saved")
+	}
+}
+
+func TestHookedRepoAfterSaveError(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewHookedRepo(wrapped)
+	repo.AfterSave(func(operation Operation, item KeyValuePair) error {
+		return errVeto
+	})
+
+	_, err := repo.Save("key", "value")
+
+	if !errors.Is(err, errVeto) {
+		t.Errorf("Expected %v but found %v", errVeto, err)
+	}
+	if _, err := wrapped.Find("key"); err != nil {
+		t.Error("Expected item to be saved before the after hook")
+	}
+}
+
+func TestHookedRepoDelete(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewHookedRepo(wrapped)
+	_, err := wrapped.Save("protected", "value")
+	checkError(err, t)
+	_, err = wrapped.Save("key", "value")
+	checkError(err, t)
+	deleted := make([]string, 0)
+	repo.BeforeDelete(func(key string) error {
+		if key == "protected" {
+			return errVeto
+		}
+		return nil
+	})
+	repo.AfterDelete(func(key string) error {
+		deleted = append(deleted, key)
+		return nil
+	})
+
+	err = repo.Delete("protected")
+	if !errors.Is(err, errVeto) {
+		t.Errorf("Expected %v but found %v", errVeto, err)
+	}
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	if !reflect.DeepEqual(deleted, []string{"key"}) {
+		t.Errorf("Expected after hook to be called for key only but found %v", deleted)
+	}
+}
+
+func TestHookedRepoAfterDeleteNotCalledOnError(t *testing.T) {
+	repo := NewHookedRepo(NewInMemoryRepo())
+	called := false
+	repo.AfterDelete(func(key string) error {
+		called = true
+		return nil
+	})
+
+	err := repo.Delete("invalid")
+
+	checkFailure(err, t)
+	if called {
+		t.Error("Expected after hook not to be called for failed delete")
+	}
+}
+
+func TestHookedRepoAfterFind(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewHookedRepo(wrapped)
+	_, err := wrapped.Save("key", "secret")
+	checkError(err, t)
+	repo.AfterFind(func(item *KeyValuePair) error {
+		item.Value = "***"
+		return nil
+	})
+
+	item, err := repo.Find("key")
+	checkError(err, t)
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if item.Value != "***" || items[0].Value != "***" {
+		t.Errorf("Expected masked values but found %v and %v", item.Value, items[0].Value)
+	}
+}
+
+func TestHookedRepoAfterFindError(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewHookedRepo(wrapped)
+	_, err := wrapped.Save("key", "value")
+	checkError(err, t)
+	repo.AfterFind(func(item *KeyValuePair) error {
+		return errVeto
+	})
+
+	_, err = repo.Find("key")
+	checkFailure(err, t)
+	_, err = repo.FindAll()
+	checkFailure(err, t)
+}
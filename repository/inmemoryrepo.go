@@ -2,19 +2,27 @@ package repository
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 	"sync"
+	"time"
 )
 
 // InMemoryRepo stores all entities in-memory
 type InMemoryRepo struct {
-	mapStore map[string]interface{}
+	mapStore map[string]*inMemoryItem
 	mutex    sync.RWMutex
 }
 
+type inMemoryItem struct {
+	value    interface{}
+	metadata Metadata
+}
+
 // NewInMemoryRepo creates a new instance of the repository
 func NewInMemoryRepo() *InMemoryRepo {
 	return &InMemoryRepo{
-		mapStore: make(map[string]interface{}),
+		mapStore: make(map[string]*inMemoryItem),
 	}
 }
 
@@ -28,21 +36,36 @@ func (repo *InMemoryRepo) Overwrite(key string, in interface{}) (KeyValuePair, e
 }
 
 func (repo *InMemoryRepo) save(key string, in interface{}, overwrite bool) (KeyValuePair, error) {
-	repo.mutex.RLock()
-	defer repo.mutex.RUnlock()
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
 
 	result := KeyValuePair{}
 	if !overwrite {
 		_, ok := repo.mapStore[key]
 		if ok {
-			return result, fmt.Errorf("key %s already exists", key)
+			return result, fmt.Errorf("key %s %w", key, ErrAlreadyExists)
 		}
 	}
-	repo.mapStore[key] = in
-	result.Key = key
-	result.Value = in
+	return repo.store(key, in), nil
+}
 
-	return result, nil
+// store sets the value of a key and updates its metadata
+func (repo *InMemoryRepo) store(key string, in interface{}) KeyValuePair {
+	now := time.Now()
+	item, ok := repo.mapStore[key]
+	if !ok {
+		item = &inMemoryItem{
+			metadata: Metadata{
+				CreatedAt: now,
+			},
+		}
+		repo.mapStore[key] = item
+	}
+	item.value = in
+	item.metadata.UpdatedAt = now
+	item.metadata.Version++
+
+	return item.toKeyValuePair(key)
 }
 
 // FindAll items
@@ -52,11 +75,8 @@ func (repo *InMemoryRepo) FindAll() ([]KeyValuePair, error) {
 
 	result := make([]KeyValuePair, 0, len(repo.mapStore))
 
-	for key, value := range repo.mapStore {
-		result = append(result, KeyValuePair{
-			Key:   key,
-			Value: value,
-		})
+	for key, item := range repo.mapStore {
+		result = append(result, item.toKeyValuePair(key))
 	}
 
 	return result, nil
@@ -64,12 +84,12 @@ func (repo *InMemoryRepo) FindAll() ([]KeyValuePair, error) {
 
 // Delete an item from the repository
 func (repo *InMemoryRepo) Delete(key string) error {
-	repo.mutex.RLock()
-	defer repo.mutex.RUnlock()
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
 
 	_, ok := repo.mapStore[key]
 	if !ok {
-		return fmt.Errorf("key %s not found", key)
+		return fmt.Errorf("key %s %w", key, ErrNotFound)
 	}
 	delete(repo.mapStore, key)
 	return nil
@@ -77,14 +97,128 @@ func (repo *InMemoryRepo) Delete(key string) error {
 
 // Find retrieves and item from the repository
 func (repo *InMemoryRepo) Find(key string) (KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
 	result := KeyValuePair{
 		Key:   key,
 		Value: nil,
 	}
-	value, ok := repo.mapStore[key]
+	item, ok := repo.mapStore[key]
 	if !ok {
-		return result, fmt.Errorf("key %s not found", key)
+		return result, fmt.Errorf("key %s %w", key, ErrNotFound)
+	}
+	return item.toKeyValuePair(key), nil
+}
+
+// Tag adds tags to an existing item
+func (repo *InMemoryRepo) Tag(key string, tags map[string]string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	item, ok := repo.mapStore[key]
+	if !ok {
+		return fmt.Errorf("key %s %w", key, ErrNotFound)
+	}
+	if item.metadata.Tags == nil {
+		item.metadata.Tags = make(map[string]string, len(tags))
+	}
+	for name, value := range tags {
+		item.metadata.Tags[name] = value
+	}
+	return nil
+}
+
+// CompareAndSwap overwrites an item if the stored value is deeply equal to the expected value
+func (repo *InMemoryRepo) CompareAndSwap(key string, expected interface{}, in interface{}) (KeyValuePair, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	item, ok := repo.mapStore[key]
+	if !ok || !reflect.DeepEqual(item.value, expected) {
+		return KeyValuePair{}, ErrConditionFailed
+	}
+	return repo.store(key, in), nil
+}
+
+// CompareAndDelete deletes an item if the stored value is deeply equal to the expected value
+func (repo *InMemoryRepo) CompareAndDelete(key string, expected interface{}) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	item, ok := repo.mapStore[key]
+	if !ok || !reflect.DeepEqual(item.value, expected) {
+		return ErrConditionFailed
+	}
+	delete(repo.mapStore, key)
+	return nil
+}
+
+// Patch applies a JSON merge patch to the value of an existing key. The patched value
+// keeps the type of the stored value.
+func (repo *InMemoryRepo) Patch(key string, mergePatch []byte) (KeyValuePair, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	item, ok := repo.mapStore[key]
+	if !ok {
+		return KeyValuePair{}, fmt.Errorf("key %s %w", key, ErrNotFound)
+	}
+	patched, err := ApplyMergePatch(item.value, mergePatch)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.store(key, patched), nil
+}
+
+// Increment adds delta to the numeric value of a key. Missing keys are created
+// with delta as initial value.
+func (repo *InMemoryRepo) Increment(key string, delta int64) (int64, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	current := int64(0)
+	if item, ok := repo.mapStore[key]; ok {
+		number, err := toInt64(item.value)
+		if err != nil {
+			return 0, fmt.Errorf("value of key %s is not numeric: %v", key, err)
+		}
+		current = number
+	}
+	current += delta
+	repo.store(key, current)
+	return current, nil
+}
+
+// toKeyValuePair copies an item to not expose the internal metadata
+func (item *inMemoryItem) toKeyValuePair(key string) KeyValuePair {
+	metadata := item.metadata
+	if item.metadata.Tags != nil {
+		metadata.Tags = make(map[string]string, len(item.metadata.Tags))
+		for name, value := range item.metadata.Tags {
+			metadata.Tags[name] = value
+		}
+	}
+	return KeyValuePair{
+		Key:      key,
+		Value:    item.value,
+		Metadata: &metadata,
+	}
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch number := value.(type) {
+	case int:
+		return int64(number), nil
+	case int32:
+		return int64(number), nil
+	case int64:
+		return number, nil
+	case float64:
+		return int64(number), nil
+	case string:
+		return strconv.ParseInt(number, 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
 	}
-	result.Value = value
-	return result, nil
 }
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -139,6 +141,172 @@ func TestInMemoryRepoFindInvalid(t *testing.T) {
 	}
 }
 
+func TestInMemoryRepoIncrementMissingKey(t *testing.T) {
+	repo := NewInMemoryRepo()
+
+	value, err := repo.Increment("counter", 3)
+	checkError(err, t)
+
+	if value != 3 {
+		t.Errorf("Expected 3 but retrieved %d", value)
+	}
+}
+
+func TestInMemoryRepoIncrementExistingKey(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("counter", "5")
+	checkError(err, t)
+
+	value, err := repo.Increment("counter", -2)
+	checkError(err, t)
+
+	if value != 3 {
+		t.Errorf("Expected 3 but retrieved %d", value)
+	}
+}
+
+func TestInMemoryRepoIncrementNonNumeric(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("counter", mockInstance)
+	checkError(err, t)
+
+	_, err = repo.Increment("counter", 1)
+
+	if err == nil {
+		t.Error("Expected error when incrementing a non numeric value")
+	}
+}
+
+func TestInMemoryRepoIncrementConcurrent(t *testing.T) {
+	repo := NewInMemoryRepo()
+	routines := 50
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < routines; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_, err := repo.Increment("counter", 1)
+			checkError(err, t)
+		}()
+	}
+	waitGroup.Wait()
+
+	item, err := repo.Find("counter")
+	checkError(err, t)
+	if item.Value != int64(routines) {
+		t.Errorf("Expected %d but retrieved %v", routines, item.Value)
+	}
+}
+
+func TestInMemoryRepoCompareAndSwap(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+
+	_, err = repo.CompareAndSwap("key", "a", "b")
+	checkError(err, t)
+
+	item, _ := repo.Find("key")
+	if item.Value != "b" {
+		t.Errorf("Expected b but retrieved %v", item.Value)
+	}
+}
+
+func TestInMemoryRepoCompareAndSwapMismatch(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+
+	_, err = repo.CompareAndSwap("key", "other", "b")
+
+	if err != ErrConditionFailed {
+		t.Errorf("Expected %v but retrieved %v", ErrConditionFailed, err)
+	}
+	item, _ := repo.Find("key")
+	if item.Value != "a" {
+		t.Errorf("Expected a but retrieved %v", item.Value)
+	}
+}
+
+func TestInMemoryRepoCompareAndSwapMissing(t *testing.T) {
+	repo := NewInMemoryRepo()
+
+	_, err := repo.CompareAndSwap("key", nil, "b")
+
+	if err != ErrConditionFailed {
+		t.Errorf("Expected %v but retrieved %v", ErrConditionFailed, err)
+	}
+}
+
+func TestInMemoryRepoCompareAndDelete(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+
+	err = repo.CompareAndDelete("key", "other")
+	if err != ErrConditionFailed {
+		t.Errorf("Expected %v but retrieved %v", ErrConditionFailed, err)
+	}
+	err = repo.CompareAndDelete("key", "a")
+	checkError(err, t)
+
+	_, err = repo.Find("key")
+	checkFailure(err, t)
+}
+
+func TestInMemoryRepoMetadata(t *testing.T) {
+	repo := NewInMemoryRepo()
+
+	saved, err := repo.Save("key", "a")
+	checkError(err, t)
+	overwritten, err := repo.Overwrite("key", "b")
+	checkError(err, t)
+
+	if saved.Metadata.Version != 1 || overwritten.Metadata.Version != 2 {
+		t.Errorf("Expected versions 1 and 2 but found %d and %d", saved.Metadata.Version, overwritten.Metadata.Version)
+	}
+	if !overwritten.Metadata.CreatedAt.Equal(saved.Metadata.CreatedAt) {
+		t.Errorf("Expected creation date %v but found %v", saved.Metadata.CreatedAt, overwritten.Metadata.CreatedAt)
+	}
+	if overwritten.Metadata.UpdatedAt.Before(saved.Metadata.UpdatedAt) {
+		t.Errorf("Expected update date %v to be after %v", overwritten.Metadata.UpdatedAt, saved.Metadata.UpdatedAt)
+	}
+	found, _ := repo.Find("key")
+	if !reflect.DeepEqual(found.Metadata, overwritten.Metadata) {
+		t.Errorf("Expected %+v but found %+v", overwritten.Metadata, found.Metadata)
+	}
+}
+
+func TestInMemoryRepoTag(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", "a")
+	checkError(err, t)
+
+	err = repo.Tag("key", map[string]string{"owner": "me"})
+	checkError(err, t)
+	_, err = repo.Overwrite("key", "b")
+	checkError(err, t)
+
+	items, _ := repo.FindAll()
+	if items[0].Metadata.Tags["owner"] != "me" {
+		t.Errorf("Expected tag owner to be kept but found %+v", items[0].Metadata.Tags)
+	}
+	items[0].Metadata.Tags["owner"] = "modified"
+	found, _ := repo.Find("key")
+	if found.Metadata.Tags["owner"] != "me" {
+		t.Errorf("Expected stored tags to be unmodified but found %+v", found.Metadata.Tags)
+	}
+}
+
+func TestInMemoryRepoTagMissing(t *testing.T) {
+	repo := NewInMemoryRepo()
+
+	err := repo.Tag("invalid", map[string]string{"owner": "me"})
+
+	checkFailure(err, t)
+}
+
 func checkError(err error, t *testing.T) {
 	if err != nil {
 		t.Error(err)
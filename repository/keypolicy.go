@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidKey is returned if a key can not be stored by a repository. The returned errors
+// wrap ErrInvalidKey and contain the reason.
+var ErrInvalidKey = errors.New("invalid key")
+
+// InvalidKeyError creates an error which wraps ErrInvalidKey
+func InvalidKeyError(key string, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidKey, key, reason)
+}
+
+// KeyPolicy validates keys and escapes characters which are not supported by a backend
+type KeyPolicy interface {
+	// converts a key into the form stored by the backend, returns an error wrapping
+	// ErrInvalidKey if the key can not be stored
+	Escape(key string) (string, error)
+	// converts a stored key back into the original key
+	Unescape(stored string) (string, error)
+}
+
+// EscapingKeyPolicy escapes all bytes of a key which are not allowed by a backend as the escape
+// character followed by two uppercase hex digits, e.g. "a/b" becomes "a_2Fb". The escape
+// character is only escaped itself if it is followed by two uppercase hex digits, so keys which
+// only contain allowed characters are mostly stored as is.
+type EscapingKeyPolicy struct {
+	// returns true for bytes which are stored unescaped. The escape character and
+	// the uppercase hex digits have to be allowed.
+	Allowed    func(b byte) bool
+	EscapeChar byte
+	// maximum length of escaped keys in bytes, 0 for no limit
+	MaxLength int
+}
+
+// Escape converts a key into the form stored by the backend
+func (policy EscapingKeyPolicy) Escape(key string) (string, error) {
+	if key == "" {
+		return "", InvalidKeyError(key, "key must not be empty")
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(key); i++ {
+		b := key[i]
+		if policy.Allowed(b) && (b != policy.EscapeChar || !isEscapeSequence(key[i+1:])) {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%c%02X", policy.EscapeChar, b)
+		}
+	}
+
+	escaped := builder.String()
+	if policy.MaxLength > 0 && len(escaped) > policy.MaxLength {
+		return "", InvalidKeyError(key, fmt.Sprintf("escaped key has %d bytes but at most %d are allowed", len(escaped), policy.MaxLength))
+	}
+	return escaped, nil
+}
+
+// Unescape converts a stored key back into the original key
+func (policy EscapingKeyPolicy) Unescape(stored string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(stored); i++ {
+		if stored[i] == policy.EscapeChar && isEscapeSequence(stored[i+1:]) {
+			builder.WriteByte(hexValue(stored[i+1])<<4 | hexValue(stored[i+2]))
+			i += 2
+		} else {
+			builder.WriteByte(stored[i])
+		}
+	}
+	return builder.String(), nil
+}
+
+// isEscapeSequence returns true if the text starts with two uppercase hex digits
+func isEscapeSequence(text string) bool {
+	return len(text) >= 2 && isUpperHex(text[0]) && isUpperHex(text[1])
+}
+
+func isUpperHex(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'A' && b <= 'F')
+}
+
+func hexValue(b byte) byte {
+	if b >= 'A' {
+		return b - 'A' + 10
+	}
+	return b - '0'
+}
+
+// LengthKeyPolicy stores keys as is and only validates their length and encoding
+type LengthKeyPolicy struct {
+	// maximum length of keys in bytes, 0 for no limit
+	MaxLength int
+}
+
+// Escape validates the key
+func (policy LengthKeyPolicy) Escape(key string) (string, error) {
+	if key == "" {
+		return "", InvalidKeyError(key, "key must not be empty")
+	}
+	if !utf8.ValidString(key) {
+		return "", InvalidKeyError(key, "key is not valid UTF-8")
+	}
+	if policy.MaxLength > 0 && len(key) > policy.MaxLength {
+		return "", InvalidKeyError(key, fmt.Sprintf("key has %d bytes but at most %d are allowed", len(key), policy.MaxLength))
+	}
+	return key, nil
+}
+
+// Unescape returns the stored key
+func (policy LengthKeyPolicy) Unescape(stored string) (string, error) {
+	return stored, nil
+}
+
+// KeyPolicyRepo applies a KeyPolicy to all keys of the wrapped repository. Invalid keys are
+// rejected before the backend is called and keys of returned items are unescaped.
+// Patch, ConditionalRepo, CounterRepo and Tagger are delegated to the wrapped repository,
+// an error wrapping ErrNotSupported is returned if it does not implement them.
+type KeyPolicyRepo struct {
+	wrappedRepo KeyValueRepo
+	policy      KeyPolicy
+}
+
+// NewKeyPolicyRepo creates a KeyPolicyRepo
+func NewKeyPolicyRepo(repo KeyValueRepo, policy KeyPolicy) *KeyPolicyRepo {
+	return &KeyPolicyRepo{
+		wrappedRepo: repo,
+		policy:      policy,
+	}
+}
+
+// FindAll calls function of wrapped repository and unescapes the keys
+func (repo *KeyPolicyRepo) FindAll() ([]KeyValuePair, error) {
+	items, err := repo.wrappedRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i], err = repo.unescape(items[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Find calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) Find(key string) (KeyValuePair, error) {
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.unescape(repo.wrappedRepo.Find(escaped))
+}
+
+// Save calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.unescape(repo.wrappedRepo.Save(escaped, in))
+}
+
+// Overwrite calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.unescape(repo.wrappedRepo.Overwrite(escaped, in))
+}
+
+// Delete calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) Delete(key string) error {
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return err
+	}
+	return repo.wrappedRepo.Delete(escaped)
+}
+
+// Patch applies a merge patch with the escaped key, see Patch
+func (repo *KeyPolicyRepo) Patch(key string, mergePatch []byte) (KeyValuePair, error) {
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.unescape(Patch(repo.wrappedRepo, escaped, mergePatch))
+}
+
+// CompareAndSwap calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) CompareAndSwap(key string, expected interface{}, in interface{}) (KeyValuePair, error) {
+	conditional, ok := repo.wrappedRepo.(ConditionalRepo)
+	if !ok {
+		return KeyValuePair{}, repo.notSupported("conditional writes")
+	}
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.unescape(conditional.CompareAndSwap(escaped, expected, in))
+}
+
+// CompareAndDelete calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) CompareAndDelete(key string, expected interface{}) error {
+	conditional, ok := repo.wrappedRepo.(ConditionalRepo)
+	if !ok {
+		return repo.notSupported("conditional writes")
+	}
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return err
+	}
+	return conditional.CompareAndDelete(escaped, expected)
+}
+
+// Increment calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) Increment(key string, delta int64) (int64, error) {
+	counter, ok := repo.wrappedRepo.(CounterRepo)
+	if !ok {
+		return 0, repo.notSupported("counters")
+	}
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Increment(escaped, delta)
+}
+
+// Tag calls function of wrapped repository with the escaped key
+func (repo *KeyPolicyRepo) Tag(key string, tags map[string]string) error {
+	tagger, ok := repo.wrappedRepo.(Tagger)
+	if !ok {
+		return repo.notSupported("tags")
+	}
+	escaped, err := repo.policy.Escape(key)
+	if err != nil {
+		return err
+	}
+	return tagger.Tag(escaped, tags)
+}
+
+func (repo *KeyPolicyRepo) notSupported(feature string) error {
+	return fmt.Errorf("repository %T does not support %s: %w", repo.wrappedRepo, feature, ErrNotSupported)
+}
+
+// unescape converts the key of an item returned by the wrapped repository
+func (repo *KeyPolicyRepo) unescape(item KeyValuePair, err error) (KeyValuePair, error) {
+	if err != nil {
+		return item, err
+	}
+	item.Key, err = repo.policy.Unescape(item.Key)
+	return item, err
+}
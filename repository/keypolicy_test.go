@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"testing/quick"
+)
+
+var testEscapingPolicy = EscapingKeyPolicy{
+	Allowed: func(b byte) bool {
+		return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_'
+	},
+	EscapeChar: '_',
+}
+
+func TestEscapingKeyPolicyRoundTrip(t *testing.T) {
+	roundTrip := func(key string) bool {
+		escaped, err := testEscapingPolicy.Escape(key)
+		if key == "" {
+			return errors.Is(err, ErrInvalidKey)
+		}
+		if err != nil {
+			return false
+		}
+		for i := 0; i < len(escaped); i++ {
+			if !testEscapingPolicy.Allowed(escaped[i]) {
+				return false
+			}
+		}
+		unescaped, err := testEscapingPolicy.Unescape(escaped)
+		return err == nil && unescaped == key
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEscapingKeyPolicyRoundTripBytes(t *testing.T) {
+	// arbitrary bytes include invalid UTF-8 and many escape characters
+	roundTrip := func(key []byte) bool {
+		if len(key) == 0 {
+			return true
+		}
+		escaped, err := testEscapingPolicy.Escape(string(key))
+		if err != nil {
+			return false
+		}
+		unescaped, err := testEscapingPolicy.Unescape(escaped)
+		return err == nil && unescaped == string(key)
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEscapingKeyPolicyEscape(t *testing.T) {
+	tests := map[string]string{
+		"plain":     "plain",
+		"MAIL_HOST": "MAIL_HOST",
+		"a/b":       "a_2Fb",
+		"a_2F":      "a_5F2F",
+		"a_":        "a_",
+		"ü":         "_C3_BC",
+	}
+
+	for key, expected := range tests {
+		escaped, err := testEscapingPolicy.Escape(key)
+		checkError(err, t)
+		if escaped != expected {
+			t.Errorf("Expected %s to be escaped as %s but found %s", key, expected, escaped)
+		}
+	}
+}
+
+func TestEscapingKeyPolicyMaxLength(t *testing.T) {
+	policy := testEscapingPolicy
+	policy.MaxLength = 4
+
+	_, err := policy.Escape("a/b")
+
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected %v but found %v", ErrInvalidKey, err)
+	}
+}
+
+func TestLengthKeyPolicy(t *testing.T) {
+	policy := LengthKeyPolicy{MaxLength: 3}
+
+	for _, invalid := range []string{"", "abcd", string([]byte{0xff})} {
+		if _, err := policy.Escape(invalid); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected %v for %q but found %v", ErrInvalidKey, invalid, err)
+		}
+	}
+	escaped, err := policy.Escape("abc")
+	checkError(err, t)
+	if escaped != "abc" {
+		t.Errorf("Expected key to be stored as is but found %s", escaped)
+	}
+}
+
+func TestKeyPolicyRepo(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewKeyPolicyRepo(wrapped, testEscapingPolicy)
+
+	saved, err := repo.Save("a/b", "value")
+	checkError(err, t)
+	found, err := repo.Find("a/b")
+	checkError(err, t)
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if saved.Key != "a/b" || found.Key != "a/b" || items[0].Key != "a/b" {
+		t.Errorf("Expected unescaped keys but found %s, %s and %s", saved.Key, found.Key, items[0].Key)
+	}
+	if _, err := wrapped.Find("a_2Fb"); err != nil {
+		t.Errorf("Expected escaped key in wrapped repository: %v", err)
+	}
+	checkError(repo.Delete("a/b"), t)
+}
+
+func TestKeyPolicyRepoInvalidKey(t *testing.T) {
+	repo := NewKeyPolicyRepo(NewInMemoryRepo(), LengthKeyPolicy{MaxLength: 3})
+
+	_, err := repo.Save("invalid", "value")
+
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected %v but found %v", ErrInvalidKey, err)
+	}
+}
+
+func TestKeyPolicyRepoOptionalInterfaces(t *testing.T) {
+	wrapped := NewInMemoryRepo()
+	repo := NewKeyPolicyRepo(wrapped, testEscapingPolicy)
+	_, err := repo.Save("a/b", map[string]interface{}{"name": "a"})
+	checkError(err, t)
+
+	patched, err := repo.Patch("a/b", []byte(`{"name":"b"}`))
+	checkError(err, t)
+	_, err = repo.CompareAndSwap("a/b", map[string]interface{}{"name": "b"}, "c")
+	checkError(err, t)
+	checkError(repo.Tag("a/b", map[string]string{"owner": "me"}), t)
+	checkError(repo.CompareAndDelete("a/b", "c"), t)
+	count, err := repo.Increment("c/d", 2)
+	checkError(err, t)
+
+	if patched.Key != "a/b" || patched.Value.(map[string]interface{})["name"] != "b" {
+		t.Errorf("Expected patched item with unescaped key but found %+v", patched)
+	}
+	if _, err := wrapped.Find("c_2Fd"); err != nil || count != 2 {
+		t.Errorf("Expected counter 2 with escaped key but found %d: %v", count, err)
+	}
+}
+
+func TestKeyPolicyRepoNotSupported(t *testing.T) {
+	repo := NewKeyPolicyRepo(struct{ KeyValueRepo }{NewInMemoryRepo()}, testEscapingPolicy)
+
+	_, err := repo.Increment("key", 1)
+
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected %v but found %v", ErrNotSupported, err)
+	}
+}
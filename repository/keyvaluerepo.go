@@ -1,11 +1,29 @@
 package repository
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
 
 // KeyValuePair has the stored entity in addition to an autogenerated id
 type KeyValuePair struct {
 	Key   string      `json:"key"`
 	Value interface{} `json:"value"`
+	// optional information about the stored item, nil if not supported by the repository
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// Metadata is maintained by repositories for each stored item.
+// Fields which are not supported by a repository keep their zero value.
+type Metadata struct {
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// starts with 1 and is increased with each write
+	Version int64             `json:"version"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	// name of the layer the item was read from, only set by the LayeredRepo
+	Source string `json:"source,omitempty"`
 }
 
 // KeyValueRepo is a generic repository which accepts values of type interface
@@ -19,6 +37,46 @@ type KeyValueRepo interface {
 	Find(key string) (KeyValuePair, error)
 }
 
+// ErrNotFound is returned if no item is stored for a key. Repositories may wrap it with
+// further details, so it has to be checked with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is returned by Save if an item is already stored for the key. Repositories
+// may wrap it with further details, so it has to be checked with errors.Is.
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrNotSupported is returned by decorators implementing an optional interface, e.g.
+// ConditionalRepo, if the wrapped repository does not support it
+var ErrNotSupported = errors.New("operation not supported")
+
+// ErrConditionFailed is returned if a conditional write was rejected because the stored
+// value did not match the expected value
+var ErrConditionFailed = errors.New("condition failed")
+
+// ConditionalRepo is implemented by repositories which support conditional writes
+type ConditionalRepo interface {
+	// overwrites an item only if the stored value equals the expected value,
+	// otherwise ErrConditionFailed is returned
+	CompareAndSwap(key string, expected interface{}, in interface{}) (KeyValuePair, error)
+	// deletes an item only if the stored value equals the expected value,
+	// otherwise ErrConditionFailed is returned
+	CompareAndDelete(key string, expected interface{}) error
+}
+
+// CounterRepo is implemented by repositories which are able to atomically
+// increment numeric values
+type CounterRepo interface {
+	// adds delta to the value stored for the key and returns the new value.
+	// If the key does not exist, it is created with delta as initial value.
+	Increment(key string, delta int64) (int64, error)
+}
+
+// Tagger is implemented by repositories which are able to tag items
+type Tagger interface {
+	// adds tags to an existing item, tags with the same name are overwritten
+	Tag(key string, tags map[string]string) error
+}
+
 // ToStruct converts json string to struct
 func (item KeyValuePair) ToStruct(jsonString string) (interface{}, error) {
 	err := json.Unmarshal([]byte(jsonString), &item)
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Layer is a named repository of a LayeredRepo
+type Layer struct {
+	Name string
+	Repo KeyValueRepo
+}
+
+// LayeredRepo reads items from multiple layers, e.g. local overrides, SSM and built-in defaults.
+// Reads check the layers in order and return the first item found. Writes are sent to a single
+// layer. Returned items report the name of their layer as Source in the metadata.
+//
+// Deleting an item only removes it from the write layer, so an item with the same key in
+// another layer becomes visible afterwards.
+type LayeredRepo struct {
+	layers     []Layer
+	writeLayer KeyValueRepo
+}
+
+// NewLayeredRepo creates a LayeredRepo. The layers are ordered from highest to lowest priority.
+// The write layer is the name of the layer which receives all writes.
+func NewLayeredRepo(writeLayer string, layers ...Layer) (*LayeredRepo, error) {
+	for _, layer := range layers {
+		if layer.Name == writeLayer {
+			return &LayeredRepo{
+				layers:     layers,
+				writeLayer: layer.Repo,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("write layer %s not found", writeLayer)
+}
+
+// FindAll merges the items of all layers. Items of higher layers replace items with the same
+// key of lower layers.
+func (repo *LayeredRepo) FindAll() ([]KeyValuePair, error) {
+	found := make(map[string]bool)
+	result := make([]KeyValuePair, 0)
+	for _, layer := range repo.layers {
+		items, err := layer.Repo.FindAll()
+		if err != nil {
+			return nil, fmt.Errorf("could not read layer %s: %v", layer.Name, err)
+		}
+		for _, item := range items {
+			if found[item.Key] {
+				continue
+			}
+			found[item.Key] = true
+			result = append(result, withSource(item, layer.Name))
+		}
+	}
+	return result, nil
+}
+
+// Find returns the item of the highest layer which contains the key. Lower layers are only
+// checked if a layer reports ErrNotFound, other errors are returned.
+func (repo *LayeredRepo) Find(key string) (KeyValuePair, error) {
+	for _, layer := range repo.layers {
+		item, err := layer.Repo.Find(key)
+		if err == nil {
+			return withSource(item, layer.Name), nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return KeyValuePair{}, fmt.Errorf("could not read layer %s: %w", layer.Name, err)
+		}
+	}
+	return KeyValuePair{}, fmt.Errorf("key %s %w in any layer", key, ErrNotFound)
+}
+
+// Save calls function of write layer
+func (repo *LayeredRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	return repo.writeLayer.Save(key, in)
+}
+
+// Overwrite calls function of write layer
+func (repo *LayeredRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	return repo.writeLayer.Overwrite(key, in)
+}
+
+// Delete calls function of write layer
+func (repo *LayeredRepo) Delete(key string) error {
+	return repo.writeLayer.Delete(key)
+}
+
+// withSource sets the layer name in a copy of the metadata of an item
+func withSource(item KeyValuePair, source string) KeyValuePair {
+	metadata := Metadata{}
+	if item.Metadata != nil {
+		metadata = *item.Metadata
+	}
+	metadata.Source = source
+	item.Metadata = &metadata
+	return item
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+func createLayeredRepo(t *testing.T) (*LayeredRepo, *InMemoryRepo, *InMemoryRepo) {
+	overrides, defaults := NewInMemoryRepo(), NewInMemoryRepo()
+	_, err := defaults.Save("both", "default")
+	checkError(err, t)
+	_, err = defaults.Save("default", "default")
+	checkError(err, t)
+	_, err = overrides.Save("both", "override")
+	checkError(err, t)
+	repo, err := NewLayeredRepo("overrides", Layer{Name: "overrides", Repo: overrides}, Layer{Name: "defaults", Repo: defaults})
+	checkError(err, t)
+	return repo, overrides, defaults
+}
+
+func TestLayeredRepoInvalidWriteLayer(t *testing.T) {
+	_, err := NewLayeredRepo("invalid", Layer{Name: "layer", Repo: NewInMemoryRepo()})
+
+	checkFailure(err, t)
+}
+
+func TestLayeredRepoFind(t *testing.T) {
+	repo, _, _ := createLayeredRepo(t)
+
+	both, err := repo.Find("both")
+	checkError(err, t)
+	fallback, err := repo.Find("default")
+	checkError(err, t)
+
+	if both.Value != "override" || both.Metadata.Source != "overrides" {
+		t.Errorf("Expected override but found %+v", both)
+	}
+	if fallback.Value != "default" || fallback.Metadata.Source != "defaults" {
+		t.Errorf("Expected default but found %+v", fallback)
+	}
+}
+
+func TestLayeredRepoFindMissing(t *testing.T) {
+	repo, _, _ := createLayeredRepo(t)
+
+	_, err := repo.Find("invalid")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound but found %v", err)
+	}
+}
+
+func TestLayeredRepoFindFailingLayer(t *testing.T) {
+	overrides, defaults := &failingRepo{InMemoryRepo: NewInMemoryRepo()}, NewInMemoryRepo()
+	_, err := defaults.Save("key", "default")
+	checkError(err, t)
+	repo, err := NewLayeredRepo("overrides", Layer{Name: "overrides", Repo: overrides}, Layer{Name: "defaults", Repo: defaults})
+	checkError(err, t)
+	overrides.fail(1)
+
+	_, err = repo.Find("key")
+
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the error of the failing layer but found %v", err)
+	}
+}
+
+func TestLayeredRepoFindAll(t *testing.T) {
+	repo, _, _ := createLayeredRepo(t)
+
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items but found %d", len(items))
+	}
+	for _, item := range items {
+		if item.Key == "both" && (item.Value != "override" || item.Metadata.Source != "overrides") {
+			t.Errorf("Expected upper layer to win but found %+v", item)
+		}
+		if item.Key == "default" && item.Metadata.Source != "defaults" {
+			t.Errorf("Expected defaults as source but found %+v", item)
+		}
+	}
+}
+
+func TestLayeredRepoWrite(t *testing.T) {
+	repo, overrides, defaults := createLayeredRepo(t)
+
+	_, err := repo.Overwrite("default", "override")
+	checkError(err, t)
+
+	if item, _ := overrides.Find("default"); item.Value != "override" {
+		t.Errorf("Expected write to write layer but found %+v", item)
+	}
+	if item, _ := defaults.Find("default"); item.Value != "default" {
+		t.Errorf("Expected lower layer to stay unchanged but found %+v", item)
+	}
+}
+
+func TestLayeredRepoDelete(t *testing.T) {
+	repo, _, _ := createLayeredRepo(t)
+
+	err := repo.Delete("both")
+	checkError(err, t)
+
+	item, err := repo.Find("both")
+	checkError(err, t)
+	if item.Value != "default" {
+		t.Errorf("Expected lower layer to become visible but found %+v", item)
+	}
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned if no capacity is available before the deadline of the context
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// Limiter limits the rate of operations
+type Limiter interface {
+	// blocks until the operation is allowed. If the context has a deadline which is reached
+	// before, ErrRateLimited is returned immediately.
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket is a local Limiter which allows bursts of operations. Tokens are refilled with
+// a constant rate up to the burst size and each operation consumes one token.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+	now    func() time.Time
+}
+
+// NewTokenBucket creates a full TokenBucket which allows rate operations per second.
+// The rate and the burst size have to be positive.
+func NewTokenBucket(rate float64, burst int) (*TokenBucket, error) {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("rate %v has to be a positive number", rate)
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("burst %d has to be positive", burst)
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}, nil
+}
+
+// Wait consumes a token and blocks until it is available
+func (bucket *TokenBucket) Wait(ctx context.Context) error {
+	bucket.mutex.Lock()
+	now := bucket.now()
+	if !bucket.last.IsZero() {
+		bucket.tokens = math.Min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	}
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.mutex.Unlock()
+		return nil
+	}
+	delay := time.Duration((1 - bucket.tokens) / bucket.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		bucket.mutex.Unlock()
+		return ErrRateLimited
+	}
+	// reserve the token which becomes available after the delay
+	bucket.tokens--
+	bucket.mutex.Unlock()
+
+	if err := sleep(ctx, delay); err != nil {
+		bucket.mutex.Lock()
+		bucket.tokens++
+		bucket.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// DistributedLimiter coordinates a limit between multiple processes via a shared counter,
+// e.g. in DynamoDB. It allows a fixed number of operations per time window. Each window uses
+// its own counter, the counter of the previous window is deleted if the repository supports it.
+type DistributedLimiter struct {
+	counter CounterRepo
+	name    string
+	limit   int64
+	window  time.Duration
+	now     func() time.Time
+}
+
+// NewDistributedLimiter creates a DistributedLimiter. All processes sharing a limit have to use
+// the same name, limit and window.
+func NewDistributedLimiter(counter CounterRepo, name string, limit int64, window time.Duration) *DistributedLimiter {
+	return &DistributedLimiter{
+		counter: counter,
+		name:    name,
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+	}
+}
+
+// Wait counts the operation in the current window and blocks until the next window if the
+// limit of the current window is exceeded
+func (limiter *DistributedLimiter) Wait(ctx context.Context) error {
+	for {
+		start := limiter.now().Truncate(limiter.window)
+		count, err := limiter.counter.Increment(limiter.counterKey(start), 1)
+		if err != nil {
+			return err
+		}
+		if count == 1 {
+			limiter.deleteCounter(start.Add(-limiter.window))
+		}
+		if count <= limiter.limit {
+			return nil
+		}
+
+		next := start.Add(limiter.window)
+		if deadline, ok := ctx.Deadline(); ok && next.After(deadline) {
+			return ErrRateLimited
+		}
+		if err := sleep(ctx, next.Sub(limiter.now())); err != nil {
+			return err
+		}
+	}
+}
+
+func (limiter *DistributedLimiter) counterKey(start time.Time) string {
+	return fmt.Sprintf("%s.%d", limiter.name, start.Unix())
+}
+
+// deleteCounter removes the counter of a window which is no longer used
+func (limiter *DistributedLimiter) deleteCounter(start time.Time) {
+	if repo, ok := limiter.counter.(KeyValueRepo); ok {
+		// the counter may not exist if there were no operations
+		_ = repo.Delete(limiter.counterKey(start))
+	}
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+// Patcher is implemented by repositories which are able to apply partial updates
+// without the need to overwrite the whole value by the caller
+type Patcher interface {
+	// applies a JSON merge patch (RFC 7396) to the stored value of an existing key
+	Patch(key string, mergePatch []byte) (KeyValuePair, error)
+}
+
+// Patch applies a JSON merge patch (RFC 7396) to the value of an existing key.
+// If the repository implements Patcher the update is delegated to it. Otherwise the item
+// is read, patched and overwritten, which is not safe against concurrent writers.
+func Patch(repo KeyValueRepo, key string, mergePatch []byte) (KeyValuePair, error) {
+	if patcher, ok := repo.(Patcher); ok {
+		return patcher.Patch(key, mergePatch)
+	}
+
+	item, err := repo.Find(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	patched, err := ApplyMergePatch(item.Value, mergePatch)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.Overwrite(key, patched)
+}
+
+// ApplyMergePatch patches a value and returns the result with the same type as the input.
+// String values are treated as serialized json documents.
+func ApplyMergePatch(value interface{}, mergePatch []byte) (interface{}, error) {
+	document, err := serialization.ToJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := serialization.MergePatch([]byte(document), mergePatch)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAs(value, patched)
+}
+
+// decodeAs unmarshals json into a new instance of the type of template
+func decodeAs(template interface{}, data []byte) (interface{}, error) {
+	if template == nil {
+		var result interface{}
+		err := json.Unmarshal(data, &result)
+		return result, err
+	}
+	if _, ok := template.(string); ok {
+		return string(data), nil
+	}
+
+	templateType := reflect.TypeOf(template)
+	if templateType.Kind() == reflect.Ptr {
+		result := reflect.New(templateType.Elem())
+		err := json.Unmarshal(data, result.Interface())
+		return result.Interface(), err
+	}
+	result := reflect.New(templateType)
+	err := json.Unmarshal(data, result.Interface())
+	return result.Elem().Interface(), err
+}
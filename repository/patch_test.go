@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+type patchStruct struct {
+	Name  string
+	Count int
+}
+
+// keyValueRepoOnly hides optional interfaces of the wrapped repository
+type keyValueRepoOnly struct {
+	KeyValueRepo
+}
+
+func TestPatchStruct(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", patchStruct{Name: "a", Count: 1})
+	checkError(err, t)
+
+	item, err := Patch(repo, "key", []byte(`{"Count":2}`))
+	checkError(err, t)
+
+	expected := patchStruct{Name: "a", Count: 2}
+	if !reflect.DeepEqual(item.Value, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, item.Value)
+	}
+	stored, _ := repo.Find("key")
+	if !reflect.DeepEqual(stored.Value, expected) {
+		t.Errorf("Expected %+v to be stored but found %+v", expected, stored.Value)
+	}
+}
+
+func TestPatchPointer(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", &patchStruct{Name: "a", Count: 1})
+	checkError(err, t)
+
+	item, err := Patch(repo, "key", []byte(`{"Name":"b"}`))
+	checkError(err, t)
+
+	expected := &patchStruct{Name: "b", Count: 1}
+	if !reflect.DeepEqual(item.Value, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, item.Value)
+	}
+}
+
+func TestPatchString(t *testing.T) {
+	repo := NewInMemoryRepo()
+	_, err := repo.Save("key", `{"a":"b","c":"d"}`)
+	checkError(err, t)
+
+	item, err := Patch(repo, "key", []byte(`{"c":null}`))
+	checkError(err, t)
+
+	if item.Value != `{"a":"b"}` {
+		t.Errorf("Expected %s but found %v", `{"a":"b"}`, item.Value)
+	}
+}
+
+func TestPatchFallback(t *testing.T) {
+	inMemoryRepo := NewInMemoryRepo()
+	repo := keyValueRepoOnly{inMemoryRepo}
+	_, err := repo.Save("key", patchStruct{Name: "a", Count: 1})
+	checkError(err, t)
+
+	_, err = Patch(repo, "key", []byte(`{"Name":"b"}`))
+	checkError(err, t)
+
+	stored, _ := inMemoryRepo.Find("key")
+	expected := patchStruct{Name: "b", Count: 1}
+	if !reflect.DeepEqual(stored.Value, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, stored.Value)
+	}
+}
+
+func TestPatchMissingKey(t *testing.T) {
+	repo := NewInMemoryRepo()
+
+	_, err := Patch(repo, "invalid", []byte(`{"Name":"b"}`))
+
+	if err == nil {
+		t.Error("Expected error when patching a missing key")
+	}
+}
+
+func TestPatchFallbackMissingKey(t *testing.T) {
+	repo := keyValueRepoOnly{NewInMemoryRepo()}
+
+	_, err := Patch(repo, "invalid", []byte(`{"Name":"b"}`))
+
+	if err == nil {
+		t.Error("Expected error when patching a missing key")
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+)
+
+// Operation is a method of a KeyValueRepo
+type Operation string
+
+// Operations which can be limited
+const (
+	OperationFindAll   Operation = "FindAll"
+	OperationFind      Operation = "Find"
+	OperationSave      Operation = "Save"
+	OperationOverwrite Operation = "Overwrite"
+	OperationDelete    Operation = "Delete"
+)
+
+// RateLimitedRepo limits the rate of calls to the wrapped repository per operation, e.g. to stay
+// below the TPS limits of SSM. Operations without a limiter are not limited. Calls block until
+// the limiter allows them, use WithContext to fail fast with ErrRateLimited.
+type RateLimitedRepo struct {
+	wrappedRepo KeyValueRepo
+	limiters    map[Operation]Limiter
+	ctx         context.Context
+}
+
+// NewRateLimitedRepo creates a RateLimitedRepo. Operations may share the same limiter.
+func NewRateLimitedRepo(repo KeyValueRepo, limiters map[Operation]Limiter) *RateLimitedRepo {
+	return &RateLimitedRepo{
+		wrappedRepo: repo,
+		limiters:    limiters,
+		ctx:         context.Background(),
+	}
+}
+
+// WithContext returns a copy of the repository which uses the context while waiting for the
+// limiters. The limiters are shared with the original repository.
+func (repo *RateLimitedRepo) WithContext(ctx context.Context) *RateLimitedRepo {
+	return &RateLimitedRepo{
+		wrappedRepo: repo.wrappedRepo,
+		limiters:    repo.limiters,
+		ctx:         ctx,
+	}
+}
+
+// FindAll calls function of wrapped repository once the rate limit allows it
+func (repo *RateLimitedRepo) FindAll() ([]KeyValuePair, error) {
+	if err := repo.wait(OperationFindAll); err != nil {
+		return nil, err
+	}
+	return repo.wrappedRepo.FindAll()
+}
+
+// Find calls function of wrapped repository once the rate limit allows it
+func (repo *RateLimitedRepo) Find(key string) (KeyValuePair, error) {
+	if err := repo.wait(OperationFind); err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Find(key)
+}
+
+// Save calls function of wrapped repository once the rate limit allows it
+func (repo *RateLimitedRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	if err := repo.wait(OperationSave); err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Save(key, in)
+}
+
+// Overwrite calls function of wrapped repository once the rate limit allows it
+func (repo *RateLimitedRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	if err := repo.wait(OperationOverwrite); err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Overwrite(key, in)
+}
+
+// Delete calls function of wrapped repository once the rate limit allows it
+func (repo *RateLimitedRepo) Delete(key string) error {
+	if err := repo.wait(OperationDelete); err != nil {
+		return err
+	}
+	return repo.wrappedRepo.Delete(key)
+}
+
+func (repo *RateLimitedRepo) wait(operation Operation) error {
+	limiter, ok := repo.limiters[operation]
+	if !ok {
+		return nil
+	}
+	return limiter.Wait(repo.ctx)
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedRepoBurst(t *testing.T) {
+	bucket, err := NewTokenBucket(1, 2)
+	checkError(err, t)
+	repo := NewRateLimitedRepo(NewInMemoryRepo(), map[Operation]Limiter{OperationSave: bucket})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	limited := repo.WithContext(ctx)
+
+	_, err = limited.Save("a", "value")
+	checkError(err, t)
+	_, err = limited.Save("b", "value")
+	checkError(err, t)
+	_, err = limited.Save("c", "value")
+
+	if err != ErrRateLimited {
+		t.Errorf("Expected %v but found %v", ErrRateLimited, err)
+	}
+	if _, err := repo.Find("c"); err == nil {
+		t.Error("Expected limited call not to reach the wrapped repository")
+	}
+}
+
+func TestRateLimitedRepoUnlimitedOperation(t *testing.T) {
+	bucket, err := NewTokenBucket(1, 1)
+	checkError(err, t)
+	repo := NewRateLimitedRepo(NewInMemoryRepo(), map[Operation]Limiter{OperationSave: bucket})
+	_, err = repo.Save("key", "value")
+	checkError(err, t)
+
+	for i := 0; i < 10; i++ {
+		_, err := repo.Find("key")
+		checkError(err, t)
+	}
+}
+
+func TestRateLimitedRepoBlocks(t *testing.T) {
+	bucket, err := NewTokenBucket(100, 1)
+	checkError(err, t)
+	repo := NewRateLimitedRepo(NewInMemoryRepo(), map[Operation]Limiter{OperationFindAll: bucket})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := repo.FindAll()
+		checkError(err, t)
+	}
+
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("Expected calls to be delayed but took %v", elapsed)
+	}
+}
+
+func TestTokenBucketCanceled(t *testing.T) {
+	bucket, err := NewTokenBucket(1, 1)
+	checkError(err, t)
+	checkError(bucket.Wait(context.Background()), t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = bucket.Wait(ctx)
+
+	if err != context.Canceled {
+		t.Errorf("Expected %v but found %v", context.Canceled, err)
+	}
+}
+
+func TestTokenBucketInvalid(t *testing.T) {
+	for _, invalid := range []struct {
+		rate  float64
+		burst int
+	}{{0, 1}, {-1, 1}, {math.NaN(), 1}, {1, 0}, {1, -1}} {
+		if _, err := NewTokenBucket(invalid.rate, invalid.burst); err == nil {
+			t.Errorf("Expected error for rate %v and burst %d", invalid.rate, invalid.burst)
+		}
+	}
+}
+
+func TestDistributedLimiter(t *testing.T) {
+	counter := NewInMemoryRepo()
+	now := time.Now().Add(time.Hour).Truncate(time.Minute)
+	limiter := NewDistributedLimiter(counter, "ssm", 2, time.Minute)
+	limiter.now = func() time.Time { return now }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	checkError(limiter.Wait(ctx), t)
+	checkError(limiter.Wait(ctx), t)
+	err := limiter.Wait(ctx)
+	if err != ErrRateLimited {
+		t.Errorf("Expected %v but found %v", ErrRateLimited, err)
+	}
+
+	now = now.Add(time.Minute)
+	checkError(limiter.Wait(ctx), t)
+	items, _ := counter.FindAll()
+	if len(items) != 1 {
+		t.Errorf("Expected counter of previous window to be deleted but found %+v", items)
+	}
+}
+
+func TestDistributedLimiterSharedCounter(t *testing.T) {
+	counter := NewInMemoryRepo()
+	first := NewDistributedLimiter(counter, "ssm", 1, time.Hour)
+	second := NewDistributedLimiter(counter, "ssm", 1, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	checkError(first.Wait(ctx), t)
+	err := second.Wait(ctx)
+
+	if err != ErrRateLimited {
+		t.Errorf("Expected %v but found %v", ErrRateLimited, err)
+	}
+}
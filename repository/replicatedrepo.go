@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+// ReplicatedRepo keeps the same data in multiple repositories, e.g. in different regions.
+// Writes are sent to all replicas concurrently and succeed once the write quorum is reached.
+// Replicas which failed to apply a write are repaired asynchronously. Reads are served by
+// the primary, the first replica, and fall back to the other replicas in order on errors
+// other than ErrNotFound.
+//
+// Save fails with ErrAlreadyExists if the key exists on any replica, these replicas are not
+// repaired to keep existing values.
+//
+// A write which does not reach the quorum is not rolled back on the replicas which applied it.
+// Repairs are not ordered with later writes to the same key, use Reconcile to detect replicas
+// which diverged nevertheless.
+type ReplicatedRepo struct {
+	replicas    []KeyValueRepo
+	writeQuorum int
+	repairs     sync.WaitGroup
+}
+
+// Mismatch describes a key which differs between the primary and other replicas
+type Mismatch struct {
+	Key string `json:"key"`
+	// indices of the replicas with a differing or missing value
+	Replicas []int `json:"replicas"`
+}
+
+// ReconciliationReport lists all keys which are not the same on all replicas
+type ReconciliationReport struct {
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// NewReplicatedRepo creates a ReplicatedRepo. The write quorum is the number of replicas which
+// have to apply a write, it has to be between 1 and the number of replicas.
+func NewReplicatedRepo(writeQuorum int, primary KeyValueRepo, replicas ...KeyValueRepo) (*ReplicatedRepo, error) {
+	all := append([]KeyValueRepo{primary}, replicas...)
+	if writeQuorum < 1 || writeQuorum > len(all) {
+		return nil, fmt.Errorf("write quorum %d has to be between 1 and %d", writeQuorum, len(all))
+	}
+	return &ReplicatedRepo{
+		replicas:    all,
+		writeQuorum: writeQuorum,
+	}, nil
+}
+
+// FindAll returns the items of the first replica which does not fail
+func (repo *ReplicatedRepo) FindAll() ([]KeyValuePair, error) {
+	var err error
+	for _, replica := range repo.replicas {
+		var items []KeyValuePair
+		if items, err = replica.FindAll(); err == nil || errors.Is(err, ErrNotFound) {
+			return items, err
+		}
+	}
+	return nil, err
+}
+
+// Find returns the item of the first replica which does not fail. ErrNotFound is returned
+// without asking other replicas, which may still contain an item deleted on the replica.
+func (repo *ReplicatedRepo) Find(key string) (KeyValuePair, error) {
+	var err error
+	for _, replica := range repo.replicas {
+		var item KeyValuePair
+		if item, err = replica.Find(key); err == nil || errors.Is(err, ErrNotFound) {
+			return item, err
+		}
+	}
+	return KeyValuePair{}, err
+}
+
+// Save saves an item on all replicas
+func (repo *ReplicatedRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	return repo.write(func(replica KeyValueRepo) (KeyValuePair, error) {
+		return replica.Save(key, in)
+	}, func(replica KeyValueRepo) error {
+		_, err := replica.Overwrite(key, in)
+		return err
+	})
+}
+
+// Overwrite overwrites an item on all replicas
+func (repo *ReplicatedRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	overwrite := func(replica KeyValueRepo) (KeyValuePair, error) {
+		return replica.Overwrite(key, in)
+	}
+	return repo.write(overwrite, func(replica KeyValueRepo) error {
+		_, err := overwrite(replica)
+		return err
+	})
+}
+
+// Delete deletes an item on all replicas
+func (repo *ReplicatedRepo) Delete(key string) error {
+	_, err := repo.write(func(replica KeyValueRepo) (KeyValuePair, error) {
+		return KeyValuePair{}, replica.Delete(key)
+	}, func(replica KeyValueRepo) error {
+		err := replica.Delete(key)
+		if _, findErr := replica.Find(key); errors.Is(findErr, ErrNotFound) {
+			// the item does not exist anymore
+			return nil
+		}
+		return err
+	})
+	return err
+}
+
+// Wait blocks until all pending repairs are finished
+func (repo *ReplicatedRepo) Wait() {
+	repo.repairs.Wait()
+}
+
+// Reconcile compares the items of all replicas with the primary. Values are compared by their
+// json representation.
+func (repo *ReplicatedRepo) Reconcile() (ReconciliationReport, error) {
+	replicaValues := make([]map[string]string, len(repo.replicas))
+	keys := make(map[string]bool)
+	for i, replica := range repo.replicas {
+		items, err := replica.FindAll()
+		if err != nil {
+			return ReconciliationReport{}, fmt.Errorf("could not read replica %d: %v", i, err)
+		}
+		replicaValues[i] = make(map[string]string, len(items))
+		for _, item := range items {
+			serialized, err := serialization.ToJSON(item.Value)
+			if err != nil {
+				return ReconciliationReport{}, err
+			}
+			replicaValues[i][item.Key] = serialized
+			keys[item.Key] = true
+		}
+	}
+
+	report := ReconciliationReport{
+		Mismatches: []Mismatch{},
+	}
+	for key := range keys {
+		expected, expectedOk := replicaValues[0][key]
+		mismatch := Mismatch{Key: key}
+		for i := 1; i < len(replicaValues); i++ {
+			value, ok := replicaValues[i][key]
+			if ok != expectedOk || value != expected {
+				mismatch.Replicas = append(mismatch.Replicas, i)
+			}
+		}
+		if len(mismatch.Replicas) > 0 {
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+	}
+	sort.Slice(report.Mismatches, func(i, j int) bool {
+		return report.Mismatches[i].Key < report.Mismatches[j].Key
+	})
+	return report, nil
+}
+
+type replicaResult struct {
+	item KeyValuePair
+	err  error
+}
+
+// write applies a write to all replicas concurrently and schedules the repair of failed replicas
+// if the quorum was reached. The result of the first successful replica is returned.
+// Replicas failing with ErrAlreadyExists fail the write and are not repaired.
+func (repo *ReplicatedRepo) write(apply func(KeyValueRepo) (KeyValuePair, error), repair func(KeyValueRepo) error) (KeyValuePair, error) {
+	results := make([]replicaResult, len(repo.replicas))
+	var wg sync.WaitGroup
+	for i, replica := range repo.replicas {
+		wg.Add(1)
+		go func(i int, replica KeyValueRepo) {
+			defer wg.Done()
+			item, err := apply(replica)
+			results[i] = replicaResult{item: item, err: err}
+		}(i, replica)
+	}
+	wg.Wait()
+
+	succeeded := make([]int, 0, len(results))
+	failed := make([]int, 0)
+	messages := make([]string, 0)
+	var cause error
+	for i, result := range results {
+		if result.err == nil {
+			succeeded = append(succeeded, i)
+			continue
+		}
+		if errors.Is(result.err, ErrAlreadyExists) {
+			return KeyValuePair{}, fmt.Errorf("replica %d: %w", i, result.err)
+		}
+		failed = append(failed, i)
+		messages = append(messages, fmt.Sprintf("replica %d: %v", i, result.err))
+		if cause == nil || (!isSentinelError(cause) && isSentinelError(result.err)) {
+			cause = result.err
+		}
+	}
+	if len(succeeded) < repo.writeQuorum {
+		return KeyValuePair{}, &quorumError{
+			message: fmt.Sprintf("write quorum of %d not reached: %s", repo.writeQuorum, strings.Join(messages, ", ")),
+			cause:   cause,
+		}
+	}
+
+	for _, i := range failed {
+		repo.repairs.Add(1)
+		go func(i int) {
+			defer repo.repairs.Done()
+			if err := repair(repo.replicas[i]); err != nil {
+				log.Printf("could not repair replica %d: %v", i, err)
+			}
+		}(i)
+	}
+	return results[succeeded[0]].item, nil
+}
+
+// quorumError is returned if a write did not reach the quorum. It wraps the first error of
+// the replicas which is one of the errors of the repository package, e.g. ErrNotFound, or the
+// first error if there is none.
+type quorumError struct {
+	message string
+	cause   error
+}
+
+func (err *quorumError) Error() string {
+	return err.message
+}
+
+func (err *quorumError) Unwrap() error {
+	return err.cause
+}
+
+// isSentinelError checks if an error is one of the errors callers of repositories branch on
+func isSentinelError(err error) bool {
+	for _, sentinel := range []error{ErrNotFound, ErrAlreadyExists, ErrConditionFailed, ErrInvalidKey} {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	return false
+}
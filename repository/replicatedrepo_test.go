@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestReplicatedRepoInvalidQuorum(t *testing.T) {
+	_, err := NewReplicatedRepo(3, NewInMemoryRepo(), NewInMemoryRepo())
+
+	checkFailure(err, t)
+}
+
+func TestReplicatedRepoSave(t *testing.T) {
+	primary, replica := NewInMemoryRepo(), NewInMemoryRepo()
+	repo, err := NewReplicatedRepo(2, primary, replica)
+	checkError(err, t)
+
+	_, err = repo.Save("key", "value")
+	checkError(err, t)
+
+	for i, r := range []KeyValueRepo{primary, replica} {
+		if item, err := r.Find("key"); err != nil || item.Value != "value" {
+			t.Errorf("Expected value on replica %d but found %+v, %v", i, item, err)
+		}
+	}
+}
+
+func TestReplicatedRepoQuorumNotReached(t *testing.T) {
+	failing := &failingRepo{InMemoryRepo: NewInMemoryRepo(), failures: 1}
+	repo, err := NewReplicatedRepo(2, NewInMemoryRepo(), failing)
+	checkError(err, t)
+
+	_, err = repo.Save("key", "value")
+
+	checkFailure(err, t)
+}
+
+func TestReplicatedRepoQuorumNotReachedWrapsSentinel(t *testing.T) {
+	failing := &failingRepo{InMemoryRepo: NewInMemoryRepo(), failures: 1}
+	repo, err := NewReplicatedRepo(2, failing, NewInMemoryRepo())
+	checkError(err, t)
+
+	err = repo.Delete("missing")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound but found %v", err)
+	}
+}
+
+func TestReplicatedRepoSaveExisting(t *testing.T) {
+	primary, replica := NewInMemoryRepo(), NewInMemoryRepo()
+	_, err := replica.Save("key", "existing")
+	checkError(err, t)
+	repo, err := NewReplicatedRepo(1, primary, replica)
+	checkError(err, t)
+
+	_, err = repo.Save("key", "value")
+	repo.Wait()
+
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists but found %v", err)
+	}
+	if item, _ := replica.Find("key"); item.Value != "existing" {
+		t.Errorf("Expected existing value to be kept but found %v", item.Value)
+	}
+}
+
+func TestReplicatedRepoFindDeleted(t *testing.T) {
+	primary, replica := NewInMemoryRepo(), NewInMemoryRepo()
+	_, err := replica.Save("key", "deleted")
+	checkError(err, t)
+	repo, err := NewReplicatedRepo(1, primary, replica)
+	checkError(err, t)
+
+	_, err = repo.Find("key")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound of the primary but found %v", err)
+	}
+}
+
+func TestReplicatedRepoRepair(t *testing.T) {
+	failing := &failingRepo{InMemoryRepo: NewInMemoryRepo(), failures: 1}
+	repo, err := NewReplicatedRepo(1, NewInMemoryRepo(), failing)
+	checkError(err, t)
+
+	_, err = repo.Overwrite("key", "value")
+	checkError(err, t)
+	repo.Wait()
+
+	item, err := failing.InMemoryRepo.Find("key")
+	checkError(err, t)
+	if item.Value != "value" {
+		t.Errorf("Expected repaired value but found %v", item.Value)
+	}
+}
+
+func TestReplicatedRepoRepairDelete(t *testing.T) {
+	failing := &failingRepo{InMemoryRepo: NewInMemoryRepo()}
+	repo, err := NewReplicatedRepo(1, NewInMemoryRepo(), failing)
+	checkError(err, t)
+	_, err = repo.Save("key", "value")
+	checkError(err, t)
+	failing.fail(1)
+
+	err = repo.Delete("key")
+	checkError(err, t)
+	repo.Wait()
+
+	if _, err := failing.InMemoryRepo.Find("key"); err == nil {
+		t.Error("Expected key to be deleted on replica")
+	}
+}
+
+func TestReplicatedRepoReadFallback(t *testing.T) {
+	primary := &failingRepo{InMemoryRepo: NewInMemoryRepo()}
+	replica := NewInMemoryRepo()
+	repo, err := NewReplicatedRepo(2, primary, replica)
+	checkError(err, t)
+	_, err = repo.Save("key", "value")
+	checkError(err, t)
+	primary.fail(2)
+
+	item, err := repo.Find("key")
+	checkError(err, t)
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if item.Value != "value" {
+		t.Errorf("Expected value but found %v", item.Value)
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 item but found %d", len(items))
+	}
+}
+
+func TestReplicatedRepoReconcile(t *testing.T) {
+	primary, first, second := NewInMemoryRepo(), NewInMemoryRepo(), NewInMemoryRepo()
+	repo, err := NewReplicatedRepo(3, primary, first, second)
+	checkError(err, t)
+	_, err = repo.Save("equal", "value")
+	checkError(err, t)
+	_, err = primary.Save("missing", "value")
+	checkError(err, t)
+	_, err = primary.Save("different", "a")
+	checkError(err, t)
+	_, err = first.Save("different", "a")
+	checkError(err, t)
+	_, err = second.Save("different", "b")
+	checkError(err, t)
+	_, err = second.Save("additional", "value")
+	checkError(err, t)
+
+	report, err := repo.Reconcile()
+	checkError(err, t)
+
+	expected := []string{"additional:[2]", "different:[2]", "missing:[1 2]"}
+	if len(report.Mismatches) != len(expected) {
+		t.Fatalf("Expected %v but found %+v", expected, report.Mismatches)
+	}
+	for i, mismatch := range report.Mismatches {
+		if actual := fmt.Sprintf("%s:%v", mismatch.Key, mismatch.Replicas); actual != expected[i] {
+			t.Errorf("Expected %s but found %s", expected[i], actual)
+		}
+	}
+}
+
+// failingRepo fails a given number of calls
+type failingRepo struct {
+	*InMemoryRepo
+	failures int
+	mutex    sync.Mutex
+}
+
+func (repo *failingRepo) fail(failures int) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.failures = failures
+}
+
+func (repo *failingRepo) err() error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if repo.failures > 0 {
+		repo.failures--
+		return fmt.Errorf("unavailable")
+	}
+	return nil
+}
+
+func (repo *failingRepo) FindAll() ([]KeyValuePair, error) {
+	if err := repo.err(); err != nil {
+		return nil, err
+	}
+	return repo.InMemoryRepo.FindAll()
+}
+
+func (repo *failingRepo) Find(key string) (KeyValuePair, error) {
+	if err := repo.err(); err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.InMemoryRepo.Find(key)
+}
+
+func (repo *failingRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	if err := repo.err(); err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.InMemoryRepo.Save(key, in)
+}
+
+func (repo *failingRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	if err := repo.err(); err != nil {
+		return KeyValuePair{}, err
+	}
+	return repo.InMemoryRepo.Overwrite(key, in)
+}
+
+func (repo *failingRepo) Delete(key string) error {
+	if err := repo.err(); err != nil {
+		return err
+	}
+	return repo.InMemoryRepo.Delete(key)
+}
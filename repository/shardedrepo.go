@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// defaultVirtualNodes is the number of points each shard has on the hash ring
+const defaultVirtualNodes = 100
+
+// ShardedRepo distributes keys across multiple repositories using consistent hashing.
+// Each shard is placed multiple times on a hash ring via virtual nodes to spread the keys evenly.
+// Adding a shard only moves the keys which are assigned to the new shard.
+type ShardedRepo struct {
+	shards       map[string]KeyValueRepo
+	ring         []ringNode
+	virtualNodes int
+	mutex        sync.RWMutex
+}
+
+type ringNode struct {
+	hash  uint64
+	shard string
+}
+
+// NewShardedRepo creates a ShardedRepo with the given shards. The names of the shards
+// determine their position on the hash ring and have to stay the same between deployments.
+func NewShardedRepo(shards map[string]KeyValueRepo) *ShardedRepo {
+	return NewShardedRepoWithVirtualNodes(shards, defaultVirtualNodes)
+}
+
+// NewShardedRepoWithVirtualNodes creates a ShardedRepo which places each shard with the given
+// number of virtual nodes on the hash ring
+func NewShardedRepoWithVirtualNodes(shards map[string]KeyValueRepo, virtualNodes int) *ShardedRepo {
+	repo := &ShardedRepo{
+		shards:       make(map[string]KeyValueRepo, len(shards)),
+		virtualNodes: virtualNodes,
+	}
+	for name, shard := range shards {
+		repo.addToRing(name, shard)
+	}
+	return repo
+}
+
+// FindAll merges the items of all shards
+func (repo *ShardedRepo) FindAll() ([]KeyValuePair, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	result := make([]KeyValuePair, 0)
+	for name, shard := range repo.shards {
+		items, err := shard.FindAll()
+		if err != nil {
+			return nil, fmt.Errorf("could not read shard %s: %v", name, err)
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+// Find calls function of the shard of the key
+func (repo *ShardedRepo) Find(key string) (KeyValuePair, error) {
+	shard, err := repo.shardOf(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return shard.Find(key)
+}
+
+// Save calls function of the shard of the key
+func (repo *ShardedRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	shard, err := repo.shardOf(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return shard.Save(key, in)
+}
+
+// Overwrite calls function of the shard of the key
+func (repo *ShardedRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	shard, err := repo.shardOf(key)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	return shard.Overwrite(key, in)
+}
+
+// Delete calls function of the shard of the key
+func (repo *ShardedRepo) Delete(key string) error {
+	shard, err := repo.shardOf(key)
+	if err != nil {
+		return err
+	}
+	return shard.Delete(key)
+}
+
+// ShardOf returns the name of the shard which stores the key
+func (repo *ShardedRepo) ShardOf(key string) (string, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if len(repo.ring) == 0 {
+		return "", fmt.Errorf("no shards configured")
+	}
+	hash := hashOf(key)
+	index := sort.Search(len(repo.ring), func(i int) bool {
+		return repo.ring[i].hash >= hash
+	})
+	if index == len(repo.ring) {
+		index = 0
+	}
+	return repo.ring[index].shard, nil
+}
+
+// AddShard adds a shard to the hash ring and moves all items which are assigned to the new
+// shard from the other shards. It returns the number of moved items.
+// Writes during the rebalancing may be lost, so it should be done in a maintenance window.
+func (repo *ShardedRepo) AddShard(name string, shard KeyValueRepo) (int, error) {
+	repo.mutex.Lock()
+	if _, ok := repo.shards[name]; ok {
+		repo.mutex.Unlock()
+		return 0, fmt.Errorf("shard %s already exists", name)
+	}
+	previous := make(map[string]KeyValueRepo, len(repo.shards))
+	for existingName, existing := range repo.shards {
+		previous[existingName] = existing
+	}
+	repo.addToRing(name, shard)
+	repo.mutex.Unlock()
+
+	moved := 0
+	for previousName, previousShard := range previous {
+		items, err := previousShard.FindAll()
+		if err != nil {
+			return moved, fmt.Errorf("could not read shard %s: %v", previousName, err)
+		}
+		for _, item := range items {
+			target, err := repo.ShardOf(item.Key)
+			if err != nil {
+				return moved, err
+			}
+			if target != name {
+				continue
+			}
+			if _, err := shard.Overwrite(item.Key, item.Value); err != nil {
+				return moved, err
+			}
+			if err := previousShard.Delete(item.Key); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func (repo *ShardedRepo) shardOf(key string) (KeyValueRepo, error) {
+	name, err := repo.ShardOf(key)
+	if err != nil {
+		return nil, err
+	}
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return repo.shards[name], nil
+}
+
+// addToRing places the virtual nodes of a shard on the ring, the caller has to hold the lock
+func (repo *ShardedRepo) addToRing(name string, shard KeyValueRepo) {
+	repo.shards[name] = shard
+	for i := 0; i < repo.virtualNodes; i++ {
+		repo.ring = append(repo.ring, ringNode{
+			hash:  hashOf(fmt.Sprintf("%s#%d", name, i)),
+			shard: name,
+		})
+	}
+	sort.Slice(repo.ring, func(i, j int) bool {
+		return repo.ring[i].hash < repo.ring[j].hash
+	})
+}
+
+func hashOf(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
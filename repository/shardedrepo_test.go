@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+)
+
+func createShards(names ...string) map[string]KeyValueRepo {
+	shards := make(map[string]KeyValueRepo, len(names))
+	for _, name := range names {
+		shards[name] = NewInMemoryRepo()
+	}
+	return shards
+}
+
+func TestShardedRepoDistribution(t *testing.T) {
+	shards := createShards("a", "b", "c")
+	repo := NewShardedRepo(shards)
+
+	for i := 0; i < 300; i++ {
+		_, err := repo.Save(fmt.Sprintf("key%d", i), i)
+		checkError(err, t)
+	}
+
+	for name, shard := range shards {
+		items, _ := shard.FindAll()
+		if len(items) < 50 {
+			t.Errorf("Expected shard %s to have a fair share of items but found %d", name, len(items))
+		}
+	}
+}
+
+func TestShardedRepoFind(t *testing.T) {
+	repo := NewShardedRepo(createShards("a", "b"))
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+
+	item, err := repo.Find("key")
+	checkError(err, t)
+
+	if item.Value != "value" {
+		t.Errorf("Expected value but found %v", item.Value)
+	}
+}
+
+func TestShardedRepoFindAll(t *testing.T) {
+	repo := NewShardedRepo(createShards("a", "b", "c"))
+	for i := 0; i < 20; i++ {
+		_, err := repo.Save(fmt.Sprintf("key%d", i), i)
+		checkError(err, t)
+	}
+
+	items, err := repo.FindAll()
+	checkError(err, t)
+
+	if len(items) != 20 {
+		t.Errorf("Expected 20 items but found %d", len(items))
+	}
+}
+
+func TestShardedRepoDelete(t *testing.T) {
+	repo := NewShardedRepo(createShards("a", "b"))
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	_, err = repo.Find("key")
+	checkFailure(err, t)
+}
+
+func TestShardedRepoNoShards(t *testing.T) {
+	repo := NewShardedRepo(nil)
+
+	_, err := repo.Save("key", "value")
+
+	checkFailure(err, t)
+}
+
+func TestShardedRepoAddShard(t *testing.T) {
+	repo := NewShardedRepo(createShards("a", "b"))
+	for i := 0; i < 300; i++ {
+		_, err := repo.Save(fmt.Sprintf("key%d", i), i)
+		checkError(err, t)
+	}
+	shard := NewInMemoryRepo()
+
+	moved, err := repo.AddShard("c", shard)
+	checkError(err, t)
+
+	items, _ := shard.FindAll()
+	if moved == 0 || moved != len(items) {
+		t.Errorf("Expected %d moved items but found %d", len(items), moved)
+	}
+	all, _ := repo.FindAll()
+	if len(all) != 300 {
+		t.Errorf("Expected 300 items but found %d", len(all))
+	}
+	for i := 0; i < 300; i++ {
+		if _, err := repo.Find(fmt.Sprintf("key%d", i)); err != nil {
+			t.Errorf("Expected to find key%d after rebalancing", i)
+		}
+	}
+}
+
+func TestShardedRepoAddExistingShard(t *testing.T) {
+	repo := NewShardedRepo(createShards("a"))
+
+	_, err := repo.AddShard("a", NewInMemoryRepo())
+
+	checkFailure(err, t)
+}
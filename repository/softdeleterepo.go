@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jo-hoe/serverless-toolbox/serialization"
+)
+
+// Tombstone is the record of a deleted item stored in the trash repository
+type Tombstone struct {
+	Key string `json:"key"`
+	// serialized value
+	Value     string    `json:"value"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// ToStruct converts json string to struct
+func (tombstone Tombstone) ToStruct(jsonString string) (interface{}, error) {
+	err := json.Unmarshal([]byte(jsonString), &tombstone)
+	return tombstone, err
+}
+
+// DeletedItem is an item in the trash
+type DeletedItem struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	DeletedAt time.Time   `json:"deletedAt"`
+}
+
+// SoftDeleteRepo moves deleted items of the wrapped repository into a trash repository
+// instead of removing them. Deleted items are not returned by Find or FindAll, but can be
+// listed via Trash and restored via Undelete until they are purged.
+// Repositories which are decoding values need to use Tombstone as item template for the trash.
+type SoftDeleteRepo struct {
+	wrappedRepo      KeyValueRepo
+	trashRepo        KeyValueRepo
+	toStructFunction func(jsonString string) (interface{}, error)
+	now              func() time.Time
+}
+
+// NewSoftDeleteRepo creates a SoftDeleteRepo. The trashRepo stores the tombstones of deleted
+// items and the toStruct function decodes their values.
+func NewSoftDeleteRepo(repo KeyValueRepo, trashRepo KeyValueRepo, toStruct func(jsonString string) (interface{}, error)) *SoftDeleteRepo {
+	return &SoftDeleteRepo{
+		wrappedRepo:      repo,
+		trashRepo:        trashRepo,
+		toStructFunction: toStruct,
+		now:              time.Now,
+	}
+}
+
+// FindAll calls function of wrapped repository
+func (repo *SoftDeleteRepo) FindAll() ([]KeyValuePair, error) {
+	return repo.wrappedRepo.FindAll()
+}
+
+// Find calls function of wrapped repository
+func (repo *SoftDeleteRepo) Find(key string) (KeyValuePair, error) {
+	return repo.wrappedRepo.Find(key)
+}
+
+// Save calls function of wrapped repository
+func (repo *SoftDeleteRepo) Save(key string, in interface{}) (KeyValuePair, error) {
+	return repo.wrappedRepo.Save(key, in)
+}
+
+// Overwrite calls function of wrapped repository
+func (repo *SoftDeleteRepo) Overwrite(key string, in interface{}) (KeyValuePair, error) {
+	return repo.wrappedRepo.Overwrite(key, in)
+}
+
+// Delete moves an item into the trash. An older tombstone of the same key is replaced.
+func (repo *SoftDeleteRepo) Delete(key string) error {
+	item, err := repo.wrappedRepo.Find(key)
+	if err != nil {
+		return err
+	}
+	serialized, err := serialization.ToJSON(item.Value)
+	if err != nil {
+		return err
+	}
+
+	tombstone := Tombstone{
+		Key:       key,
+		Value:     serialized,
+		DeletedAt: repo.now(),
+	}
+	if _, err := repo.trashRepo.Overwrite(key, tombstone); err != nil {
+		return err
+	}
+	if err := repo.wrappedRepo.Delete(key); err != nil {
+		// the item still exists, so the tombstone is obsolete
+		_ = repo.trashRepo.Delete(key)
+		return err
+	}
+	return nil
+}
+
+// Trash lists all deleted items which were not purged yet
+func (repo *SoftDeleteRepo) Trash() ([]DeletedItem, error) {
+	items, err := repo.trashRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]DeletedItem, 0, len(items))
+	for _, item := range items {
+		deleted, err := repo.toDeletedItem(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, deleted)
+	}
+	return result, nil
+}
+
+// Undelete restores an item from the trash. An error is returned if an item with the
+// same key was saved in the meantime.
+func (repo *SoftDeleteRepo) Undelete(key string) (KeyValuePair, error) {
+	item, err := repo.trashRepo.Find(key)
+	if errors.Is(err, ErrNotFound) {
+		return KeyValuePair{}, fmt.Errorf("key %s %w in trash", key, ErrNotFound)
+	}
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+	deleted, err := repo.toDeletedItem(item.Value)
+	if err != nil {
+		return KeyValuePair{}, err
+	}
+
+	result, err := repo.wrappedRepo.Save(key, deleted.Value)
+	if err != nil {
+		return result, err
+	}
+	return result, repo.trashRepo.Delete(key)
+}
+
+// Purge removes all items from the trash which were deleted longer ago than the retention
+// period and returns the number of removed items. It is meant to be called periodically,
+// e.g. by a scheduled Lambda function.
+func (repo *SoftDeleteRepo) Purge(retention time.Duration) (int, error) {
+	items, err := repo.trashRepo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+
+	threshold := repo.now().Add(-retention)
+	purged := 0
+	for _, item := range items {
+		tombstone, err := toTombstone(item.Value)
+		if err != nil {
+			return purged, err
+		}
+		if !tombstone.DeletedAt.Before(threshold) {
+			continue
+		}
+		if err := repo.trashRepo.Delete(item.Key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (repo *SoftDeleteRepo) toDeletedItem(value interface{}) (DeletedItem, error) {
+	tombstone, err := toTombstone(value)
+	if err != nil {
+		return DeletedItem{}, err
+	}
+	decoded, err := repo.toStructFunction(tombstone.Value)
+	return DeletedItem{
+		Key:       tombstone.Key,
+		Value:     decoded,
+		DeletedAt: tombstone.DeletedAt,
+	}, err
+}
+
+// toTombstone converts stored values into a tombstone independent of the decoding of the repository
+func toTombstone(value interface{}) (Tombstone, error) {
+	result := Tombstone{}
+	err := DecodeValue(value, &result)
+	return result, err
+}
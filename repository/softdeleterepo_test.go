@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func createSoftDeleteRepo() *SoftDeleteRepo {
+	return NewSoftDeleteRepo(NewInMemoryRepo(), NewInMemoryRepo(), toStringStruct)
+}
+
+func TestSoftDeleteRepoDelete(t *testing.T) {
+	repo := createSoftDeleteRepo()
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	_, err = repo.Find("key")
+	checkFailure(err, t)
+	items, _ := repo.FindAll()
+	if len(items) != 0 {
+		t.Errorf("Expected deleted item to be hidden but found %+v", items)
+	}
+}
+
+func TestSoftDeleteRepoDeleteMissing(t *testing.T) {
+	repo := createSoftDeleteRepo()
+
+	err := repo.Delete("invalid")
+
+	checkFailure(err, t)
+}
+
+func TestSoftDeleteRepoTrash(t *testing.T) {
+	repo := createSoftDeleteRepo()
+	deletedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return deletedAt }
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	trash, err := repo.Trash()
+	checkError(err, t)
+
+	if len(trash) != 1 {
+		t.Fatalf("Expected 1 item in trash but found %d", len(trash))
+	}
+	if trash[0].Key != "key" || trash[0].Value != "value" || !trash[0].DeletedAt.Equal(deletedAt) {
+		t.Errorf("Unexpected item in trash %+v", trash[0])
+	}
+}
+
+func TestSoftDeleteRepoUndelete(t *testing.T) {
+	repo := createSoftDeleteRepo()
+	_, err := repo.Save("key", "value")
+	checkError(err, t)
+	err = repo.Delete("key")
+	checkError(err, t)
+
+	_, err = repo.Undelete("key")
+	checkError(err, t)
+
+	item, err := repo.Find("key")
+	checkError(err, t)
+	if item.Value != "value" {
+		t.Errorf("Expected value but found %v", item.Value)
+	}
+	trash, _ := repo.Trash()
+	if len(trash) != 0 {
+		t.Errorf("Expected empty trash but found %+v", trash)
+	}
+}
+
+func TestSoftDeleteRepoUndeleteExistingKey(t *testing.T) {
+	repo := createSoftDeleteRepo()
+	_, err := repo.Save("key", "old")
+	checkError(err, t)
+	err = repo.Delete("key")
+	checkError(err, t)
+	_, err = repo.Save("key", "new")
+	checkError(err, t)
+
+	_, err = repo.Undelete("key")
+
+	checkFailure(err, t)
+	item, _ := repo.Find("key")
+	if item.Value != "new" {
+		t.Errorf("Expected new but found %v", item.Value)
+	}
+}
+
+func TestSoftDeleteRepoUndeleteMissing(t *testing.T) {
+	repo := createSoftDeleteRepo()
+
+	_, err := repo.Undelete("invalid")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v but found %v", ErrNotFound, err)
+	}
+}
+
+func TestSoftDeleteRepoUndeleteUnavailableTrash(t *testing.T) {
+	trash := &failingRepo{InMemoryRepo: NewInMemoryRepo()}
+	repo := NewSoftDeleteRepo(NewInMemoryRepo(), trash, toStringStruct)
+	trash.fail(1)
+
+	_, err := repo.Undelete("key")
+
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected error of the trash repository but found %v", err)
+	}
+}
+
+func TestSoftDeleteRepoPurge(t *testing.T) {
+	repo := createSoftDeleteRepo()
+	now := time.Now()
+	repo.now = func() time.Time { return now.Add(-48 * time.Hour) }
+	_, err := repo.Save("old", "value")
+	checkError(err, t)
+	checkError(repo.Delete("old"), t)
+	repo.now = func() time.Time { return now }
+	_, err = repo.Save("new", "value")
+	checkError(err, t)
+	checkError(repo.Delete("new"), t)
+
+	purged, err := repo.Purge(24 * time.Hour)
+	checkError(err, t)
+
+	if purged != 1 {
+		t.Errorf("Expected 1 purged item but found %d", purged)
+	}
+	trash, _ := repo.Trash()
+	if len(trash) != 1 || trash[0].Key != "new" {
+		t.Errorf("Expected only new item in trash but found %+v", trash)
+	}
+}
@@ -0,0 +1,52 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// MergePatch applies a JSON merge patch as described in RFC 7396 to a json document
+// and returns the patched document.
+// An empty document is treated like null.
+func MergePatch(document []byte, patch []byte) ([]byte, error) {
+	var target interface{}
+	if len(document) > 0 {
+		if err := unmarshalJSON(document, &target); err != nil {
+			return nil, err
+		}
+	}
+
+	var patchValue interface{}
+	if err := unmarshalJSON(patch, &patchValue); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(target, patchValue))
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergeValue(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// unmarshalJSON keeps numbers in their textual form to not lose precision
+func unmarshalJSON(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
@@ -0,0 +1,325 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// Schema is a subset of JSON Schema draft 2020-12. Supported keywords are type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems, uniqueItems,
+// minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+// multipleOf, allOf, anyOf, oneOf and not. Other keywords, e.g. $ref or format, are ignored.
+type Schema struct {
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                *interface{}       `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MultipleOf           *float64           `json:"multipleOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+
+	// set for the boolean schemas true and false
+	boolean *bool
+	pattern *regexp.Regexp
+}
+
+// schemaTypes accepts a single type or a list of types
+type schemaTypes []string
+
+func (types *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*types = schemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	err := json.Unmarshal(data, &multiple)
+	*types = multiple
+	return err
+}
+
+// UnmarshalJSON parses a schema object or a boolean schema
+func (schema *Schema) UnmarshalJSON(data []byte) error {
+	var boolean bool
+	if err := json.Unmarshal(data, &boolean); err == nil {
+		*schema = Schema{boolean: &boolean}
+		return nil
+	}
+	// the alias prevents the recursion into this function
+	type schemaAlias Schema
+	alias := schemaAlias{}
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*schema = Schema(alias)
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", schema.Pattern, err)
+		}
+		schema.pattern = pattern
+	}
+	return nil
+}
+
+// SchemaValidator validates values against a JSON Schema. Values are validated in their json
+// representation, so json struct tags define the property names.
+type SchemaValidator struct {
+	schema *Schema
+}
+
+// NewSchemaValidator parses a JSON Schema
+func NewSchemaValidator(schema []byte) (*SchemaValidator, error) {
+	parsed := &Schema{}
+	if err := json.Unmarshal(schema, parsed); err != nil {
+		return nil, err
+	}
+	return &SchemaValidator{
+		schema: parsed,
+	}, nil
+}
+
+// Validate checks a value against the schema
+func (validator *SchemaValidator) Validate(value interface{}) error {
+	generic, err := toGeneric(value)
+	if err != nil {
+		return err
+	}
+	collector := &errorCollector{}
+	validator.schema.validate(generic, "", collector)
+	return collector.result()
+}
+
+func (schema *Schema) validate(value interface{}, pointer string, collector *errorCollector) {
+	if schema.boolean != nil {
+		if !*schema.boolean {
+			collector.add(pointer, "no value is allowed")
+		}
+		return
+	}
+
+	if len(schema.Type) > 0 && !schema.matchesType(value) {
+		collector.add(pointer, "expected type %v but found %s", []string(schema.Type), typeOf(value))
+		return
+	}
+	if schema.Enum != nil && !containsEqual(schema.Enum, value) {
+		collector.add(pointer, "value is not one of %v", schema.Enum)
+	}
+	if schema.Const != nil && !equal(*schema.Const, value) {
+		collector.add(pointer, "value has to be %v", *schema.Const)
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		schema.validateObject(typed, pointer, collector)
+	case []interface{}:
+		schema.validateArray(typed, pointer, collector)
+	case string:
+		schema.validateString(typed, pointer, collector)
+	case json.Number:
+		number, _ := typed.Float64()
+		schema.validateNumber(number, pointer, collector)
+	}
+
+	for _, subschema := range schema.AllOf {
+		subschema.validate(value, pointer, collector)
+	}
+	if len(schema.AnyOf) > 0 && schema.countMatches(schema.AnyOf, value) == 0 {
+		collector.add(pointer, "value does not match any schema")
+	}
+	if len(schema.OneOf) > 0 {
+		if matches := schema.countMatches(schema.OneOf, value); matches != 1 {
+			collector.add(pointer, "value has to match exactly one schema but matches %d", matches)
+		}
+	}
+	if schema.Not != nil && schema.countMatches([]*Schema{schema.Not}, value) == 1 {
+		collector.add(pointer, "value must not match the schema")
+	}
+}
+
+func (schema *Schema) validateObject(object map[string]interface{}, pointer string, collector *errorCollector) {
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			collector.add(childPointer(pointer, name), "field is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := schema.Properties[name]; ok {
+			property.validate(object[name], childPointer(pointer, name), collector)
+		} else if schema.AdditionalProperties != nil {
+			schema.AdditionalProperties.validate(object[name], childPointer(pointer, name), collector)
+		}
+	}
+}
+
+func (schema *Schema) validateArray(array []interface{}, pointer string, collector *errorCollector) {
+	if schema.MinItems != nil && len(array) < *schema.MinItems {
+		collector.add(pointer, "expected at least %d items but found %d", *schema.MinItems, len(array))
+	}
+	if schema.MaxItems != nil && len(array) > *schema.MaxItems {
+		collector.add(pointer, "expected at most %d items but found %d", *schema.MaxItems, len(array))
+	}
+	if schema.UniqueItems {
+		for i := range array {
+			if containsEqual(array[:i], array[i]) {
+				collector.add(childPointer(pointer, fmt.Sprint(i)), "item is not unique")
+			}
+		}
+	}
+	if schema.Items != nil {
+		for i, item := range array {
+			schema.Items.validate(item, childPointer(pointer, fmt.Sprint(i)), collector)
+		}
+	}
+}
+
+func (schema *Schema) validateString(value string, pointer string, collector *errorCollector) {
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		collector.add(pointer, "expected at least %d characters but found %d", *schema.MinLength, length)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		collector.add(pointer, "expected at most %d characters but found %d", *schema.MaxLength, length)
+	}
+	if schema.pattern != nil && !schema.pattern.MatchString(value) {
+		collector.add(pointer, "value does not match pattern %s", schema.Pattern)
+	}
+}
+
+func (schema *Schema) validateNumber(value float64, pointer string, collector *errorCollector) {
+	if schema.Minimum != nil && value < *schema.Minimum {
+		collector.add(pointer, "value has to be at least %v", *schema.Minimum)
+	}
+	if schema.Maximum != nil && value > *schema.Maximum {
+		collector.add(pointer, "value has to be at most %v", *schema.Maximum)
+	}
+	if schema.ExclusiveMinimum != nil && value <= *schema.ExclusiveMinimum {
+		collector.add(pointer, "value has to be greater than %v", *schema.ExclusiveMinimum)
+	}
+	if schema.ExclusiveMaximum != nil && value >= *schema.ExclusiveMaximum {
+		collector.add(pointer, "value has to be less than %v", *schema.ExclusiveMaximum)
+	}
+	if schema.MultipleOf != nil && *schema.MultipleOf != 0 {
+		quotient := value / *schema.MultipleOf
+		if quotient != math.Trunc(quotient) {
+			collector.add(pointer, "value has to be a multiple of %v", *schema.MultipleOf)
+		}
+	}
+}
+
+func (schema *Schema) matchesType(value interface{}) bool {
+	actual := typeOf(value)
+	for _, expected := range schema.Type {
+		if expected == actual {
+			return true
+		}
+		if expected == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// countMatches returns the number of schemas which accept the value
+func (schema *Schema) countMatches(schemas []*Schema, value interface{}) int {
+	matches := 0
+	for _, subschema := range schemas {
+		collector := &errorCollector{}
+		subschema.validate(value, "", collector)
+		if len(collector.errors) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// typeOf returns the JSON Schema type of a generic value
+func typeOf(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		number, err := typed.Float64()
+		if err == nil && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsEqual(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if equal(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares generic values, numbers are compared by their value
+func equal(a interface{}, b interface{}) bool {
+	normalizedA, errA := toGeneric(a)
+	normalizedB, errB := toGeneric(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalizeNumbers(normalizedA), normalizeNumbers(normalizedB))
+}
+
+func normalizeNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		number, _ := typed.Float64()
+		return number
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for i, item := range typed {
+			result[i] = normalizeNumbers(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for name, item := range typed {
+			result[name] = normalizeNumbers(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+const personSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 10, "pattern": "^[A-Z]"},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+		"score": {"type": ["number", "null"], "multipleOf": 0.5}
+	},
+	"required": ["name"],
+	"additionalProperties": false
+}`
+
+type person struct {
+	Name  string   `json:"name,omitempty"`
+	Age   int      `json:"age"`
+	Role  string   `json:"role,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	Score *float64 `json:"score,omitempty"`
+}
+
+func createPersonValidator(t *testing.T) *SchemaValidator {
+	validator, err := NewSchemaValidator([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return validator
+}
+
+func TestSchemaValidatorValid(t *testing.T) {
+	validator := createPersonValidator(t)
+	score := 1.5
+
+	err := validator.Validate(person{Name: "Alice", Age: 30, Role: "admin", Tags: []string{"a", "b"}, Score: &score})
+
+	if err != nil {
+		t.Errorf("Expected no error but found %v", err)
+	}
+}
+
+func TestSchemaValidatorFieldErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		field string
+	}{
+		{"required", person{Age: 1}, "/name"},
+		{"pattern", person{Name: "alice"}, "/name"},
+		{"maxLength", person{Name: "Abcdefghijk"}, "/name"},
+		{"minimum", person{Name: "Alice", Age: -1}, "/age"},
+		{"exclusiveMaximum", person{Name: "Alice", Age: 150}, "/age"},
+		{"enum", person{Name: "Alice", Role: "guest"}, "/role"},
+		{"maxItems", person{Name: "Alice", Tags: []string{"a", "b", "c"}}, "/tags"},
+		{"uniqueItems", person{Name: "Alice", Tags: []string{"a", "a"}}, "/tags/1"},
+		{"items", map[string]interface{}{"name": "Alice", "tags": []interface{}{1}}, "/tags/0"},
+		{"type", map[string]interface{}{"name": "Alice", "age": 1.5}, "/age"},
+		{"multipleOf", map[string]interface{}{"name": "Alice", "score": 0.3}, "/score"},
+		{"additionalProperties", map[string]interface{}{"name": "Alice", "other": 1}, "/other"},
+	}
+	validator := createPersonValidator(t)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validator.Validate(test.value)
+
+			validationError := &ValidationError{}
+			if !errors.As(err, &validationError) {
+				t.Fatalf("Expected validation error but found %v", err)
+			}
+			if len(validationError.Errors) != 1 || validationError.Errors[0].Field != test.field {
+				t.Errorf("Expected error for %s but found %+v", test.field, validationError.Errors)
+			}
+		})
+	}
+}
+
+func TestSchemaValidatorCombinators(t *testing.T) {
+	validator, err := NewSchemaValidator([]byte(`{
+		"anyOf": [{"type": "string"}, {"type": "integer"}],
+		"oneOf": [{"type": "integer", "minimum": 10}, {"type": "integer", "maximum": 20}, {"type": "string"}],
+		"not": {"const": 15}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, valid := range []interface{}{"text", 5, 25} {
+		if err := validator.Validate(valid); err != nil {
+			t.Errorf("Expected %v to be valid but found %v", valid, err)
+		}
+	}
+	for _, invalid := range []interface{}{true, 15, 12} {
+		if err := validator.Validate(invalid); err == nil {
+			t.Errorf("Expected %v to be invalid", invalid)
+		}
+	}
+}
+
+func TestSchemaValidatorBooleanSchema(t *testing.T) {
+	validator, err := NewSchemaValidator([]byte(`{"properties": {"allowed": true, "forbidden": false}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validator.Validate(map[string]int{"allowed": 1}); err != nil {
+		t.Errorf("Expected no error but found %v", err)
+	}
+	if err := validator.Validate(map[string]int{"forbidden": 1}); err == nil {
+		t.Error("Expected error for forbidden property")
+	}
+}
+
+func TestNewSchemaValidatorInvalidPattern(t *testing.T) {
+	_, err := NewSchemaValidator([]byte(`{"pattern": "("}`))
+
+	if err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
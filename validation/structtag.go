@@ -0,0 +1,193 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateTagName is the struct tag which holds the validation rules of a field
+const validateTagName = "validate"
+
+// StructValidator validates structs with rules defined in the struct tag `validate`.
+// Rules are separated by commas:
+//
+//	required     the field must not have its zero value
+//	min=<n>      minimum of numbers, minimum length of strings, slices and maps
+//	max=<n>      maximum of numbers, maximum length of strings, slices and maps
+//	oneof=<a b>  the value has to be one of the space separated values
+//	pattern=<re> strings have to match the regular expression, has to be the last rule
+//
+// Example:
+//
+//	type Person struct {
+//		Name string `json:"name" validate:"required,max=64"`
+//		Age  int    `json:"age" validate:"min=0,max=150"`
+//	}
+//
+// Nested structs, pointers, slices and maps are validated recursively. Field errors use the
+// json names of the fields.
+type StructValidator struct{}
+
+// NewStructValidator creates a StructValidator
+func NewStructValidator() *StructValidator {
+	return &StructValidator{}
+}
+
+// Validate checks the rules of all fields
+func (validator *StructValidator) Validate(value interface{}) error {
+	collector := &errorCollector{}
+	if err := validateValue(reflect.ValueOf(value), "", collector); err != nil {
+		return err
+	}
+	return collector.result()
+}
+
+func validateValue(value reflect.Value, pointer string, collector *errorCollector) error {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return validateValue(value.Elem(), pointer, collector)
+	case reflect.Struct:
+		return validateStruct(value, pointer, collector)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := validateValue(value.Index(i), childPointer(pointer, fmt.Sprint(i)), collector); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iterator := value.MapRange()
+		for iterator.Next() {
+			if err := validateValue(iterator.Value(), childPointer(pointer, fmt.Sprint(iterator.Key().Interface())), collector); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateStruct(value reflect.Value, pointer string, collector *errorCollector) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		fieldPointer := childPointer(pointer, jsonName(field))
+		if tag, ok := field.Tag.Lookup(validateTagName); ok {
+			if err := validateRules(value.Field(i), tag, fieldPointer, collector); err != nil {
+				return fmt.Errorf("invalid rules of field %s: %v", field.Name, err)
+			}
+		}
+		if err := validateValue(value.Field(i), fieldPointer, collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRules checks the rules of a field. Errors are returned for invalid rules.
+func validateRules(value reflect.Value, tag string, pointer string, collector *errorCollector) error {
+	rules := tag
+	pattern := ""
+	if index := strings.Index(tag, "pattern="); index >= 0 {
+		rules = tag[:index]
+		pattern = tag[index+len("pattern="):]
+	}
+
+	if value.IsZero() {
+		if strings.Contains(","+rules+",", ",required,") {
+			collector.add(pointer, "field is required")
+		}
+		// optional fields are only validated if set
+		return nil
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		parts := strings.SplitN(rule, "=", 2)
+		name, argument := parts[0], ""
+		if len(parts) == 2 {
+			argument = parts[1]
+		}
+		switch name {
+		case "", "required":
+		case "min", "max":
+			limit, err := strconv.ParseFloat(argument, 64)
+			if err != nil {
+				return err
+			}
+			actual, unit, ok := measure(value)
+			if !ok {
+				return fmt.Errorf("rule %s is not supported for %s", name, value.Kind())
+			}
+			if name == "min" && actual < limit {
+				collector.add(pointer, "expected at least %v%s but found %v", limit, unit, actual)
+			}
+			if name == "max" && actual > limit {
+				collector.add(pointer, "expected at most %v%s but found %v", limit, unit, actual)
+			}
+		case "oneof":
+			actual := fmt.Sprint(value.Interface())
+			allowed := strings.Fields(argument)
+			found := false
+			for _, candidate := range allowed {
+				found = found || candidate == actual
+			}
+			if !found {
+				collector.add(pointer, "value is not one of %v", allowed)
+			}
+		default:
+			return fmt.Errorf("unknown rule %s", name)
+		}
+	}
+
+	if pattern != "" {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		if value.Kind() != reflect.String {
+			return fmt.Errorf("rule pattern is not supported for %s", value.Kind())
+		}
+		if !expression.MatchString(value.String()) {
+			collector.add(pointer, "value does not match pattern %s", pattern)
+		}
+	}
+	return nil
+}
+
+// measure returns the number or length of a value which is compared by min and max
+func measure(value reflect.Value) (float64, string, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), "", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), " items", true
+	default:
+		return 0, "", false
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("json"); ok {
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
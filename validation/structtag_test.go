@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type customer struct {
+	Name      string    `json:"name" validate:"required,max=5"`
+	Age       int       `json:"age" validate:"min=18"`
+	Plan      string    `json:"plan" validate:"oneof=free pro"`
+	Code      string    `json:"code" validate:"pattern=^[a-z]{2,3}$"`
+	Addresses []address `json:"addresses" validate:"max=2"`
+	Manager   *customer `json:"manager,omitempty"`
+}
+
+func TestStructValidatorValid(t *testing.T) {
+	validator := NewStructValidator()
+
+	err := validator.Validate(customer{Name: "Bob", Age: 20, Plan: "pro", Code: "ab", Addresses: []address{{City: "Berlin"}}})
+
+	if err != nil {
+		t.Errorf("Expected no error but found %v", err)
+	}
+}
+
+func TestStructValidatorOptionalFields(t *testing.T) {
+	validator := NewStructValidator()
+
+	err := validator.Validate(&customer{Name: "Bob"})
+
+	if err != nil {
+		t.Errorf("Expected unset fields to be valid but found %v", err)
+	}
+}
+
+func TestStructValidatorFieldErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value customer
+		field string
+	}{
+		{"required", customer{}, "/name"},
+		{"max", customer{Name: "Robert"}, "/name"},
+		{"min", customer{Name: "Bob", Age: 17}, "/age"},
+		{"oneof", customer{Name: "Bob", Plan: "gold"}, "/plan"},
+		{"pattern", customer{Name: "Bob", Code: "ABC"}, "/code"},
+		{"max items", customer{Name: "Bob", Addresses: []address{{"a"}, {"b"}, {"c"}}}, "/addresses"},
+		{"nested", customer{Name: "Bob", Addresses: []address{{}}}, "/addresses/0/city"},
+		{"pointer", customer{Name: "Bob", Manager: &customer{}}, "/manager/name"},
+	}
+	validator := NewStructValidator()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validator.Validate(test.value)
+
+			validationError := &ValidationError{}
+			if !errors.As(err, &validationError) {
+				t.Fatalf("Expected validation error but found %v", err)
+			}
+			if len(validationError.Errors) != 1 || validationError.Errors[0].Field != test.field {
+				t.Errorf("Expected error for %s but found %+v", test.field, validationError.Errors)
+			}
+		})
+	}
+}
+
+func TestStructValidatorInvalidRule(t *testing.T) {
+	validator := NewStructValidator()
+	value := struct {
+		Name string `validate:"unknown"`
+	}{Name: "value"}
+
+	err := validator.Validate(value)
+
+	validationError := &ValidationError{}
+	if err == nil || errors.As(err, &validationError) {
+		t.Errorf("Expected error for invalid rule but found %v", err)
+	}
+}
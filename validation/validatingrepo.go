@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"errors"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+// ValidatingRepo validates values before they are written to the wrapped repository.
+// Invalid values are rejected with a *ValidationError.
+type ValidatingRepo struct {
+	wrappedRepo repository.KeyValueRepo
+	validator   Validator
+}
+
+// NewValidatingRepo creates a ValidatingRepo
+func NewValidatingRepo(repo repository.KeyValueRepo, validator Validator) *ValidatingRepo {
+	return &ValidatingRepo{
+		wrappedRepo: repo,
+		validator:   validator,
+	}
+}
+
+// FindAll calls function of wrapped repository
+func (repo *ValidatingRepo) FindAll() ([]repository.KeyValuePair, error) {
+	return repo.wrappedRepo.FindAll()
+}
+
+// Find calls function of wrapped repository
+func (repo *ValidatingRepo) Find(key string) (repository.KeyValuePair, error) {
+	return repo.wrappedRepo.Find(key)
+}
+
+// Save validates the value and calls function of wrapped repository
+func (repo *ValidatingRepo) Save(key string, in interface{}) (repository.KeyValuePair, error) {
+	if err := repo.validator.Validate(in); err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Save(key, in)
+}
+
+// Overwrite validates the value and calls function of wrapped repository
+func (repo *ValidatingRepo) Overwrite(key string, in interface{}) (repository.KeyValuePair, error) {
+	if err := repo.validator.Validate(in); err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	return repo.wrappedRepo.Overwrite(key, in)
+}
+
+// Delete calls function of wrapped repository
+func (repo *ValidatingRepo) Delete(key string) error {
+	return repo.wrappedRepo.Delete(key)
+}
+
+// Patch applies a JSON merge patch and validates the result before it is written. If the wrapped
+// repository implements ConditionalRepo, the write fails if the item was changed concurrently.
+func (repo *ValidatingRepo) Patch(key string, mergePatch []byte) (repository.KeyValuePair, error) {
+	item, err := repo.wrappedRepo.Find(key)
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	patched, err := repository.ApplyMergePatch(item.Value, mergePatch)
+	if err != nil {
+		return repository.KeyValuePair{}, err
+	}
+	if err := repo.validator.Validate(patched); err != nil {
+		return repository.KeyValuePair{}, err
+	}
+
+	if conditional, ok := repo.wrappedRepo.(repository.ConditionalRepo); ok {
+		result, err := conditional.CompareAndSwap(key, item.Value, patched)
+		if !errors.Is(err, repository.ErrNotSupported) {
+			return result, err
+		}
+	}
+	return repo.wrappedRepo.Overwrite(key, patched)
+}
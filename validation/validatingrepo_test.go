@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jo-hoe/serverless-toolbox/repository"
+)
+
+func createValidatingRepo() (*ValidatingRepo, *repository.InMemoryRepo) {
+	wrapped := repository.NewInMemoryRepo()
+	return NewValidatingRepo(wrapped, NewStructValidator()), wrapped
+}
+
+func TestValidatingRepoSave(t *testing.T) {
+	repo, wrapped := createValidatingRepo()
+
+	_, err := repo.Save("valid", address{City: "Berlin"})
+	checkError(err, t)
+	_, err = repo.Save("invalid", address{})
+
+	validationError := &ValidationError{}
+	if !errors.As(err, &validationError) {
+		t.Errorf("Expected validation error but found %v", err)
+	}
+	items, _ := wrapped.FindAll()
+	if len(items) != 1 {
+		t.Errorf("Expected only valid item to be stored but found %+v", items)
+	}
+}
+
+func TestValidatingRepoOverwrite(t *testing.T) {
+	repo, wrapped := createValidatingRepo()
+	_, err := wrapped.Save("key", address{City: "Berlin"})
+	checkError(err, t)
+
+	_, err = repo.Overwrite("key", address{})
+
+	if err == nil {
+		t.Error("Expected validation error")
+	}
+	item, _ := wrapped.Find("key")
+	if item.Value != (address{City: "Berlin"}) {
+		t.Errorf("Expected item to stay unchanged but found %+v", item.Value)
+	}
+}
+
+func TestValidatingRepoPatch(t *testing.T) {
+	repo, wrapped := createValidatingRepo()
+	_, err := wrapped.Save("key", address{City: "Berlin"})
+	checkError(err, t)
+
+	_, err = repository.Patch(repo, "key", []byte(`{"city":""}`))
+	if err == nil {
+		t.Error("Expected validation error")
+	}
+	_, err = repository.Patch(repo, "key", []byte(`{"city":"Hamburg"}`))
+	checkError(err, t)
+
+	item, _ := wrapped.Find("key")
+	if item.Value != (address{City: "Hamburg"}) {
+		t.Errorf("Expected patched item but found %+v", item.Value)
+	}
+}
+
+func checkError(err error, t *testing.T) {
+	if err != nil {
+		t.Error(err)
+	}
+}
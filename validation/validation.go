@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Validator checks values before they are persisted
+type Validator interface {
+	// returns a *ValidationError if the value is invalid
+	Validate(value interface{}) error
+}
+
+// FieldError describes why the value of a field is invalid
+type FieldError struct {
+	// JSON pointer of the field, empty for the value itself
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError contains all field errors of an invalid value
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, 0, len(err.Errors))
+	for _, fieldError := range err.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+	}
+	return fmt.Sprintf("validation failed: %s", strings.Join(messages, ", "))
+}
+
+// errorCollector collects field errors while walking a value
+type errorCollector struct {
+	errors []FieldError
+}
+
+func (collector *errorCollector) add(field string, format string, args ...interface{}) {
+	collector.errors = append(collector.errors, FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (collector *errorCollector) result() error {
+	if len(collector.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: collector.errors}
+}
+
+// toGeneric converts a value into its json representation of maps, slices and primitives
+func toGeneric(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var result interface{}
+	err = decoder.Decode(&result)
+	return result, err
+}
+
+// childPointer appends a segment to a JSON pointer
+func childPointer(pointer string, segment string) string {
+	segment = strings.ReplaceAll(segment, "~", "~0")
+	segment = strings.ReplaceAll(segment, "/", "~1")
+	return pointer + "/" + segment
+}